@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// admin is the HTTP admin API for operators. If a token is set, requests
+// should have the `Authorization: Bearer $token` header.
+type admin struct {
+	h     *handlers
+	token string
+}
+
+// listen starts the admin API HTTP server. It blocks.
+func (a *admin) listen(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/queries", a.auth(a.handleQueries))
+	mux.HandleFunc("/api/top", a.auth(a.handleTop))
+	mux.HandleFunc("/metrics", a.auth(a.handleMetrics))
+
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleQueries returns the recent anonymized queries to a service
+// (?service=$name) or the list of services with logged queries.
+func (a *admin) handleQueries(w http.ResponseWriter, r *http.Request) {
+	if a.h.audit == nil {
+		writeJSON(w, http.StatusNotFound, "query audit log is disabled")
+		return
+	}
+
+	svc := r.URL.Query().Get("service")
+	if svc == "" {
+		writeJSON(w, http.StatusOK, a.h.audit.Services())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.h.audit.Recent(svc))
+}
+
+// handleTop returns the most popular queries to a service (?service=$name)
+// over the last hour or day (?period=hour|day), or the list of services
+// with counted queries. ?n= is the number of queries (default 10).
+func (a *admin) handleTop(w http.ResponseWriter, r *http.Request) {
+	if a.h.analytics == nil {
+		writeJSON(w, http.StatusNotFound, "query analytics are disabled")
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("service") == "" {
+		writeJSON(w, http.StatusOK, a.h.analytics.Services())
+		return
+	}
+
+	period := time.Hour
+	switch q.Get("period") {
+	case "", "hour":
+	case "day":
+		period = time.Hour * 24
+	default:
+		writeJSON(w, http.StatusBadRequest, "invalid period. Should be hour or day")
+		return
+	}
+
+	n, _ := strconv.Atoi(q.Get("n"))
+	if n < 1 || n > 1000 {
+		n = 10
+	}
+
+	writeJSON(w, http.StatusOK, a.h.analytics.Top(q.Get("service"), period, n))
+}
+
+// handleMetrics returns the query, cache, and upstream metrics in the
+// Prometheus text format.
+func (a *admin) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if a.h.metrics == nil {
+		writeJSON(w, http.StatusNotFound, "metrics are disabled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := a.h.metrics.Write(w); err != nil {
+		slog.Error("error writing metrics", "error", err)
+	}
+}
+
+// auth wraps a handler to check the admin token.
+func (a *admin) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.token != "" {
+			t := []byte("Bearer " + a.token)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), t) != 1 {
+				writeJSON(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// writeJSON writes a JSON response as {"data": v}.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Data interface{} `json:"data"`
+	}{v}); err != nil {
+		slog.Error("error writing admin response", "error", err)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const dohMIME = "application/dns-message"
+
+// dohOpt holds the DoH server options.
+type dohOpt struct {
+	Address string
+	Path    string
+
+	// TLS config of the server. nil to serve plain HTTP (eg: behind a TLS
+	// terminating proxy).
+	TLS *tls.Config
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// Networks of proxies whose X-Forwarded-For header is trusted for the
+	// client's IP.
+	TrustedProxies []*net.IPNet
+}
+
+// doh serves DNS-over-HTTPS (RFC 8484) queries with a DNS handler.
+type doh struct {
+	opt     dohOpt
+	handler dns.Handler
+
+	// The HTTP server once listen() is called, for shutdown().
+	srv *http.Server
+	mu  sync.Mutex
+}
+
+// dohWriter is a dns.ResponseWriter that captures the response to a query.
+type dohWriter struct {
+	local, remote net.Addr
+	msg           *dns.Msg
+}
+
+// listen starts the DoH HTTP server. It blocks.
+func (d *doh) listen() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(d.opt.Path, d.handleQuery)
+
+	srv := &http.Server{
+		Addr:              d.opt.Address,
+		Handler:           mux,
+		TLSConfig:         d.opt.TLS,
+		ReadHeaderTimeout: d.opt.ReadHeaderTimeout,
+		ReadTimeout:       d.opt.ReadTimeout,
+		WriteTimeout:      d.opt.WriteTimeout,
+		IdleTimeout:       d.opt.IdleTimeout,
+	}
+	d.mu.Lock()
+	d.srv = srv
+	d.mu.Unlock()
+
+	var err error
+	if d.opt.TLS == nil {
+		err = srv.ListenAndServe()
+	} else {
+		err = srv.ListenAndServeTLS("", "")
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// shutdown stops accepting connections and waits for in-flight queries to
+// be answered until ctx is done.
+func (d *doh) shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	srv := d.srv
+	d.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Shutdown(ctx)
+}
+
+// handleQuery handles a DoH query with the wire format message in the `dns`
+// param of a GET request or the body of a POST request.
+func (d *doh) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var (
+		b   []byte
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		b, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != dohMIME {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		b, err = ioutil.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil || len(b) == 0 {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+
+	req := &dns.Msg{}
+	if err := req.Unpack(b); err != nil {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+
+	// The network is https so that answers that report the transport
+	// (eg: ip) show it.
+	rw := &dohWriter{remote: listenerAddr{d.clientAddr(r), "https"}, local: &net.TCPAddr{}}
+	d.handler.ServeDNS(rw, req)
+	if rw.msg == nil {
+		http.Error(w, "no response", http.StatusInternalServerError)
+		return
+	}
+
+	out, err := rw.msg.Pack()
+	if err != nil {
+		slog.Error("error packing DoH response", "error", err)
+		http.Error(w, "error preparing response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", dohMIME)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", minTTL(rw.msg)))
+	w.Write(out)
+}
+
+// clientAddr returns the address of the client of a request. If the request
+// is from a trusted proxy, it's the last address in its X-Forwarded-For
+// header, which is the one that the proxy added.
+func (d *doh) clientAddr(r *http.Request) *net.TCPAddr {
+	// The remote address is ip:port.
+	remote, _ := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if remote == nil {
+		return &net.TCPAddr{}
+	}
+
+	if !d.trusted(remote.IP) {
+		return remote
+	}
+
+	fwd := r.Header.Values("X-Forwarded-For")
+	if len(fwd) == 0 {
+		return remote
+	}
+	addrs := strings.Split(fwd[len(fwd)-1], ",")
+	if ip := net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1])); ip != nil {
+		return &net.TCPAddr{IP: ip}
+	}
+
+	return remote
+}
+
+// trusted checks whether an IP is of a trusted proxy.
+func (d *doh) trusted(ip net.IP) bool {
+	for _, n := range d.opt.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// minTTL returns the lowest TTL of the records in a response for the
+// HTTP cache lifetime.
+func minTTL(m *dns.Msg) uint32 {
+	ttl := uint32(defaultTTL)
+	for i, rr := range m.Answer {
+		if t := rr.Header().Ttl; i == 0 || t < ttl {
+			ttl = t
+		}
+	}
+
+	return ttl
+}
+
+func (w *dohWriter) LocalAddr() net.Addr  { return w.local }
+func (w *dohWriter) RemoteAddr() net.Addr { return w.remote }
+
+func (w *dohWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *dohWriter) Write(b []byte) (int, error) {
+	m := &dns.Msg{}
+	if err := m.Unpack(b); err != nil {
+		return 0, err
+	}
+	w.msg = m
+	return len(b), nil
+}
+
+func (w *dohWriter) Close() error        { return nil }
+func (w *dohWriter) TsigStatus() error   { return nil }
+func (w *dohWriter) TsigTimersOnly(bool) {}
+func (w *dohWriter) Hijack()             {}
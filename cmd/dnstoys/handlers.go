@@ -1,12 +1,26 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"regexp"
+	"log/slog"
+	"net"
 	"strings"
-
+	"time"
+
+	"github.com/knadh/dns.toys/internal/analytics"
+	"github.com/knadh/dns.toys/internal/audit"
+	"github.com/knadh/dns.toys/internal/creds"
+	"github.com/knadh/dns.toys/internal/errs"
+	"github.com/knadh/dns.toys/internal/i18n"
+	"github.com/knadh/dns.toys/internal/logging"
+	"github.com/knadh/dns.toys/internal/metrics"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/ratelimit"
+	"github.com/knadh/dns.toys/internal/record"
+	"github.com/knadh/dns.toys/internal/resolvers"
+	"github.com/knadh/dns.toys/internal/txt"
 	"github.com/miekg/dns"
 )
 
@@ -14,35 +28,201 @@ import (
 // of DNS query.
 type Service interface {
 	Query(string) ([]string, error)
+}
+
+// Dumper is a Service whose state can be saved to a snapshot on exit.
+type Dumper interface {
 	Dump() ([]byte, error)
 }
 
+// ContextService is a Service that stops working on a query (eg: waiting
+// for an upstream) when the query's deadline is reached.
+type ContextService interface {
+	QueryContext(context.Context, string) ([]string, error)
+}
+
+// ClientService is a Service whose answers depend on the client that sent
+// the query (eg: state kept per client IP).
+type ClientService interface {
+	QueryClient(q string, client net.IP) ([]string, error)
+}
+
+// Sensitive is a Service whose queries carry secrets (eg: stored values or
+// tokens). Its queries are redacted in the audit log and the query logs, and
+// are not counted in the analytics.
+type Sensitive interface {
+	Sensitive() bool
+}
+
+// PrefixService is a Service that shares its suffix with another and only
+// answers the queries that start with one of its prefixes. eg: put-$note.note
+type PrefixService interface {
+	Service
+	Prefixes() []string
+}
+
+// Dashed is a Service that also answers $suffix-$query names without its
+// suffix label. eg: probe-512
+type Dashed interface {
+	Dashed() bool
+}
+
+// Strict is a Service whose queries are rejected rather than answered when
+// normalization would remove characters from them. eg: Ca(OH)2.molar
+type Strict interface {
+	Strict() bool
+}
+
+// redacted replaces the queries to Sensitive services in logs.
+const redacted = "[redacted]"
+
+// TTL of service responses unless specified otherwise.
+const defaultTTL = 1
+
+// meta describes a service for the `help` and `services` queries.
+type meta struct {
+	// Query suffixes the service is registered for.
+	Names []string
+
+	Desc   string
+	Syntax string
+
+	// Example queries with %s for the server's domain. The first
+	// one is shown in the `help` list.
+	Examples []string
+
+	// Upstream indicates whether responses depend on a third party API.
+	Upstream bool
+
+	TTL int
+}
+
 type handlers struct {
 	services map[string]Service
-	domain   string
-	help     []dns.RR
-}
 
-var reClean = regexp.MustCompile("[^a-zA-Z0-9/\\-\\.:]")
+	// Services that answer the queries with their prefixes on a suffix
+	// that's shared with another service.
+	prefixed map[string][]PrefixService
+
+	// Handlers of the services that answer $suffix-$query names by suffix.
+	dashed map[string]func(w dns.ResponseWriter, r *dns.Msg)
+
+	// Services with state to snapshot on exit by config name. These are
+	// the registered services that implement Dumper, and any other state
+	// they add with registry.Env.Snapshot (eg: the weather geocoder).
+	snapshots map[string]Dumper
+
+	domain string
+	i18n   *i18n.I18n
+	creds  *creds.Manager
+
+	// Time budget for answering a query.
+	timeout time.Duration
+
+	// Location and hostname of this node in multi-node deployments.
+	pop  string
+	node string
+
+	// Recent and top queries to services for the admin API. nil if disabled.
+	audit     *audit.Log
+	analytics *analytics.Analytics
+
+	// Query counts and latencies for the admin API's /metrics. nil if disabled.
+	metrics *metrics.Metrics
+
+	// help and svcHelp are keyed by language ("" for English).
+	// svcHelp keys are $service or $service.$lang.
+	help     map[string][]dns.RR
+	svcHelp  map[string][]dns.RR
+	servList []dns.RR
+}
 
 // register registers a Service for a given query suffix on the DNS server.
-// A Service responds to a DNS query via Query().
+// A Service responds to a DNS query via Query(). The service is also registered
+// for $suffix.$lang for every language with a translation catalog so that
+// errors are returned in that language. eg: mumbai.time.de
+//
+// A PrefixService only answers the queries with its prefixes and the service
+// that shares its suffix, if any, answers the rest.
 func (h *handlers) register(suffix string, s Service, mux *dns.ServeMux) func(w dns.ResponseWriter, r *dns.Msg) {
-	f := func(w dns.ResponseWriter, r *dns.Msg) {
-		m := &dns.Msg{}
-		m.SetReply(r)
-		m.Compress = false
+	if p, ok := s.(PrefixService); ok {
+		h.prefixed[suffix] = append(h.prefixed[suffix], p)
+		if base, ok := h.services[suffix]; ok {
+			s = base
+		}
+	}
+
+	f := h.serve(suffix, "", "", s)
+	if d, ok := s.(Dashed); ok && d.Dashed() {
+		h.dashed[suffix] = f
+	}
+
+	h.services[suffix] = s
+	mux.HandleFunc(suffix+".", f)
+	for _, l := range h.i18n.Langs() {
+		mux.HandleFunc(suffix+"."+l+".", h.serve(suffix, "", l, s))
+	}
+
+	// Machine readable output modes for services that support them.
+	if _, ok := s.(record.Structured); ok {
+		for _, mode := range []string{"kv", "json"} {
+			fs := &fields{Service: s, mode: mode}
+			mux.HandleFunc(suffix+"."+mode+".", h.serve(suffix, mode, "", fs))
+			for _, l := range h.i18n.Langs() {
+				mux.HandleFunc(suffix+"."+mode+"."+l+".", h.serve(suffix, mode, l, fs))
+			}
+		}
+	}
+
+	return f
+}
+
+// route returns the PrefixService on a suffix that answers a query with
+// one of its prefixes (in any case), or s.
+func (h *handlers) route(suffix, query string, s Service) Service {
+	query = strings.ToLower(query)
+	for _, p := range h.prefixed[suffix] {
+		for _, pre := range p.Prefixes() {
+			if strings.HasPrefix(query, pre) {
+				return p
+			}
+		}
+	}
+
+	return s
+}
+
+// serve returns a DNS handler that executes a Service on incoming queries with
+// the given suffix and output mode (eg: kv), if any. Errors are translated
+// to lang.
+func (h *handlers) serve(suffix, mode, lang string, s Service) func(w dns.ResponseWriter, r *dns.Msg) {
+	trim := "." + suffix + "."
+	if mode != "" {
+		trim += mode + "."
+	}
+	if lang != "" {
+		trim += lang + "."
+	}
+
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := newReply(r)
 
 		if r.Opcode != dns.OpcodeQuery {
+			m.Rcode = dns.RcodeNotImplemented
 			w.WriteMsg(m)
 			return
 		}
 
 		if len(m.Question) > 5 {
-			respErr(errors.New("too many queries."), w, m)
+			respErr(h.tr(lang, errors.New("too many queries.")), w, m)
 			return
 		}
 
+		ctx, cancel := context.WithTimeout(logging.WithRequest(context.Background()), h.timeout)
+		defer cancel()
+
+		client := clientIP(w)
+
 		// Execute the service on all the questions.
 		out := []dns.RR{}
 		for _, q := range m.Question {
@@ -52,17 +232,60 @@ func (h *handlers) register(suffix string, s Service, mux *dns.ServeMux) func(w
 
 			// Call the service with the incoming query.
 			// Strip the service suffix from the query eg: mumbai.time.
-			ans, err := s.Query(cleanQuery(q.Name, "."+suffix+"."))
+			query, err := cleanQuery(q.Name, trim)
 			if err != nil {
-				respErr(err, w, m)
+				h.respErr(suffix, lang, err, w, m)
+				return
+			}
+
+			// Prefixed queries only have plain answers.
+			svc := s
+			if mode == "" {
+				svc = h.route(suffix, query, s)
+			}
+
+			var (
+				sensitive = isSensitive(svc)
+				logged    = query
+			)
+			if sensitive {
+				logged = redacted
+			}
+			if isStrict(svc) {
+				if _, err := strictQuery(q.Name, trim); err != nil {
+					h.respErr(suffix, lang, err, w, m)
+					return
+				}
+			}
+
+			if h.audit != nil {
+				h.audit.Add(suffix, logged, dns.TypeToString[q.Qtype], client)
+			}
+			if h.analytics != nil && !sensitive {
+				h.analytics.Add(suffix, query)
+			}
+
+			start := time.Now()
+			ans, err := h.query(ctx, svc, query, client)
+			h.observe(suffix, start, err)
+			logQuery(ctx, suffix, logged, q.Qtype, client, start, err)
+			if err == context.DeadlineExceeded {
+				// Out of time. Respond with the answers so far, if any.
+				if len(out) == 0 {
+					out = append(out, newTXT(q.Name, []string{h.i18n.T(lang, "response took too long. Try again in a few seconds.")}))
+				}
+				break
+			}
+			if err != nil {
+				h.respErr(suffix, lang, err, w, m)
 				return
 			}
 
 			// Convert string responses to dns.RR{}.
 			o, err := makeResp(ans)
 			if err != nil {
-				log.Printf("error preparing response: %v", err)
-				respErr(errors.New("error preparing response."), w, m)
+				slog.ErrorContext(ctx, "error preparing response", "service", suffix, "error", err)
+				respErr(h.tr(lang, errs.New(errs.Internal, "error preparing response.")), w, m)
 				return
 			}
 
@@ -73,35 +296,240 @@ func (h *handlers) register(suffix string, s Service, mux *dns.ServeMux) func(w
 		m.Answer = out
 		w.WriteMsg(m)
 	}
+}
 
-	h.services[suffix] = s
-	mux.HandleFunc(suffix+".", f)
-	return f
+// isStrict checks whether a service rejects queries that lose characters
+// to normalization.
+func isStrict(s interface{}) bool {
+	st, ok := s.(Strict)
+	return ok && st.Strict()
+}
+
+// isSensitive checks whether a service's queries carry secrets.
+func isSensitive(s interface{}) bool {
+	sn, ok := s.(Sensitive)
+	return ok && sn.Sensitive()
+}
+
+// fields is a Service that answers the queries to a record.Structured Service
+// in a machine readable output mode. In the kv mode, every record is a TXT
+// record with key=value strings. In the json mode, all records are a JSON
+// array split across the strings of a single TXT record.
+type fields struct {
+	Service
+	mode string
+}
+
+// Query returns the records of a query in the output mode.
+func (f *fields) Query(q string) ([]string, error) {
+	recs, err := f.Service.(record.Structured).Fields(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.mode == "json" {
+		strs, err := record.JSON(recs)
+		if err != nil {
+			return nil, errs.Wrap(errs.Internal, "error preparing response.", err)
+		}
+		return []string{txt.Record(q, strs...)}, nil
+	}
+
+	out := make([]string, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, txt.Record(q, r.KV()...))
+	}
+
+	return out, nil
+}
+
+// Sensitive returns whether the structured service is Sensitive.
+func (f *fields) Sensitive() bool {
+	return isSensitive(f.Service)
+}
+
+// Strict returns whether the structured service is Strict.
+func (f *fields) Strict() bool {
+	return isStrict(f.Service)
+}
+
+// query runs a service's query within the deadline of ctx. Services that
+// aren't a ContextService are run in the background and their answer is
+// discarded if the deadline passes. ClientServices get the client's IP.
+func (h *handlers) query(ctx context.Context, s Service, q string, client net.IP) ([]string, error) {
+	if cs, ok := s.(ContextService); ok {
+		return cs.QueryContext(ctx, q)
+	}
+
+	type result struct {
+		ans []string
+		err error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		if cs, ok := s.(ClientService); ok {
+			ans, err := cs.QueryClient(q, client)
+			ch <- result{ans, err}
+			return
+		}
+
+		ans, err := s.Query(q)
+		ch <- result{ans, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.ans, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// observe records the latency and outcome of a query to a service for
+// the metrics.
+func (h *handlers) observe(suffix string, start time.Time, err error) {
+	if h.metrics == nil {
+		return
+	}
+
+	var kind string
+	switch {
+	case err == context.DeadlineExceeded:
+		kind = "timeout"
+	case err != nil:
+		kind = errs.KindOf(err).String()
+	}
+
+	h.metrics.Observe(suffix, time.Since(start), kind)
+}
+
+// logQuery logs a query to a service with the request ID of ctx, the client,
+// the query type, the time taken, and how it was answered. Queries that
+// failed for reasons other than bad input are logged as warnings.
+func logQuery(ctx context.Context, suffix, query string, qtype uint16, client net.IP, start time.Time, err error) {
+	var (
+		outcome = logging.Outcome(ctx)
+		level   = slog.LevelInfo
+		attrs   = []slog.Attr{
+			slog.String("service", suffix),
+			slog.String("query", query),
+			slog.String("qtype", dns.TypeToString[qtype]),
+			slog.String("client", client.String()),
+			slog.Duration("duration", time.Since(start)),
+		}
+	)
+
+	switch {
+	case err == context.DeadlineExceeded:
+		outcome, level = "timeout", slog.LevelWarn
+	case outcome == logging.Queued:
+	case err != nil:
+		k := errs.KindOf(err)
+		outcome = k.String() + "_error"
+		if k != errs.User && k != errs.NotImplemented {
+			level = slog.LevelWarn
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+	case outcome == "":
+		outcome = "ok"
+	}
+
+	slog.LogAttrs(ctx, level, "query", append(attrs, slog.String("outcome", outcome))...)
 }
 
 // handleEchoIP returns the client's IP address as a DNS response.
 // Although it is a service, it's not registered like a Service as it
 // uses w.RemoteAddr() instead of m.Question unlike a typical service.
+// A and AAAA queries get the bare address and TXT queries get the address
+// followed by the connection details.
 func (h *handlers) handleEchoIP(w dns.ResponseWriter, r *dns.Msg) {
 	m := &dns.Msg{}
 	m.SetReply(r)
 	m.Compress = false
 
+	host, port, err := net.SplitHostPort(w.RemoteAddr().String())
+	ip := net.ParseIP(host)
+	if err != nil || ip == nil {
+		respErr(errs.New(errs.Internal, "unable to detect IP."), w, m)
+		return
+	}
+
 	for _, q := range m.Question {
-		if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeA {
+		var rrstr string
+		switch q.Qtype {
+		case dns.TypeTXT:
+			strs := append([]string{ip.String(), "port " + port, transport(w)}, ednsInfo(r)...)
+			rrstr = txt.Record("ip.", append(strs, h.popInfo()...)...)
+		case dns.TypeA:
+			if ip.To4() == nil {
+				continue
+			}
+			rrstr = fmt.Sprintf("ip. 1 A %s", ip)
+		case dns.TypeAAAA:
+			if ip.To4() != nil {
+				continue
+			}
+			rrstr = fmt.Sprintf("ip. 1 AAAA %s", ip)
+		default:
 			continue
 		}
 
-		a := strings.Split(w.RemoteAddr().String(), ":")
-		if len(a) != 2 {
-			respErr(errors.New("unable to detect IP."), w, m)
-			w.WriteMsg(m)
+		rr, err := dns.NewRR(rrstr)
+		if err != nil {
+			slog.Error("error preparing ip response", "error", err)
 			return
 		}
 
-		rr, err := dns.NewRR(fmt.Sprintf("ip. 1 TXT \"%s\"", a[0]))
+		m.Answer = append(m.Answer, rr)
+	}
+
+	w.WriteMsg(m)
+}
+
+// handleResolver returns the public resolver network (if known) that a query
+// arrived from along with the DNSSEC and EDNS features the resolver requested.
+func (h *handlers) handleResolver(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	host, _, err := net.SplitHostPort(w.RemoteAddr().String())
+	ip := net.ParseIP(host)
+	if err != nil || ip == nil {
+		respErr(errs.New(errs.Internal, "unable to detect IP."), w, m)
+		return
+	}
+
+	name := resolvers.Lookup(ip)
+	if name == "" {
+		name = "unknown network"
+	}
+
+	do := "no"
+	opts := []string{}
+	if o := r.IsEdns0(); o != nil {
+		if o.Do() {
+			do = "yes"
+		}
+		for _, opt := range o.Option {
+			opts = append(opts, ednsOptName(opt.Option()))
+		}
+	}
+	if len(opts) == 0 {
+		opts = append(opts, "none")
+	}
+
+	for _, q := range m.Question {
+		if q.Qtype != dns.TypeTXT {
+			continue
+		}
+
+		strs := append([]string{ip.String(), name, "dnssec ok " + do}, ednsInfo(r)...)
+		strs = append(strs, "edns options "+strings.Join(opts, ","))
+		rr, err := dns.NewRR(txt.Record(q.Name, append(strs, h.popInfo()...)...))
 		if err != nil {
-			lo.Printf("error preparing ip response: %v", err)
+			slog.Error("error preparing resolver response", "error", err)
 			return
 		}
 
@@ -111,6 +539,39 @@ func (h *handlers) handleEchoIP(w dns.ResponseWriter, r *dns.Msg) {
 	w.WriteMsg(m)
 }
 
+// handleFromWhere returns the location (POP) and hostname of the node
+// that answered the query.
+func (h *handlers) handleFromWhere(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	pop := h.pop
+	if pop == "" {
+		pop = "unknown"
+	}
+
+	for _, q := range m.Question {
+		if q.Qtype != dns.TypeTXT {
+			continue
+		}
+
+		m.Answer = append(m.Answer, newTXT(q.Name, []string{"pop " + pop, "node " + h.node}))
+	}
+
+	w.WriteMsg(m)
+}
+
+// popInfo returns a TXT string with the location of the node to append
+// to answers, or none if it's not set.
+func (h *handlers) popInfo() []string {
+	if h.pop == "" {
+		return nil
+	}
+
+	return []string{"pop " + h.pop}
+}
+
 // handlePi returns values of pi relevant for the record type.
 // TXT  record: "3.141592653589793238462643383279502884197169"
 // A    record: 3.141.59.26
@@ -123,7 +584,7 @@ func (h *handlers) handlePi(w dns.ResponseWriter, r *dns.Msg) {
 	for _, q := range m.Question {
 		var rrstr string
 		if q.Qtype == dns.TypeTXT {
-			rrstr = "pi. 1 TXT 3.141592653589793238462643383279502884197169"
+			rrstr = txt.Record("pi.", "3.141592653589793238462643383279502884197169")
 		} else if q.Qtype == dns.TypeA {
 			rrstr = "pi. IN A 3.141.59.27"
 		} else if q.Qtype == dns.TypeAAAA {
@@ -133,7 +594,7 @@ func (h *handlers) handlePi(w dns.ResponseWriter, r *dns.Msg) {
 		}
 		rr, err := dns.NewRR(rrstr)
 		if err != nil {
-			lo.Printf("error preparing pi response: %v", err)
+			slog.Error("error preparing pi response", "error", err)
 			return
 		}
 		m.Answer = append(m.Answer, rr)
@@ -142,37 +603,296 @@ func (h *handlers) handlePi(w dns.ResponseWriter, r *dns.Msg) {
 	w.WriteMsg(m)
 }
 
+// handleHelp returns the list of services for the `help` and `help.$lang` queries.
 func (h *handlers) handleHelp(w dns.ResponseWriter, r *dns.Msg) {
 	m := &dns.Msg{}
 	m.SetReply(r)
 	m.Compress = false
-	m.Answer = h.help
+
+	for _, q := range m.Question {
+		lang := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(q.Name), "help"), ".")
+		m.Answer = append(m.Answer, h.help[strings.TrimPrefix(lang, ".")]...)
+	}
+
 	w.WriteMsg(m)
 }
 
-func (h *handlers) handleDefault(w dns.ResponseWriter, m *dns.Msg) {
-	respErr(fmt.Errorf(`unknown query. try: dig help @%s`, h.domain), w, m)
+// handleServiceHelp returns the description, syntax, and examples of
+// a service for the `help.$service` and `help.$service.$lang` queries.
+func (h *handlers) handleServiceHelp(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	for _, q := range m.Question {
+		name := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(q.Name), "help."), ".")
+		m.Answer = append(m.Answer, h.svcHelp[name]...)
+	}
+
 	w.WriteMsg(m)
 }
 
-// respErr writes an error message to a DNS response.
-func respErr(err error, w dns.ResponseWriter, m *dns.Msg) {
-	r, err := dns.NewRR(fmt.Sprintf(". 1 IN TXT \"error: %s\"", err.Error()))
-	if err != nil {
-		lo.Println(err)
+// handleServices returns the machine readable list of services, one TXT
+// record per service, for clients to discover capabilities.
+func (h *handlers) handleServices(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+	m.Answer = h.servList
+	w.WriteMsg(m)
+}
+
+func (h *handlers) handleDefault(w dns.ResponseWriter, r *dns.Msg) {
+	// $suffix-$query names don't match a suffix and are routed here.
+	if len(r.Question) > 0 {
+		if sfx, _, ok := strings.Cut(strings.ToLower(r.Question[0].Name), "-"); ok {
+			if f, ok := h.dashed[sfx]; ok {
+				f(w, r)
+				return
+			}
+		}
+	}
+
+	// If the query ends with a language code, respond in that language.
+	var lang string
+	if len(r.Question) > 0 {
+		l := dns.SplitDomainName(strings.ToLower(r.Question[0].Name))
+		if len(l) > 0 && h.i18n.Has(l[len(l)-1]) {
+			lang = l[len(l)-1]
+		}
+	}
+
+	respErr(errs.Newf(errs.User, h.i18n.T(lang, "unknown query. try: dig help @%s"), h.domain), w, newReply(r))
+}
+
+// respErr translates a service's error to lang and writes it to a DNS
+// response. User errors point to the service's help.
+func (h *handlers) respErr(suffix, lang string, err error, w dns.ResponseWriter, m *dns.Msg) {
+	err = h.tr(lang, err)
+	if errs.KindOf(err) != errs.User {
+		respErr(err, w, m)
 		return
 	}
 
-	m.Rcode = dns.RcodeServerFailure
-	m.Extra = []dns.RR{r}
+	key := suffix
+	if lang != "" {
+		key += "." + lang
+	}
+	if _, ok := h.svcHelp[key]; !ok {
+		respErr(err, w, m)
+		return
+	}
+
+	respErr(err, w, m, fmt.Sprintf(h.i18n.T(lang, "try: dig help.%s @%s"), key, h.domain))
+}
+
+// tr translates an error message to a language.
+func (h *handlers) tr(lang string, err error) error {
+	if lang == "" {
+		return err
+	}
+
+	return errs.WithMsg(err, h.i18n.T(lang, err.Error()))
+}
+
+// truncWriter is a dns.ResponseWriter that truncates UDP responses to the
+// size advertised by the client, setting the TC bit so that it retries over TCP.
+type truncWriter struct {
+	dns.ResponseWriter
+	req *dns.Msg
+}
+
+func (t *truncWriter) WriteMsg(m *dns.Msg) error {
+	if _, ok := t.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if o := t.req.IsEdns0(); o != nil {
+			size = int(o.UDPSize())
+		}
+		m.Truncate(size)
+	}
+
+	return t.ResponseWriter.WriteMsg(m)
+}
+
+// truncHandler wraps a handler to truncate oversized UDP responses.
+func truncHandler(next dns.Handler) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		next.ServeDNS(&truncWriter{ResponseWriter: w, req: r}, r)
+	})
+}
+
+// limitHandler wraps a handler to refuse queries from clients that exceed
+// their rate limit. With truncate, UDP queries instead get an empty truncated
+// response so that clients retry over TCP, which spoofed sources can't.
+func limitHandler(l *ratelimit.Limiter, truncate bool, next dns.Handler) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if l.Allow(clientIP(w)) {
+			next.ServeDNS(w, r)
+			return
+		}
+
+		m := newReply(r)
+		if _, ok := w.RemoteAddr().(*net.UDPAddr); ok && truncate {
+			m.Truncated = true
+			w.WriteMsg(m)
+			return
+		}
+
+		respErr(errs.New(errs.RateLimited, "too many queries. Try again later."), w, m)
+	})
+}
+
+// clientIP returns the IP address a query arrived from.
+func clientIP(w dns.ResponseWriter) net.IP {
+	host, _, err := net.SplitHostPort(w.RemoteAddr().String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// transport returns the name of the transport a query arrived on.
+func transport(w dns.ResponseWriter) string {
+	return w.RemoteAddr().Network()
+}
+
+// listenerAddr is a client address whose network is the transport of the
+// listener that the query arrived on (eg: tls), which can differ from the
+// network of the connection (eg: tcp).
+type listenerAddr struct {
+	net.Addr
+	network string
+}
+
+func (a listenerAddr) Network() string {
+	return a.network
+}
+
+// listenerWriter is a dns.ResponseWriter whose client address has the
+// listener's transport as its network.
+type listenerWriter struct {
+	dns.ResponseWriter
+	network string
+}
+
+func (w listenerWriter) RemoteAddr() net.Addr {
+	return listenerAddr{w.ResponseWriter.RemoteAddr(), w.network}
+}
+
+// withTransport returns a handler for a listener that reports network as
+// the transport of its queries.
+func withTransport(h dns.Handler, network string) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		h.ServeDNS(listenerWriter{w, network}, r)
+	})
+}
+
+// ednsInfo returns TXT strings describing a query's EDNS buffer size and
+// EDNS Client Subnet (ECS) option.
+func ednsInfo(r *dns.Msg) []string {
+	o := r.IsEdns0()
+	if o == nil {
+		return []string{"edns none", "ecs none"}
+	}
+
+	ecs := "none"
+	for _, opt := range o.Option {
+		if e, ok := opt.(*dns.EDNS0_SUBNET); ok {
+			ecs = fmt.Sprintf("%s/%d", e.Address, e.SourceNetmask)
+		}
+	}
+
+	return []string{fmt.Sprintf("edns %d", o.UDPSize()), "ecs " + ecs}
+}
+
+// ednsOptName returns the name of an EDNS option code.
+func ednsOptName(code uint16) string {
+	switch code {
+	case dns.EDNS0NSID:
+		return "nsid"
+	case dns.EDNS0SUBNET:
+		return "ecs"
+	case dns.EDNS0COOKIE:
+		return "cookie"
+	case dns.EDNS0TCPKEEPALIVE:
+		return "keepalive"
+	case dns.EDNS0PADDING:
+		return "padding"
+	case dns.EDNS0EXPIRE:
+		return "expire"
+	case dns.EDNS0EDE:
+		return "ede"
+	}
+
+	return fmt.Sprintf("opt%d", code)
+}
+
+// respErr writes an error message to a DNS response with the response code
+// for the kind of error. Upstream errors also carry an extended DNS error
+// (RFC 8914) if the query has EDNS. help are additional strings to add to
+// the error TXT record.
+func respErr(err error, w dns.ResponseWriter, m *dns.Msg, help ...string) {
+	switch errs.KindOf(err) {
+	case errs.User:
+		m.Rcode = dns.RcodeNameError
+	case errs.NotImplemented:
+		m.Rcode = dns.RcodeNotImplemented
+	case errs.RateLimited:
+		m.Rcode = dns.RcodeRefused
+	case errs.Upstream:
+		m.Rcode = dns.RcodeServerFailure
+		if o := m.IsEdns0(); o != nil {
+			o.Option = append(o.Option, &dns.EDNS0_EDE{
+				InfoCode:  dns.ExtendedErrorCodeNetworkError,
+				ExtraText: err.Error(),
+			})
+		}
+	default:
+		m.Rcode = dns.RcodeServerFailure
+	}
+
+	m.Extra = append(m.Extra, newTXT(".", append([]string{"error: " + err.Error()}, help...)))
 
 	w.WriteMsg(m)
 }
 
-// cleanQuery removes all non-alpha chars, and trims the service suffix
-// from the given query string.
-func cleanQuery(q, trimSuffix string) string {
-	return reClean.ReplaceAllString(strings.TrimSuffix(q, trimSuffix), "")
+// newReply returns a response to a query. If the query has EDNS, so does
+// the response.
+func newReply(r *dns.Msg) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	if o := r.IsEdns0(); o != nil {
+		m.SetEdns0(o.UDPSize(), o.Do())
+	}
+
+	return m
+}
+
+// cleanQuery validates and normalizes a query name and trims the service
+// suffix from it.
+func cleanQuery(q, trimSuffix string) (string, error) {
+	return query.Normalize(q, trimSuffix)
+}
+
+// strictQuery is cleanQuery that returns an error instead of removing
+// characters from the query.
+func strictQuery(q, trimSuffix string) (string, error) {
+	return query.NormalizeStrict(q, trimSuffix)
+}
+
+// newTXT returns a TXT record with the given strings.
+func newTXT(name string, strs []string) dns.RR {
+	return &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    defaultTTL,
+		},
+		Txt: txt.Strings(strs...),
+	}
 }
 
 // makeResp converts a []string of DNS responses to []dns.RR.
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/creds"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/i18n"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/upstream"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/providers/file"
+)
+
+type noNetwork struct{}
+
+func (noNetwork) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("no network in tests")
+}
+
+// TestHelpTranslations checks that the help description of every registered
+// service is in every language's catalog.
+func TestHelpTranslations(t *testing.T) {
+	k := koanf.New(".")
+	if err := k.Load(file.Provider("../../config.sample.toml"), toml.Parser()); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &registry.Env{
+		Domain:    "dns.toys",
+		Transport: noNetwork{},
+		Creds:     creds.New(),
+		Geo:       func() *geo.Geo { return nil },
+		Store:     func(string) upstream.Store { return nil },
+		Snapshot:  func(string, registry.Dumper) []byte { return nil },
+		AddCache:  func(string, cache.Cacher) {},
+	}
+
+	for _, e := range registry.Entries() {
+		get := func(string) registry.Service { return nil }
+		if e.NewGroup != nil {
+			g, err := e.NewGroup(k.Cut(e.Name), env)
+			if err != nil {
+				t.Fatalf("%s: %v", e.Name, err)
+			}
+			get = g.Service
+		} else {
+			s, err := e.New(k.Cut(e.Name), env)
+			if err != nil {
+				t.Fatalf("%s: %v", e.Name, err)
+			}
+			get = func(string) registry.Service { return s }
+		}
+
+		for _, sfx := range e.Suffixes {
+			desc := get(sfx).Help().Desc
+			for _, l := range tr.Langs() {
+				if tr.T(l, desc) == desc {
+					t.Errorf("%s: no %s translation for %q", sfx, l, desc)
+				}
+			}
+		}
+	}
+}
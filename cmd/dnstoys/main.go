@@ -1,24 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/knadh/dns.toys/internal/geo"
-	"github.com/knadh/dns.toys/internal/services/base"
-	"github.com/knadh/dns.toys/internal/services/cidr"
-	"github.com/knadh/dns.toys/internal/services/fx"
-	"github.com/knadh/dns.toys/internal/services/num2words"
-	"github.com/knadh/dns.toys/internal/services/timezones"
-	"github.com/knadh/dns.toys/internal/services/units"
-	"github.com/knadh/dns.toys/internal/services/weather"
+	"github.com/knadh/dns.toys/internal/analytics"
+	"github.com/knadh/dns.toys/internal/audit"
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/creds"
+	"github.com/knadh/dns.toys/internal/datasets"
+	"github.com/knadh/dns.toys/internal/fixtures"
+	"github.com/knadh/dns.toys/internal/i18n"
+	"github.com/knadh/dns.toys/internal/logging"
+	"github.com/knadh/dns.toys/internal/metrics"
+	"github.com/knadh/dns.toys/internal/ratelimit"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/resolvers"
+	"github.com/knadh/dns.toys/internal/services/cachestats"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/upstream"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/posflag"
 	"github.com/miekg/dns"
@@ -26,7 +38,6 @@ import (
 )
 
 var (
-	lo = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
 	ko = koanf.New(".")
 
 	// Version of the build injected at build time.
@@ -36,6 +47,10 @@ var (
 // Not all platforms have syscall.SIGUNUSED so use Golang's default definition here
 const SIGUNUSED = syscall.Signal(0x1f)
 
+// Time to wait for in-flight queries on shutdown if server.shutdown_timeout
+// isn't set.
+const defaultShutdownTimeout = 5 * time.Second
+
 func initConfig() {
 	// Register --help handler.
 	f := flag.NewFlagSet("config", flag.ContinueOnError)
@@ -56,17 +71,44 @@ func initConfig() {
 	// Read the config files.
 	cFiles, _ := f.GetStringSlice("config")
 	for _, f := range cFiles {
-		lo.Printf("reading config: %s", f)
+		slog.Info("reading config", "file", f)
 		if err := ko.Load(file.Provider(f), toml.Parser()); err != nil {
-			lo.Printf("error reading config: %v", err)
+			slog.Error("error reading config", "file", f, "error", err)
 		}
 	}
 
+	// Override config values with environment variables.
+	// eg: DNSTOYS_SERVER__ADDRESS=":53" => server.address
+	ko.Load(env.Provider("DNSTOYS_", ".", func(s string) string {
+		return strings.Replace(strings.ToLower(
+			strings.TrimPrefix(s, "DNSTOYS_")), "__", ".", -1)
+	}), nil)
+
 	ko.Load(posflag.Provider(f, ".", ko), nil)
 }
 
-func saveSnapshot(h *handlers) {
-	interruptSignal := make(chan os.Signal)
+// initLogger sets up structured logging with the log config. The standard
+// logger also writes through it so that all log lines have the same format.
+func initLogger() {
+	l, err := logging.New(os.Stdout, ko.String("log.format"), ko.String("log.level"))
+	if err != nil {
+		fatal("error initializing logger", "error", err)
+	}
+
+	slog.SetDefault(l)
+}
+
+// fatal logs an error with its attributes and exits.
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// handleSignals waits for OS signals to shut down the servers, flush
+// service snapshots, and exit. If there are TLS certificates, SIGHUP reloads
+// them instead. SIGUNUSED only flushes the snapshots.
+func handleSignals(h *handlers, snaps *cache.Snapshots, certs []*certReloader, stops []func(context.Context) error) {
+	interruptSignal := make(chan os.Signal, 1)
 	signal.Notify(interruptSignal,
 		syscall.SIGTERM,
 		syscall.SIGHUP,
@@ -75,39 +117,133 @@ func saveSnapshot(h *handlers) {
 		SIGUNUSED, // SIGUNUSED, can be used to avoid shutting down the app.
 	)
 
-	// On receiving an OS signal, iterate through services and
-	// dump their snapshots to the disk if available.
-	for {
-		select {
-		case i := <-interruptSignal:
-			lo.Printf("received SIGNAL: `%s`", i.String())
+	for i := range interruptSignal {
+		slog.Info("received signal", "signal", i.String())
 
-			for name, s := range h.services {
-				if !ko.Bool(name+".enabled") || !ko.Bool(name+".snapshot_enabled") {
-					continue
+		if i == syscall.SIGHUP && len(certs) > 0 {
+			for _, c := range certs {
+				if err := c.reload(); err != nil {
+					slog.Error("error reloading TLS certificate", "file", c.certFile, "error", err)
 				}
+			}
+			continue
+		}
 
-				b, err := s.Dump()
-				if err != nil {
-					lo.Printf("error generating %s snapshot: %v", name, err)
-				}
+		if i != SIGUNUSED {
+			shutdown(stops, ko.Duration("server.shutdown_timeout"))
+		}
 
-				if b == nil {
-					continue
-				}
+		for _, s := range h.creds.Stats() {
+			slog.Info("api key usage", "provider", s.Provider, "key", s.Key, "uses", s.Uses, "limited", s.Limited)
+		}
 
-				filePath := ko.MustString(name + ".snapshot_file")
-				lo.Printf("saving %s snapshot to %s", name, filePath)
-				if err := ioutil.WriteFile(filePath, b, 0644); err != nil {
-					lo.Printf("error writing weather snapshot: %v", err)
-				}
-			}
+		// Dump the services' snapshots to the disk.
+		snaps.Flush()
+
+		if i != SIGUNUSED {
+			os.Exit(0)
+		}
+	}
+}
 
-			if i != SIGUNUSED {
-				os.Exit(0)
+// shutdown stops the servers from accepting queries and waits for in-flight
+// queries to be answered for up to timeout.
+func shutdown(stops []func(context.Context) error, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Info("shutting down. Waiting for in-flight queries", "timeout", timeout)
+
+	var wg sync.WaitGroup
+	for _, stop := range stops {
+		wg.Add(1)
+		go func(stop func(context.Context) error) {
+			defer wg.Done()
+			if err := stop(ctx); err != nil {
+				slog.Error("error shutting down server", "error", err)
 			}
+		}(stop)
+	}
+	wg.Wait()
+}
+
+// initSnapshots returns the snapshots of the enabled services that have
+// snapshot_enabled. They're saved every snapshot_interval, if set, and on
+// exit.
+func initSnapshots(h *handlers) *cache.Snapshots {
+	snaps := cache.NewSnapshots(slog.Default())
+	for name, d := range h.snapshots {
+		if !ko.Bool(name+".enabled") || !ko.Bool(name+".snapshot_enabled") {
+			continue
 		}
+
+		snaps.Add(name, ko.MustString(name+".snapshot_file"), ko.Duration(name+".snapshot_interval"), d)
 	}
+
+	return snaps
+}
+
+// cacheStore returns the disk-backed cache store for a service if its
+// cache_backend is "bolt", or nil for the default in-memory cache.
+func cacheStore(service string) upstream.Store {
+	switch b := ko.String(service + ".cache_backend"); b {
+	case "", "memory":
+		return nil
+	case "bolt":
+		filePath := ko.MustString(service + ".cache_file")
+		slog.Info("using cache file", "service", service, "file", filePath)
+
+		s, err := upstream.NewBoltStore(filePath, service)
+		if err != nil {
+			fatal("error opening cache file", "service", service, "file", filePath, "error", err)
+		}
+		return s
+	default:
+		fatal("unknown cache_backend", "service", service, "cache_backend", b)
+	}
+
+	return nil
+}
+
+// upstreamTransport returns the HTTP transport for upstream API requests.
+// In offline mode, it serves canned responses from fixture files. Otherwise,
+// it's nil for services to use the network.
+func upstreamTransport() http.RoundTripper {
+	if !ko.Bool("offline.enabled") {
+		return nil
+	}
+
+	return fixtures.New(ko.MustString("offline.fixtures_dir"))
+}
+
+// tlsCert returns the TLS certificate of a listener (dot, doh) from its
+// cert_file and key_file, or nil if it's disabled or has no certificate and
+// doesn't require one. The certificate is reloaded when the files change.
+func tlsCert(name string, required bool) *certReloader {
+	if !ko.Bool(name + ".enabled") {
+		return nil
+	}
+
+	var (
+		cert = ko.String(name + ".cert_file")
+		key  = ko.String(name + ".key_file")
+	)
+	if cert == "" && key == "" && !required {
+		return nil
+	}
+	if cert == "" || key == "" {
+		fatal("listener is enabled but cert_file or key_file is not set", "listener", name)
+	}
+
+	c, err := newCertReloader(cert, key, time.Minute)
+	if err != nil {
+		fatal("error loading TLS certificate", "listener", name, "error", err)
+	}
+
+	return c
 }
 
 func loadSnapshot(service string) []byte {
@@ -117,12 +253,9 @@ func loadSnapshot(service string) []byte {
 
 	filePath := ko.MustString(service + ".snapshot_file")
 
-	b, err := ioutil.ReadFile(filePath)
+	b, err := cache.ReadSnapshot(filePath)
 	if err != nil {
-		if _, ok := err.(*os.PathError); ok {
-			return nil
-		}
-		lo.Printf("error reading snapshot file %s: %v", err)
+		slog.Error("error reading snapshot file", "file", filePath, "error", err)
 		return nil
 	}
 
@@ -131,154 +264,366 @@ func loadSnapshot(service string) []byte {
 
 func main() {
 	initConfig()
+	initLogger()
 
 	var (
 		h = &handlers{
-			services: make(map[string]Service),
-			domain:   ko.MustString("server.domain"),
+			services:  make(map[string]Service),
+			prefixed:  make(map[string][]PrefixService),
+			dashed:    make(map[string]func(w dns.ResponseWriter, r *dns.Msg)),
+			snapshots: make(map[string]Dumper),
+			help:      make(map[string][]dns.RR),
+			svcHelp:   make(map[string][]dns.RR),
+			domain:    ko.MustString("server.domain"),
+			creds:     creds.New(),
+			timeout:   ko.Duration("server.query_timeout"),
+			pop:       ko.String("server.pop"),
 		}
-		ge  *geo.Geo
 		mux = dns.NewServeMux()
 
-		help = [][]string{}
+		help = []meta{}
+
+		// Caches held by services for the `cache.stats` query.
+		caches = map[string]cache.Cacher{}
 	)
 
-	// Timezone service.
-	if ko.Bool("timezones.enabled") || ko.Bool("weather.enabled") {
-		fPath := ko.MustString("timezones.geo_filepath")
-		lo.Printf("reading geo locations from %s", fPath)
+	if h.timeout == 0 {
+		h.timeout = time.Second * 2
+	}
 
-		g, err := geo.New(fPath)
-		if err != nil {
-			lo.Fatalf("error loading geo locations: %v", err)
-		}
-		ge = g
+	// Translation catalogs.
+	tr, err := i18n.New()
+	if err != nil {
+		fatal("error loading translations", "error", err)
+	}
+	h.i18n = tr
+
+	// Periodically refreshed datasets.
+	ds := datasets.New(ko.Duration("datasets.download_timeout"))
+
+	// IP echo.
+	if ko.Bool("ip.enabled") {
+		mux.HandleFunc("ip.", h.handleEchoIP)
 
-		lo.Printf("%d geo location names loaded", g.Count())
+		help = append(help, meta{
+			Names:    []string{"ip"},
+			Desc:     "get your host's requesting IP and connection details.",
+			Syntax:   "ip",
+			Examples: []string{"dig ip @%s", "dig ip -t aaaa @%s"},
+		})
 	}
 
-	// Timezone service.
-	if ko.Bool("timezones.enabled") {
-		tz := timezones.New(timezones.Opt{}, ge)
-		h.register("time", tz, mux)
+	// Location of the node (POP) that answered.
+	if ko.Bool("fromwhere.enabled") {
+		h.node, _ = os.Hostname()
+		mux.HandleFunc("fromwhere.", h.handleFromWhere)
 
-		help = append(help, []string{"get time for a city", "dig mumbai.time @%s"})
+		help = append(help, meta{
+			Names:    []string{"fromwhere"},
+			Desc:     "get the location (POP) of the server node that answered your query.",
+			Syntax:   "fromwhere",
+			Examples: []string{"dig fromwhere @%s"},
+		})
 	}
 
-	// FX currency conversion.
-	if ko.Bool("fx.enabled") {
-		f := fx.New(fx.Opt{
-			RefreshInterval: ko.MustDuration("fx.refresh_interval"),
+	// Resolver fingerprint.
+	if ko.Bool("resolver.enabled") {
+		// The query has to arrive via a resolver, which is only possible
+		// for names under the server's (delegated) domain.
+		mux.HandleFunc("resolver.", h.handleResolver)
+		mux.HandleFunc("resolver."+h.domain+".", h.handleResolver)
+
+		help = append(help, meta{
+			Names:    []string{"resolver"},
+			Desc:     "get the public resolver network your query came through and its EDNS features.",
+			Syntax:   "resolver.$domain via a resolver",
+			Examples: []string{"dig resolver.%s", "dig resolver.%s @1.1.1.1"},
 		})
+	}
 
-		// Load snapshot?
-		if b := loadSnapshot("fx"); b != nil {
-			if err := f.Load(b); err != nil {
-				lo.Printf("error reading weather snapshot: %v", err)
-			}
-		}
+	// PI.
+	if ko.Bool("pi.enabled") {
+		mux.HandleFunc("pi.", h.handlePi)
 
-		h.register("fx", f, mux)
+		help = append(help, meta{
+			Names:    []string{"pi"},
+			Desc:     "return digits of Pi as TXT or A or AAAA record.",
+			Syntax:   "pi",
+			Examples: []string{"dig pi @%s", "dig pi -t aaaa @%s"},
+		})
+	}
 
-		help = append(help, []string{"convert currency rates", "dig 99USD-INR.fx @%s"})
+	// Services in the registry (see services.go).
+	env := &registry.Env{
+		Domain:    h.domain,
+		Transport: upstreamTransport(),
+		Creds:     h.creds,
+		Geo:       newGeoLoader(ds),
+		Store:     cacheStore,
+		Snapshot: func(section string, d registry.Dumper) []byte {
+			h.snapshots[section] = d
+			return loadSnapshot(section)
+		},
+		AddCache: func(name string, c cache.Cacher) {
+			caches[name] = c
+		},
 	}
+	help = append(help, registerServices(h, mux, env, caches)...)
 
-	// IP echo.
-	if ko.Bool("ip.enabled") {
-		mux.HandleFunc("ip.", h.handleEchoIP)
+	// Cache statistics.
+	if ko.Bool("cachestats.enabled") {
+		h.register("stats", cachestats.New(caches), mux)
 
-		help = append(help, []string{"get your host's requesting IP.", "dig ip @%s"})
+		help = append(help, meta{
+			Names:    []string{"stats"},
+			Desc:     "get entry counts, memory, hit ratios, and entry ages of service caches.",
+			Syntax:   "cache.stats",
+			Examples: []string{"dig cache.stats @%s"},
+		})
 	}
 
-	// Weather.
-	if ko.Bool("weather.enabled") {
-		w := weather.New(weather.Opt{
-			MaxEntries:       ko.MustInt("weather.max_entries"),
-			ForecastInterval: ko.MustDuration("weather.forecast_interval"),
-			CacheTTL:         ko.MustDuration("weather.cache_ttl"),
-			ReqTimeout:       time.Second * 3,
-			UserAgent:        ko.MustString("server.domain"),
-		}, ge)
+	// Prepare the static help responses for the `help` and `help.$service`
+	// queries in all languages, and the machine readable list of services
+	// for the `services` query.
+	langs := append([]string{""}, h.i18n.Langs()...)
+	for _, m := range help {
+		if m.TTL == 0 {
+			m.TTL = defaultTTL
+		}
 
-		// Load snapshot?
-		if b := loadSnapshot("weather"); b != nil {
-			if err := w.Load(b); err != nil {
-				lo.Printf("error reading weather snapshot: %v", err)
+		ex := fmt.Sprintf(m.Examples[0], h.domain)
+		upstream := "no"
+		if m.Upstream {
+			upstream = "yes"
+		}
+		for _, n := range m.Names {
+			r, err := dns.NewRR(txt.Record("services.", "name="+n, "syntax="+m.Syntax,
+				"example="+ex, "upstream="+upstream, fmt.Sprintf("ttl=%d", m.TTL)))
+			if err != nil {
+				fatal("error preparing service help", "error", err)
 			}
+			h.servList = append(h.servList, r)
 		}
 
-		h.register("weather", w, mux)
+		for _, l := range langs {
+			var (
+				desc = h.i18n.T(l, m.Desc)
+				sfx  = "."
+			)
+			if l != "" {
+				sfx = "." + l + "."
+			}
 
-		help = append(help, []string{"get weather forecast for a city.", "dig berlin.weather @%s"})
-	}
+			r, err := dns.NewRR(txt.Record("help"+sfx, desc, ex))
+			if err != nil {
+				fatal("error preparing service help", "error", err)
+			}
+			h.help[l] = append(h.help[l], r)
+
+			// Per-service help.
+			for _, n := range m.Names {
+				q := "help." + n + sfx
+				out := []string{
+					txt.Record(q, desc),
+					txt.Record(q, "syntax: "+m.Syntax),
+				}
+				for _, e := range m.Examples {
+					out = append(out, txt.Record(q, fmt.Sprintf(e, h.domain)))
+				}
 
-	// Units.
-	if ko.Bool("units.enabled") {
-		u, err := units.New()
-		if err != nil {
-			lo.Fatalf("error initializing units service: %v", err)
+				rr, err := makeResp(out)
+				if err != nil {
+					fatal("error preparing service help", "error", err)
+				}
+				key := n
+				if l != "" {
+					key += "." + l
+				}
+				// Services that share a suffix (eg: note) are listed together.
+				h.svcHelp[key] = append(h.svcHelp[key], rr...)
+				mux.HandleFunc(q, h.handleServiceHelp)
+			}
 		}
-		h.register("unit", u, mux)
-
-		help = append(help, []string{"convert between units.", "dig 42km-cm.unit @%s"})
 	}
 
-	// Numbers to words.
-	if ko.Bool("num2words.enabled") {
-		n := num2words.New()
-		h.register("words", n, mux)
+	for _, l := range h.i18n.Langs() {
+		mux.HandleFunc("help."+l+".", h.handleHelp)
+	}
+	mux.HandleFunc("help.", h.handleHelp)
+	mux.HandleFunc("services.", h.handleServices)
+	mux.HandleFunc(".", (h.handleDefault))
 
-		help = append(help, []string{"convert numbers to words.", "dig 123456.words @%s"})
+	// TLS certificates for DoT and DoH.
+	var (
+		dotCert = tlsCert("dot", true)
+		dohCert = tlsCert("doh", false)
+		certs   []*certReloader
+	)
+	for _, c := range []*certReloader{dotCert, dohCert} {
+		if c != nil {
+			certs = append(certs, c)
+		}
 	}
 
-	// CIDR.
-	if ko.Bool("cidr.enabled") {
-		n := cidr.New()
-		h.register("cidr", n, mux)
+	snaps := initSnapshots(h)
 
-		help = append(help, []string{"convert cidr to ip range.", "dig 10.100.0.0/24.cidr @%s"})
+	// Periodically refresh datasets from their upstream URLs.
+	if ko.Bool("resolver.enabled") && ko.Bool("datasets.resolvers.enabled") {
+		ds.Add(datasets.Dataset{
+			Name:     "resolvers",
+			URL:      ko.MustString("datasets.resolvers.url"),
+			Interval: ko.MustDuration("datasets.resolvers.interval"),
+			Load:     resolvers.Load,
+		})
+	}
+	if ko.Bool("offline.enabled") {
+		slog.Info("offline mode. Upstream APIs are served from fixtures", "dir", ko.MustString("offline.fixtures_dir"))
+	} else {
+		ds.Start()
 	}
 
-	// PI.
-	if ko.Bool("pi.enabled") {
-		mux.HandleFunc("pi.", h.handlePi)
+	// Keep the recent queries to services for the admin API?
+	if ko.Bool("audit.enabled") {
+		h.audit = audit.New(ko.Int("audit.size"))
+	}
 
-		help = append(help, []string{"return digits of Pi as TXT or A or AAAA record.", "dig pi @%s"})
+	// Count the top queries to services for the admin API?
+	if ko.Bool("analytics.enabled") {
+		h.analytics = analytics.New(ko.Int("analytics.size"))
 	}
 
-	// Base
-	if ko.Bool("base.enabled") {
-		n := base.New()
-		h.register("base", n, mux)
+	// Count queries, errors, and latencies for the admin API's /metrics?
+	if ko.Bool("metrics.enabled") {
+		h.metrics = metrics.New(caches)
+	}
 
-		help = append(help, []string{"convert numbers from one base to another", "dig 100dec-hex.base @%s"})
+	// Start the admin API.
+	if ko.Bool("admin.enabled") {
+		a := &admin{h: h, token: ko.String("admin.token")}
+		go func() {
+			slog.Info("admin API listening", "address", ko.MustString("admin.address"))
+			if err := a.listen(ko.MustString("admin.address")); err != nil {
+				fatal("error starting admin API", "error", err)
+			}
+		}()
 	}
 
-	// Prepare the static help response for the `help` query.
-	for _, l := range help {
-		r, err := dns.NewRR(fmt.Sprintf("help. 1 TXT \"%s\" \"%s\"", l[0], fmt.Sprintf(l[1], h.domain)))
+	// Sign answers?
+	var handler dns.Handler = mux
+	if ko.Bool("signing.enabled") {
+		s, err := newSigner(ko.String("signing.private_key"))
 		if err != nil {
-			lo.Fatalf("error preparing: %v", err)
+			fatal("error initializing signing", "error", err)
 		}
 
-		h.help = append(h.help, r)
+		mux.HandleFunc("pubkey.", s.handlePubKey)
+		mux.HandleFunc("pubkey."+h.domain+".", s.handlePubKey)
+		handler = signHandler(s, mux)
+
+		slog.Info("signing answers with ed25519", "public_key", s.pubKey())
 	}
 
-	mux.HandleFunc("help.", h.handleHelp)
-	mux.HandleFunc(".", (h.handleDefault))
+	// Limit the queries per client network?
+	if ko.Bool("ratelimit.enabled") {
+		l := ratelimit.New(ratelimit.Opt{
+			QPS:        ko.MustFloat64("ratelimit.qps"),
+			Burst:      ko.MustInt("ratelimit.burst"),
+			IPv4Prefix: ko.Int("ratelimit.ipv4_prefix"),
+			IPv6Prefix: ko.Int("ratelimit.ipv6_prefix"),
+			MaxClients: ko.Int("ratelimit.max_clients"),
+			IdleTTL:    ko.Duration("ratelimit.idle_ttl"),
+		})
+		handler = limitHandler(l, ko.String("ratelimit.action") == "truncate", handler)
+
+		if h.metrics != nil {
+			h.metrics.CountLimited(l.Limited)
+		}
+	}
 
-	// Start the snapshot listener.
-	go saveSnapshot(h)
+	// Start the servers. Large responses (eg: help, services) are truncated
+	// over UDP so that clients retry over TCP.
+	handler = truncHandler(handler)
+	var (
+		addr   = ko.MustString("server.address")
+		tcp    = &dns.Server{Addr: addr, Net: "tcp", Handler: handler}
+		server = &dns.Server{Addr: addr, Net: "udp", Handler: handler}
 
-	// Start the server.
-	server := &dns.Server{
-		Addr:    ko.MustString("server.address"),
-		Net:     "udp",
-		Handler: mux,
+		// Shut down the servers on exit.
+		stops = []func(context.Context) error{server.ShutdownContext, tcp.ShutdownContext}
+	)
+	go func() {
+		if err := tcp.ListenAndServe(); err != nil {
+			fatal("error starting TCP server", "error", err)
+		}
+	}()
+
+	// DNS-over-HTTPS.
+	if ko.Bool("doh.enabled") {
+		o := dohOpt{
+			Address:           ko.MustString("doh.address"),
+			Path:              ko.MustString("doh.path"),
+			ReadHeaderTimeout: ko.Duration("doh.read_header_timeout"),
+			ReadTimeout:       ko.Duration("doh.read_timeout"),
+			WriteTimeout:      ko.Duration("doh.write_timeout"),
+			IdleTimeout:       ko.Duration("doh.idle_timeout"),
+		}
+		if dohCert != nil {
+			o.TLS = dohCert.tlsConfig()
+		}
+		if o.ReadHeaderTimeout == 0 {
+			o.ReadHeaderTimeout = time.Second * 5
+		}
+		if o.ReadTimeout == 0 {
+			o.ReadTimeout = time.Second * 10
+		}
+		if o.WriteTimeout == 0 {
+			o.WriteTimeout = time.Second * 10
+		}
+		if o.IdleTimeout == 0 {
+			o.IdleTimeout = time.Second * 60
+		}
+		for _, c := range ko.Strings("doh.trusted_proxies") {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				fatal("invalid doh.trusted_proxies network", "network", c, "error", err)
+			}
+			o.TrustedProxies = append(o.TrustedProxies, n)
+		}
+
+		d := &doh{opt: o, handler: handler}
+		go func() {
+			slog.Info("DoH listening", "address", o.Address)
+			if err := d.listen(); err != nil {
+				fatal("error starting DoH server", "error", err)
+			}
+		}()
+		stops = append(stops, d.shutdown)
 	}
-	lo.Println("listening on ", ko.String("server.address"))
-	if err := server.ListenAndServe(); err != nil {
-		lo.Fatalf("error starting server: %v", err)
+
+	// DNS-over-TLS.
+	if dotCert != nil {
+		dot := &dns.Server{
+			Addr:      ko.MustString("dot.address"),
+			Net:       "tcp-tls",
+			TLSConfig: dotCert.tlsConfig(),
+			Handler:   withTransport(handler, "tls"),
+		}
+		go func() {
+			slog.Info("DoT listening", "address", ko.MustString("dot.address"))
+			if err := dot.ListenAndServe(); err != nil {
+				fatal("error starting DoT server", "error", err)
+			}
+		}()
+		stops = append(stops, dot.ShutdownContext)
 	}
-	defer server.Shutdown()
+
+	go func() {
+		slog.Info("listening", "address", ko.String("server.address"))
+		if err := server.ListenAndServe(); err != nil {
+			fatal("error starting server", "error", err)
+		}
+	}()
+
+	// Block until a signal shuts down the servers and exits.
+	handleSignals(h, snaps, certs, stops)
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/miekg/dns"
+
+	// Services that register themselves with the registry. To add a
+	// service, call registry.Register() in its package's init() and
+	// import it here.
+	_ "github.com/knadh/dns.toys/internal/services/acronym"
+	_ "github.com/knadh/dns.toys/internal/services/altitude"
+	_ "github.com/knadh/dns.toys/internal/services/bac"
+	_ "github.com/knadh/dns.toys/internal/services/base"
+	_ "github.com/knadh/dns.toys/internal/services/beaufort"
+	_ "github.com/knadh/dns.toys/internal/services/biorhythm"
+	_ "github.com/knadh/dns.toys/internal/services/bytedump"
+	_ "github.com/knadh/dns.toys/internal/services/calendars"
+	_ "github.com/knadh/dns.toys/internal/services/checksum"
+	_ "github.com/knadh/dns.toys/internal/services/chem"
+	_ "github.com/knadh/dns.toys/internal/services/chess"
+	_ "github.com/knadh/dns.toys/internal/services/cidr"
+	_ "github.com/knadh/dns.toys/internal/services/color"
+	_ "github.com/knadh/dns.toys/internal/services/cooking"
+	_ "github.com/knadh/dns.toys/internal/services/counter"
+	_ "github.com/knadh/dns.toys/internal/services/cssunit"
+	_ "github.com/knadh/dns.toys/internal/services/decay"
+	_ "github.com/knadh/dns.toys/internal/services/dewpoint"
+	_ "github.com/knadh/dns.toys/internal/services/dictionary"
+	_ "github.com/knadh/dns.toys/internal/services/discount"
+	_ "github.com/knadh/dns.toys/internal/services/duedate"
+	_ "github.com/knadh/dns.toys/internal/services/eightball"
+	_ "github.com/knadh/dns.toys/internal/services/electrical"
+	_ "github.com/knadh/dns.toys/internal/services/feelslike"
+	_ "github.com/knadh/dns.toys/internal/services/fx"
+	_ "github.com/knadh/dns.toys/internal/services/guess"
+	_ "github.com/knadh/dns.toys/internal/services/hangman"
+	_ "github.com/knadh/dns.toys/internal/services/life"
+	_ "github.com/knadh/dns.toys/internal/services/maze"
+	_ "github.com/knadh/dns.toys/internal/services/molar"
+	_ "github.com/knadh/dns.toys/internal/services/monitor"
+	_ "github.com/knadh/dns.toys/internal/services/morph"
+	_ "github.com/knadh/dns.toys/internal/services/music"
+	_ "github.com/knadh/dns.toys/internal/services/namegen"
+	_ "github.com/knadh/dns.toys/internal/services/note"
+	_ "github.com/knadh/dns.toys/internal/services/num2words"
+	_ "github.com/knadh/dns.toys/internal/services/paste"
+	_ "github.com/knadh/dns.toys/internal/services/petyears"
+	_ "github.com/knadh/dns.toys/internal/services/probe"
+	_ "github.com/knadh/dns.toys/internal/services/qr"
+	_ "github.com/knadh/dns.toys/internal/services/remind"
+	_ "github.com/knadh/dns.toys/internal/services/resistor"
+	_ "github.com/knadh/dns.toys/internal/services/richter"
+	_ "github.com/knadh/dns.toys/internal/services/rps"
+	_ "github.com/knadh/dns.toys/internal/services/skyevents"
+	_ "github.com/knadh/dns.toys/internal/services/split"
+	_ "github.com/knadh/dns.toys/internal/services/sudoku"
+	_ "github.com/knadh/dns.toys/internal/services/tax"
+	_ "github.com/knadh/dns.toys/internal/services/tempo"
+	_ "github.com/knadh/dns.toys/internal/services/textstats"
+	_ "github.com/knadh/dns.toys/internal/services/texttransform"
+	_ "github.com/knadh/dns.toys/internal/services/totp"
+	_ "github.com/knadh/dns.toys/internal/services/typewords"
+	_ "github.com/knadh/dns.toys/internal/services/unitprice"
+	_ "github.com/knadh/dns.toys/internal/services/units"
+	_ "github.com/knadh/dns.toys/internal/services/wordle"
+)
+
+// registerServices creates the enabled services in the registry with their
+// config sections, restores their snapshots, and registers them for their
+// suffixes. It returns their help metadata.
+func registerServices(h *handlers, mux *dns.ServeMux, env *registry.Env, caches map[string]cache.Cacher) []meta {
+	var out []meta
+	for _, e := range registry.Entries() {
+		if !ko.Bool(e.Name + ".enabled") {
+			continue
+		}
+
+		// A single service is registered for all the entry's suffixes.
+		var (
+			inst interface{}
+			get  func(string) registry.Service
+		)
+		if e.NewGroup != nil {
+			g, err := e.NewGroup(ko.Cut(e.Name), env)
+			if err != nil {
+				fatal("error initializing service", "service", e.Name, "error", err)
+			}
+			inst, get = g, g.Service
+		} else {
+			s, err := e.New(ko.Cut(e.Name), env)
+			if err != nil {
+				fatal("error initializing service", "service", e.Name, "error", err)
+			}
+			inst, get = s, func(string) registry.Service { return s }
+		}
+
+		if l, ok := inst.(registry.Loader); ok {
+			if b := loadSnapshot(e.Name); b != nil {
+				if err := l.Load(b); err != nil {
+					slog.Error("error loading snapshot", "service", e.Name, "error", err)
+				}
+			}
+		}
+		if d, ok := inst.(registry.Dumper); ok {
+			h.snapshots[e.Name] = d
+		}
+		if c, ok := inst.(cache.Cacher); ok {
+			caches[e.Name] = c
+		}
+
+		// Suffixes with the same help are listed together.
+		first := len(out)
+		for _, sfx := range e.Suffixes {
+			s := get(sfx)
+			if s == nil {
+				fatal("service has no service for a suffix", "service", e.Name, "suffix", sfx)
+			}
+			h.register(sfx, s, mux)
+
+			hp := s.Help()
+			if n := len(out) - 1; n >= first && out[n].Desc == hp.Desc {
+				out[n].Names = append(out[n].Names, sfx)
+				continue
+			}
+			out = append(out, meta{
+				Names:    []string{sfx},
+				Desc:     hp.Desc,
+				Syntax:   hp.Syntax,
+				Examples: hp.Examples,
+				Upstream: hp.Upstream,
+			})
+		}
+	}
+
+	return out
+}
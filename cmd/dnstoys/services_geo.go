@@ -0,0 +1,66 @@
+//go:build !nogeo
+// +build !nogeo
+
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/knadh/dns.toys/internal/datasets"
+	"github.com/knadh/dns.toys/internal/geo"
+
+	// Services that depend on the geonames.org locations. They can be
+	// excluded from the build with the `nogeo` build tag for a slim binary
+	// that doesn't need the locations file.
+	_ "github.com/knadh/dns.toys/internal/services/distance"
+	_ "github.com/knadh/dns.toys/internal/services/geocode"
+	_ "github.com/knadh/dns.toys/internal/services/nearcity"
+	_ "github.com/knadh/dns.toys/internal/services/prayer"
+	_ "github.com/knadh/dns.toys/internal/services/schedule"
+	_ "github.com/knadh/dns.toys/internal/services/sunpos"
+	_ "github.com/knadh/dns.toys/internal/services/timezones"
+	_ "github.com/knadh/dns.toys/internal/services/weather"
+)
+
+// newGeoLoader returns a function that loads the geo locations the first
+// time a service asks for them, so that they're only read if a service that
+// depends on them is enabled. The locations are refreshed with the datasets.
+func newGeoLoader(ds *datasets.Refresher) func() *geo.Geo {
+	var (
+		once sync.Once
+		ge   *geo.Geo
+	)
+
+	return func() *geo.Geo {
+		once.Do(func() {
+			fPath := ko.MustString("timezones.geo_filepath")
+			slog.Info("reading geo locations", "file", fPath)
+
+			g, err := geo.New(fPath)
+			if err != nil {
+				fatal("error loading geo locations", "error", err)
+			}
+			ge = g
+
+			slog.Info("geo location names loaded", "count", g.Count())
+
+			if ko.Bool("datasets.geo.enabled") {
+				ds.Add(datasets.Dataset{
+					Name:     "geo",
+					URL:      ko.MustString("datasets.geo.url"),
+					Interval: ko.MustDuration("datasets.geo.interval"),
+					Load: func(b []byte) error {
+						if err := ge.Load(b); err != nil {
+							return err
+						}
+						slog.Info("geo location names loaded", "count", ge.Count())
+						return nil
+					},
+				})
+			}
+		})
+
+		return ge
+	}
+}
@@ -0,0 +1,23 @@
+//go:build nogeo
+// +build nogeo
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/knadh/dns.toys/internal/datasets"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// newGeoLoader warns about the enabled services that depend on the
+// geonames.org locations, which builds with the `nogeo` tag exclude.
+func newGeoLoader(ds *datasets.Refresher) func() *geo.Geo {
+	for _, s := range []string{"timezones", "weather", "distance", "geo", "nearcity", "sunpos", "schedule", "prayer"} {
+		if ko.Bool(s + ".enabled") {
+			slog.Warn("service is enabled but this build excludes geo services (nogeo)", "service", s)
+		}
+	}
+
+	return func() *geo.Geo { return nil }
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// First string of the signature record that marks it apart from answers.
+const sigTag = "ed25519-sig"
+
+// signer appends Ed25519 signatures over answers so that cached or proxied
+// responses can be verified with the public key served at `pubkey`.
+//
+// The signature is a TXT record owned by the question name (so that it's in
+// the same RRset as answers at the qname and isn't stripped by resolvers)
+// with the strings "ed25519-sig" and the base64 (standard, padded) signature.
+//
+// The signed bytes are the UTF-8 text of the lowercased, fully qualified
+// question name followed by a newline and the answer records, excluding the
+// signature, one per line. Each record is in miekg/dns presentation format
+// (RR.String()), eg: "mumbai.\t0\tIN\tTXT\t\"a\" \"b\"", with its TTL set to
+// 0 and its owner name lowercased. The lines are sorted bytewise and joined
+// with "\n" without a trailing newline.
+type signer struct {
+	key ed25519.PrivateKey
+}
+
+// newSigner returns a signer for a base64 encoded 32 byte Ed25519 seed.
+func newSigner(seed string) (*signer, error) {
+	b, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(b) != ed25519.SeedSize {
+		return nil, errors.New("private key should be a base64 encoded 32 byte seed")
+	}
+
+	return &signer{key: ed25519.NewKeyFromSeed(b)}, nil
+}
+
+// pubKey returns the base64 encoded public key.
+func (s *signer) pubKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// sign appends a signature record to the answers in a message.
+func (s *signer) sign(m *dns.Msg) {
+	if len(m.Answer) == 0 || len(m.Question) == 0 || m.Rcode != dns.RcodeSuccess {
+		return
+	}
+
+	// Already signed?
+	for _, rr := range m.Answer {
+		if isSig(rr) {
+			return
+		}
+	}
+
+	qname := strings.ToLower(dns.Fqdn(m.Question[0].Name))
+	sig := ed25519.Sign(s.key, signPayload(qname, m.Answer))
+	m.Answer = append(m.Answer, newTXT(m.Question[0].Name,
+		[]string{sigTag, base64.StdEncoding.EncodeToString(sig)}))
+}
+
+// signPayload returns the canonical bytes of a question name and its
+// answer records that are signed.
+func signPayload(qname string, rrs []dns.RR) []byte {
+	lines := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		if isSig(rr) {
+			continue
+		}
+
+		c := dns.Copy(rr)
+		c.Header().Ttl = 0
+		c.Header().Name = strings.ToLower(c.Header().Name)
+		lines = append(lines, c.String())
+	}
+	sort.Strings(lines)
+
+	return []byte(qname + "\n" + strings.Join(lines, "\n"))
+}
+
+// isSig returns true if a record is a signature record.
+func isSig(rr dns.RR) bool {
+	t, ok := rr.(*dns.TXT)
+	return ok && len(t.Txt) == 2 && t.Txt[0] == sigTag
+}
+
+// handlePubKey returns the public key that answers are signed with.
+func (s *signer) handlePubKey(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	for _, q := range m.Question {
+		if q.Qtype != dns.TypeTXT {
+			continue
+		}
+		m.Answer = append(m.Answer, newTXT(q.Name, []string{"ed25519", s.pubKey()}))
+	}
+
+	w.WriteMsg(m)
+}
+
+// signWriter is a dns.ResponseWriter that signs responses.
+type signWriter struct {
+	dns.ResponseWriter
+	s *signer
+}
+
+func (w *signWriter) WriteMsg(m *dns.Msg) error {
+	w.s.sign(m)
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// signHandler wraps a handler to sign its responses.
+func signHandler(s *signer, next dns.Handler) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		next.ServeDNS(&signWriter{ResponseWriter: w, s: s}, r)
+	})
+}
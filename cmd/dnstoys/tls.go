@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader holds a TLS certificate that is reloaded from its files
+// when they change (eg: renewed by Let's Encrypt) or on SIGHUP, so that
+// TLS listeners don't have to be restarted.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader loads a certificate and key pair and checks the files for
+// changes every interval.
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if c.changed() {
+				if err := c.reload(); err != nil {
+					slog.Error("error reloading TLS certificate", "file", c.certFile, "error", err)
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// reload loads the certificate from its files. The old certificate is
+// retained if the new one can't be loaded.
+func (c *certReloader) reload() error {
+	mod := c.lastModified()
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = mod
+	c.mu.Unlock()
+
+	slog.Info("loaded TLS certificate", "file", c.certFile)
+	return nil
+}
+
+// changed checks whether the certificate or key files have been modified
+// since they were loaded.
+func (c *certReloader) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lastModified().After(c.modTime)
+}
+
+// lastModified returns the latest modification time of the files.
+func (c *certReloader) lastModified() time.Time {
+	var t time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		if st, err := os.Stat(f); err == nil && st.ModTime().After(t) {
+			t = st.ModTime()
+		}
+	}
+
+	return t
+}
+
+// getCertificate returns the current certificate for tls.Config.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// tlsConfig returns a TLS config that uses the current certificate.
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.getCertificate,
+	}
+}
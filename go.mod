@@ -1,11 +1,13 @@
 module github.com/knadh/dns.toys
 
-go 1.17
+go 1.21
 
 require (
 	github.com/knadh/koanf v1.4.1
 	github.com/miekg/dns v1.1.49
 	github.com/spf13/pflag v1.0.5
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/net v0.0.0-20210726213435-c6fcb2dbf985
 	golang.org/x/time v0.0.0-20220411224347-583f2d630306
 )
 
@@ -16,8 +18,8 @@ require (
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
 	github.com/pelletier/go-toml v1.7.0 // indirect
 	golang.org/x/mod v0.4.2 // indirect
-	golang.org/x/net v0.0.0-20210726213435-c6fcb2dbf985 // indirect
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/tools v0.1.6-0.20210726203631-07bc1bf47fb2 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
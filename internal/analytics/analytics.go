@@ -0,0 +1,207 @@
+// Package analytics tracks the most popular queries to each service over the
+// last hour and day in bounded memory using the Space-Saving heavy hitters
+// algorithm. Counts are approximate but the top queries are reliable.
+package analytics
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Queries are counted in buckets of this duration and the last
+	// numBuckets buckets (a day) are kept.
+	bucketSize = time.Minute * 10
+	numBuckets = int(24 * time.Hour / bucketSize)
+)
+
+// Item is a query and its approximate count.
+type Item struct {
+	Query string `json:"query"`
+	Count uint64 `json:"count"`
+}
+
+// Analytics tracks the top queries to services.
+type Analytics struct {
+	// Max distinct queries tracked per service per bucket.
+	size int
+
+	services map[string]*series
+	mut      sync.RWMutex
+}
+
+// series is a ring of time buckets of a service's query counts.
+type series struct {
+	buckets [numBuckets]bucket
+	mut     sync.Mutex
+}
+
+// bucket holds the query counts of a time bucket in a min-heap so that
+// the least counted query can be replaced in O(log size).
+type bucket struct {
+	start  time.Time
+	counts counts
+	index  map[string]*count
+}
+
+type count struct {
+	query string
+	n     uint64
+
+	// Position in the heap.
+	pos int
+}
+
+// counts is a min-heap of query counts (container/heap.Interface).
+type counts []*count
+
+func (c counts) Len() int           { return len(c) }
+func (c counts) Less(i, j int) bool { return c[i].n < c[j].n }
+
+func (c counts) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+	c[i].pos = i
+	c[j].pos = j
+}
+
+func (c *counts) Push(x interface{}) {
+	it := x.(*count)
+	it.pos = len(*c)
+	*c = append(*c, it)
+}
+
+func (c *counts) Pop() interface{} {
+	old := *c
+	it := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return it
+}
+
+// New returns a new instance of Analytics that tracks up to size distinct
+// queries per service in each time bucket.
+func New(size int) *Analytics {
+	if size < 1 {
+		size = 100
+	}
+
+	return &Analytics{
+		size:     size,
+		services: make(map[string]*series),
+	}
+}
+
+// Add counts a query to a service.
+func (a *Analytics) Add(service, query string) {
+	now := time.Now().Truncate(bucketSize)
+	query = strings.ToLower(query)
+
+	s := a.series(service)
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	b := &s.buckets[int(now.Unix()/int64(bucketSize/time.Second))%numBuckets]
+	if !b.start.Equal(now) {
+		b.start = now
+		b.counts = make(counts, 0, a.size)
+		b.index = make(map[string]*count, a.size)
+	}
+
+	if c, ok := b.index[query]; ok {
+		c.n++
+		heap.Fix(&b.counts, c.pos)
+		return
+	}
+
+	if len(b.counts) < a.size {
+		c := &count{query: query, n: 1}
+		heap.Push(&b.counts, c)
+		b.index[query] = c
+		return
+	}
+
+	// Space-Saving: replace the least counted query and inherit its count.
+	c := b.counts[0]
+	delete(b.index, c.query)
+	c.query = query
+	c.n++
+	b.index[query] = c
+	heap.Fix(&b.counts, 0)
+}
+
+// series returns the series of a service, creating it if it's new.
+func (a *Analytics) series(service string) *series {
+	a.mut.RLock()
+	s, ok := a.services[service]
+	a.mut.RUnlock()
+	if ok {
+		return s
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if s, ok := a.services[service]; ok {
+		return s
+	}
+	s = &series{}
+	a.services[service] = s
+
+	return s
+}
+
+// Top returns the n most popular queries to a service over the given
+// period (up to 24 hours).
+func (a *Analytics) Top(service string, period time.Duration, n int) []Item {
+	since := time.Now().Truncate(bucketSize).Add(-period + bucketSize)
+	totals := map[string]uint64{}
+
+	a.mut.RLock()
+	s, ok := a.services[service]
+	a.mut.RUnlock()
+
+	if ok {
+		s.mut.Lock()
+		for _, b := range s.buckets {
+			if b.start.Before(since) {
+				continue
+			}
+			for _, c := range b.counts {
+				totals[c.query] += c.n
+			}
+		}
+		s.mut.Unlock()
+	}
+
+	out := make([]Item, 0, len(totals))
+	for q, c := range totals {
+		out = append(out, Item{Query: q, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count == out[j].Count {
+			return out[i].Query < out[j].Query
+		}
+		return out[i].Count > out[j].Count
+	})
+
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// Services returns the names of the services that have been queried.
+func (a *Analytics) Services() []string {
+	a.mut.RLock()
+	defer a.mut.RUnlock()
+
+	out := make([]string, 0, len(a.services))
+	for s := range a.services {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+
+	return out
+}
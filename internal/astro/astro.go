@@ -0,0 +1,160 @@
+// Package astro implements approximate astronomical calculations for the
+// position of the sun based on the NOAA solar calculator formulas.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// SunPos represents the position of the sun for an observer.
+type SunPos struct {
+	// Elevation above the horizon in degrees (without refraction correction).
+	Elevation float64
+
+	// Azimuth in degrees clockwise from the north.
+	Azimuth float64
+
+	// Solar declination in degrees.
+	Declination float64
+
+	// Equation of time in minutes.
+	EqTime float64
+}
+
+// SunPosition returns the position of the sun at the given time for
+// an observer at lat, lon.
+func SunPosition(t time.Time, lat, lon float64) SunPos {
+	t = t.UTC()
+	decl, eqTime := solarParams(t)
+
+	// True solar time in minutes.
+	mins := float64(t.Hour()*60+t.Minute()) + float64(t.Second())/60
+	tst := math.Mod(mins+eqTime+4*lon, 1440)
+	if tst < 0 {
+		tst += 1440
+	}
+
+	// Hour angle.
+	ha := tst/4 - 180
+	if tst/4 < 0 {
+		ha = tst/4 + 180
+	}
+
+	var (
+		latR  = rad(lat)
+		declR = rad(decl)
+	)
+
+	cosZen := math.Sin(latR)*math.Sin(declR) + math.Cos(latR)*math.Cos(declR)*math.Cos(rad(ha))
+	zen := math.Acos(clamp(cosZen))
+
+	// Azimuth.
+	var az float64
+	if d := math.Cos(latR) * math.Sin(zen); d != 0 {
+		a := deg(math.Acos(clamp((math.Sin(latR)*math.Cos(zen) - math.Sin(declR)) / d)))
+		if ha > 0 {
+			az = math.Mod(a+180, 360)
+		} else {
+			az = math.Mod(540-a, 360)
+		}
+	}
+
+	return SunPos{
+		Elevation:   90 - deg(zen),
+		Azimuth:     az,
+		Declination: decl,
+		EqTime:      eqTime,
+	}
+}
+
+// SolarNoon returns the time of the solar noon on the calendar date of t
+// (in t's location) for the given longitude.
+func SolarNoon(t time.Time, lon float64) time.Time {
+	var (
+		y, m, d = t.Date()
+		day     = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	)
+
+	_, eqTime := solarParams(day.Add(time.Hour * 12))
+	mins := 720 - 4*lon - eqTime
+
+	return day.Add(time.Duration(mins * float64(time.Minute)))
+}
+
+// ElevationTimes returns the times on the calendar date of t (in t's
+// location) when the sun is at the given elevation (degrees) before and
+// after the solar noon for an observer at lat, lon. It returns zero times and
+// false if the sun doesn't reach the elevation on that day (eg: polar days
+// and nights).
+func ElevationTimes(t time.Time, lat, lon, elevation float64) (time.Time, time.Time, bool) {
+	noon := SolarNoon(t, lon)
+	decl, _ := solarParams(noon)
+
+	var (
+		latR  = rad(lat)
+		declR = rad(decl)
+	)
+
+	cosHA := (math.Sin(rad(elevation)) - math.Sin(latR)*math.Sin(declR)) / (math.Cos(latR) * math.Cos(declR))
+	if cosHA < -1 || cosHA > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	// The hour angle in degrees is 4 minutes of time per degree.
+	d := time.Duration(deg(math.Acos(cosHA)) * 4 * float64(time.Minute))
+	return noon.Add(-d), noon.Add(d), true
+}
+
+// julianDay returns the Julian day number for the given time.
+func julianDay(t time.Time) float64 {
+	return float64(t.UTC().UnixNano())/float64(time.Hour*24) + 2440587.5
+}
+
+// solarParams returns the solar declination (degrees) and the
+// equation of time (minutes) for the given time.
+func solarParams(t time.Time) (float64, float64) {
+	// Julian century.
+	jc := (julianDay(t) - 2451545) / 36525
+
+	var (
+		meanLong = math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+		meanAnom = 357.52911 + jc*(35999.05029-0.0001537*jc)
+		ecc      = 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	)
+
+	eqCtr := math.Sin(rad(meanAnom))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(rad(2*meanAnom))*(0.019993-0.000101*jc) +
+		math.Sin(rad(3*meanAnom))*0.000289
+
+	var (
+		trueLong = meanLong + eqCtr
+		appLong  = trueLong - 0.00569 - 0.00478*math.Sin(rad(125.04-1934.136*jc))
+
+		meanObliq = 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+		obliq     = meanObliq + 0.00256*math.Cos(rad(125.04-1934.136*jc))
+	)
+
+	decl := deg(math.Asin(math.Sin(rad(obliq)) * math.Sin(rad(appLong))))
+
+	y := math.Pow(math.Tan(rad(obliq/2)), 2)
+	eqTime := 4 * deg(y*math.Sin(2*rad(meanLong))-
+		2*ecc*math.Sin(rad(meanAnom))+
+		4*ecc*y*math.Sin(rad(meanAnom))*math.Cos(2*rad(meanLong))-
+		0.5*y*y*math.Sin(4*rad(meanLong))-
+		1.25*ecc*ecc*math.Sin(2*rad(meanAnom)))
+
+	return decl, eqTime
+}
+
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,118 @@
+// Package audit keeps the last N queries to each service in memory, with the
+// client addresses anonymized, so that operators can see live traffic
+// patterns without full query logging.
+package audit
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a logged query.
+type Entry struct {
+	Time time.Time `json:"time"`
+
+	// Client is the anonymized client network. eg: 203.0.113.0/24
+	Client string `json:"client"`
+	Query  string `json:"query"`
+	Type   string `json:"type"`
+}
+
+// Log is a set of fixed size ring buffers of queries, one per service.
+type Log struct {
+	size  int
+	rings map[string]*ring
+	mut   sync.Mutex
+}
+
+type ring struct {
+	entries []Entry
+	next    int
+}
+
+// New returns a new Log that keeps the last size queries to each service.
+func New(size int) *Log {
+	if size < 1 {
+		size = 100
+	}
+
+	return &Log{
+		size:  size,
+		rings: make(map[string]*ring),
+	}
+}
+
+// Add logs a query to a service. The client IP is anonymized.
+func (l *Log) Add(service, query, qtype string, client net.IP) {
+	e := Entry{
+		Time:   time.Now(),
+		Client: Anonymize(client),
+		Query:  query,
+		Type:   qtype,
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	r, ok := l.rings[service]
+	if !ok {
+		r = &ring{entries: make([]Entry, 0, l.size)}
+		l.rings[service] = r
+	}
+
+	if len(r.entries) < l.size {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % l.size
+}
+
+// Recent returns the logged queries to a service, newest first.
+func (l *Log) Recent(service string) []Entry {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	r, ok := l.rings[service]
+	if !ok {
+		return []Entry{}
+	}
+
+	out := make([]Entry, 0, len(r.entries))
+	for i := 0; i < len(r.entries); i++ {
+		n := (r.next - 1 - i + 2*len(r.entries)) % len(r.entries)
+		out = append(out, r.entries[n])
+	}
+
+	return out
+}
+
+// Services returns the names of the services that have logged queries.
+func (l *Log) Services() []string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	out := make([]string, 0, len(l.rings))
+	for s := range l.rings {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Anonymize returns the network of an IP with the host bits zeroed,
+// /24 for IPv4 and /48 for IPv6.
+func Anonymize(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -0,0 +1,40 @@
+// Package cache has common types for introspecting the in-memory caches
+// held by services.
+package cache
+
+import "time"
+
+// Stats are the statistics of a cache.
+type Stats struct {
+	Entries int
+
+	// Bytes is an estimate of the memory used by the entries.
+	// -1 if it can't be estimated.
+	Bytes int64
+
+	// Hits and Misses are the number of lookups that were (not)
+	// served from the cache.
+	Hits   uint64
+	Misses uint64
+
+	// Time the oldest and newest entries were added. Zero if
+	// there are no entries.
+	Oldest time.Time
+	Newest time.Time
+}
+
+// Cacher is implemented by services and stores that hold caches.
+type Cacher interface {
+	CacheStats() Stats
+}
+
+// HitRatio returns the percentage of lookups that were served from the
+// cache or -1 if there have been no lookups.
+func (s Stats) HitRatio() float64 {
+	n := s.Hits + s.Misses
+	if n == 0 {
+		return -1
+	}
+
+	return float64(s.Hits) / float64(n) * 100
+}
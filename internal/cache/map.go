@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Map is an in-memory map of values that expire after a TTL, for services
+// to embed as their cache. Expired values are not returned and are removed
+// periodically. Services that persist it with Dump() should gob.Register()
+// the types of their values.
+type Map struct {
+	mu    sync.RWMutex
+	items map[string]Item
+	ttl   time.Duration
+	max   int
+
+	// Approximate memory used by the items, updated as they're set and
+	// removed so that stats don't have to walk or encode the Map.
+	bytes int64
+
+	hits   uint64
+	misses uint64
+}
+
+// Approximate memory used by an Item and its map entry, excluding the
+// key and value.
+const itemOverhead = 96
+
+// Sizer is implemented by values that know their approximate size in bytes
+// for the Map's memory estimate. Other values are counted as the item
+// overhead only.
+type Sizer interface {
+	Size() int
+}
+
+// Item is a value in a Map.
+type Item struct {
+	Val     interface{}
+	Added   time.Time
+	Expires time.Time
+}
+
+// NewMap returns a Map where values set with Set() expire after ttl. If max
+// is > 0, an arbitrary value is evicted to set a new one once there are max
+// values.
+func NewMap(ttl time.Duration, max int) *Map {
+	m := &Map{
+		items: make(map[string]Item),
+		ttl:   ttl,
+		max:   max,
+	}
+
+	// Remove expired values.
+	interval := time.Minute
+	if ttl > 0 && ttl < interval {
+		interval = ttl
+	}
+	go func() {
+		for range time.Tick(interval) {
+			m.sweep()
+		}
+	}()
+
+	return m
+}
+
+// Get returns the value for a key if it hasn't expired.
+func (m *Map) Get(key string) (interface{}, bool) {
+	m.mu.RLock()
+	it, ok := m.items[key]
+	m.mu.RUnlock()
+
+	if !ok || time.Now().After(it.Expires) {
+		atomic.AddUint64(&m.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddUint64(&m.hits, 1)
+	return it.Val, true
+}
+
+// Set sets the value for a key with the Map's TTL.
+func (m *Map) Set(key string, val interface{}) {
+	m.SetTTL(key, val, m.ttl)
+}
+
+// SetTTL sets the value for a key that expires after ttl. Values with a
+// ttl <= 0 are not set and the existing one, if any, is removed.
+func (m *Map) SetTTL(key string, val interface{}, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ttl <= 0 {
+		m.delete(key)
+		return
+	}
+
+	now := time.Now()
+	m.set(key, Item{Val: val, Added: now, Expires: now.Add(ttl)})
+}
+
+// set sets an item, evicting an arbitrary one if the Map is full.
+// m.mu should be locked.
+func (m *Map) set(key string, it Item) {
+	if old, ok := m.items[key]; ok {
+		m.bytes -= size(key, old)
+	} else if m.max > 0 && len(m.items) >= m.max {
+		for k := range m.items {
+			m.delete(k)
+			break
+		}
+	}
+
+	m.items[key] = it
+	m.bytes += size(key, it)
+}
+
+// Delete removes the value for a key.
+func (m *Map) Delete(key string) {
+	m.mu.Lock()
+	m.delete(key)
+	m.mu.Unlock()
+}
+
+// delete removes the value for a key. m.mu should be locked.
+func (m *Map) delete(key string) {
+	if it, ok := m.items[key]; ok {
+		m.bytes -= size(key, it)
+		delete(m.items, key)
+	}
+}
+
+// Range calls fn for every value that hasn't expired until fn returns false.
+// The Map is read locked while fn is called.
+func (m *Map) Range(fn func(key string, it Item) bool) {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for k, it := range m.items {
+		if now.After(it.Expires) {
+			continue
+		}
+		if !fn(k, it) {
+			return
+		}
+	}
+}
+
+// Len returns the number of values in the Map, including expired ones
+// that are yet to be removed.
+func (m *Map) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.items)
+}
+
+// CacheStats returns the statistics of the Map.
+func (m *Map) CacheStats() Stats {
+	hits := atomic.LoadUint64(&m.hits)
+	st := Stats{
+		Entries: m.Len(),
+		Hits:    hits,
+		Misses:  atomic.LoadUint64(&m.misses),
+	}
+
+	m.Range(func(_ string, it Item) bool {
+		if st.Oldest.IsZero() || it.Added.Before(st.Oldest) {
+			st.Oldest = it.Added
+		}
+		if it.Added.After(st.Newest) {
+			st.Newest = it.Added
+		}
+		return true
+	})
+
+	m.mu.RLock()
+	st.Bytes = m.bytes
+	m.mu.RUnlock()
+
+	return st
+}
+
+// Dump produces a gob dump of the values in the Map.
+func (m *Map) Dump() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := gob.NewEncoder(buf).Encode(m.items); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load loads a gob dump of values into the Map. Values that have expired
+// since the dump are skipped.
+func (m *Map) Load(b []byte) error {
+	var items map[string]Item
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&items); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, it := range items {
+		if now.After(it.Expires) {
+			continue
+		}
+		m.set(k, it)
+	}
+
+	return nil
+}
+
+// sweep removes expired values.
+func (m *Map) sweep() {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, it := range m.items {
+		if now.After(it.Expires) {
+			m.delete(k)
+		}
+	}
+}
+
+// size returns the approximate memory used by an item.
+func size(key string, it Item) int64 {
+	n := len(key) + itemOverhead
+	if s, ok := it.Val.(Sizer); ok {
+		n += s.Size()
+	}
+
+	return int64(n)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Dumper is implemented by caches whose contents can be saved to a
+// snapshot and restored with a corresponding Load([]byte) error.
+type Dumper interface {
+	Dump() ([]byte, error)
+}
+
+// Snapshots saves the contents of caches to snapshot files periodically
+// and on Flush() (eg: on shutdown) so that they survive restarts.
+type Snapshots struct {
+	mu   sync.Mutex
+	list []*snapshot
+	log  *slog.Logger
+}
+
+type snapshot struct {
+	name string
+	path string
+	d    Dumper
+
+	// Serializes writes of the file by the ticker and Flush().
+	mu sync.Mutex
+}
+
+// NewSnapshots returns a new instance of Snapshots that logs to lo.
+func NewSnapshots(lo *slog.Logger) *Snapshots {
+	return &Snapshots{log: lo}
+}
+
+// Add adds a cache that's saved to path every interval and on Flush().
+// An interval of 0 only saves it on Flush().
+func (s *Snapshots) Add(name, path string, interval time.Duration, d Dumper) {
+	sn := &snapshot{name: name, path: path, d: d}
+
+	s.mu.Lock()
+	s.list = append(s.list, sn)
+	s.mu.Unlock()
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if err := sn.save(); err != nil {
+				s.log.Error("error saving snapshot", "snapshot", name, "error", err)
+			}
+		}
+	}()
+}
+
+// Flush saves all the caches.
+func (s *Snapshots) Flush() {
+	s.mu.Lock()
+	list := s.list
+	s.mu.Unlock()
+
+	for _, sn := range list {
+		s.log.Info("saving snapshot", "snapshot", sn.name, "file", sn.path)
+		if err := sn.save(); err != nil {
+			s.log.Error("error saving snapshot", "snapshot", sn.name, "error", err)
+		}
+	}
+}
+
+// save writes the cache's dump to a temporary file that's renamed to the
+// snapshot file so that a crash midway doesn't leave a partial snapshot.
+// Caches that dump nothing (eg: persisted elsewhere) are skipped.
+func (sn *snapshot) save() error {
+	b, err := sn.d.Dump()
+	if err != nil || b == nil {
+		return err
+	}
+
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	tmp := sn.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sn.path)
+}
+
+// ReadSnapshot returns the contents of a snapshot file, or nil if it
+// doesn't exist.
+func ReadSnapshot(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
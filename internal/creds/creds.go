@@ -0,0 +1,188 @@
+// Package creds manages API keys for upstream providers. A provider can have
+// multiple keys that are rotated when one gets rate limited by the upstream.
+package creds
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Manager holds key pools for named providers (eg: fx).
+type Manager struct {
+	pools map[string]*Pool
+	mut   sync.RWMutex
+}
+
+// Pool is a set of API keys for a single provider.
+type Pool struct {
+	keys []*key
+	cur  int
+	mut  sync.Mutex
+}
+
+type key struct {
+	val     string
+	uses    uint64
+	limited uint64
+}
+
+// Stat represents the usage counters of a single key. The key itself
+// is masked.
+type Stat struct {
+	Provider string `json:"provider"`
+	Key      string `json:"key"`
+	Uses     uint64 `json:"uses"`
+	Limited  uint64 `json:"limited"`
+}
+
+// New returns a new instance of the credentials Manager.
+func New() *Manager {
+	return &Manager{
+		pools: make(map[string]*Pool),
+	}
+}
+
+// Add registers one or more keys for a provider. Empty keys are ignored.
+func (m *Manager) Add(provider string, keys ...string) *Pool {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	p, ok := m.pools[provider]
+	if !ok {
+		p = &Pool{}
+		m.pools[provider] = p
+	}
+
+	p.mut.Lock()
+	for _, k := range keys {
+		if k != "" {
+			p.keys = append(p.keys, &key{val: k})
+		}
+	}
+	p.mut.Unlock()
+
+	return p
+}
+
+// Pool returns the key pool for a provider. It returns nil if the
+// provider has no keys.
+func (m *Manager) Pool(provider string) *Pool {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	p, ok := m.pools[provider]
+	if !ok || p.Len() == 0 {
+		return nil
+	}
+	return p
+}
+
+// Stats returns the usage counters of all keys of all providers.
+func (m *Manager) Stats() []Stat {
+	m.mut.RLock()
+	names := make([]string, 0, len(m.pools))
+	for n := range m.pools {
+		names = append(names, n)
+	}
+	m.mut.RUnlock()
+	sort.Strings(names)
+
+	var out []Stat
+	for _, n := range names {
+		for _, s := range m.pools[n].Stats() {
+			s.Provider = n
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Len returns the number of keys in the pool.
+func (p *Pool) Len() int {
+	if p == nil {
+		return 0
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return len(p.keys)
+}
+
+// Get returns the current key and increments its usage counter.
+// It returns an empty string if the pool is empty.
+func (p *Pool) Get() string {
+	if p == nil {
+		return ""
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if len(p.keys) == 0 {
+		return ""
+	}
+
+	k := p.keys[p.cur]
+	atomic.AddUint64(&k.uses, 1)
+	return k.val
+}
+
+// Limited marks a key as having been rate limited (eg: HTTP 429) by the
+// upstream and rotates to the next key. It returns false if there is no
+// other key to rotate to.
+func (p *Pool) Limited(val string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if len(p.keys) == 0 {
+		return false
+	}
+
+	for _, k := range p.keys {
+		if k.val == val {
+			atomic.AddUint64(&k.limited, 1)
+			break
+		}
+	}
+
+	// Only rotate if the limited key is still the current one. Another
+	// request may have rotated it already.
+	if p.keys[p.cur].val == val {
+		p.cur = (p.cur + 1) % len(p.keys)
+	}
+
+	return len(p.keys) > 1
+}
+
+// Stats returns the usage counters of the keys in the pool.
+func (p *Pool) Stats() []Stat {
+	if p == nil {
+		return nil
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	out := make([]Stat, 0, len(p.keys))
+	for _, k := range p.keys {
+		out = append(out, Stat{
+			Key:     mask(k.val),
+			Uses:    atomic.LoadUint64(&k.uses),
+			Limited: atomic.LoadUint64(&k.limited),
+		})
+	}
+	return out
+}
+
+// mask hides all but the last four characters of a key.
+func mask(s string) string {
+	if len(s) <= 4 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
@@ -0,0 +1,153 @@
+// Package datasets periodically re-downloads the datasets that services are
+// loaded with (eg: geo locations) from configured URLs and hands them to the
+// services to validate and swap in without a restart.
+package datasets
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Max size of a downloaded (and decompressed) dataset.
+const maxSize = 256 << 20
+
+// LoadFunc parses and validates a downloaded dataset and atomically
+// replaces the data in use. On error, the existing data should be retained.
+type LoadFunc func(b []byte) error
+
+// Dataset is a dataset that is refreshed periodically.
+type Dataset struct {
+	Name string
+
+	// URL to download the dataset from. Datasets ending in .zip (the first
+	// file in the archive is used) and .gz are decompressed.
+	URL      string
+	Interval time.Duration
+
+	Load LoadFunc
+}
+
+// Refresher refreshes datasets in the background.
+type Refresher struct {
+	client *http.Client
+	sets   []Dataset
+}
+
+// New returns a new Refresher that downloads datasets with the given timeout.
+func New(timeout time.Duration) *Refresher {
+	if timeout == 0 {
+		timeout = time.Minute * 5
+	}
+
+	return &Refresher{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Add adds a dataset to be refreshed.
+func (r *Refresher) Add(d Dataset) {
+	if d.Interval < time.Minute {
+		d.Interval = time.Minute
+	}
+	r.sets = append(r.sets, d)
+}
+
+// Start starts refreshing all the added datasets, each on its own interval.
+// The first refresh of a dataset happens after its interval as the dataset
+// is expected to have been loaded from disk on startup.
+func (r *Refresher) Start() {
+	for _, d := range r.sets {
+		go r.run(d)
+	}
+}
+
+func (r *Refresher) run(d Dataset) {
+	for {
+		time.Sleep(d.Interval)
+
+		slog.Info("refreshing dataset", "dataset", d.Name, "url", d.URL)
+		b, err := r.download(d.URL)
+		if err != nil {
+			slog.Error("error downloading dataset", "dataset", d.Name, "error", err)
+			continue
+		}
+
+		if err := d.Load(b); err != nil {
+			slog.Error("error loading dataset", "dataset", d.Name, "error", err)
+			continue
+		}
+
+		slog.Info("refreshed dataset", "dataset", d.Name, "bytes", len(b))
+	}
+}
+
+// download downloads a dataset and decompresses it if required.
+func (r *Refresher) download(url string) ([]byte, error) {
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+
+	b, err := readAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	u := strings.ToLower(strings.SplitN(url, "?", 2)[0])
+	switch {
+	case strings.HasSuffix(u, ".zip"):
+		z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		if len(z.File) == 0 {
+			return nil, errors.New("empty zip archive")
+		}
+
+		f, err := z.File[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return readAll(f)
+
+	case strings.HasSuffix(u, ".gz"):
+		g, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer g.Close()
+
+		return readAll(g)
+	}
+
+	return b, nil
+}
+
+// readAll reads up to maxSize bytes from r.
+func readAll(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSize {
+		return nil, fmt.Errorf("dataset is larger than %d bytes", maxSize)
+	}
+
+	return b, nil
+}
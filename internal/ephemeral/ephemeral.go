@@ -0,0 +1,311 @@
+// Package ephemeral is a small in-memory key-value store whose keys expire
+// after a TTL, with a quota on the number of keys per owner (eg: client IP).
+package ephemeral
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errs"
+)
+
+const keyChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+var (
+	// ErrFull is returned when the store has reached its maximum size.
+	ErrFull = errs.New(errs.RateLimited, "store is full. Try again later.")
+
+	// ErrQuota is returned when an owner has reached its maximum number of keys.
+	ErrQuota = errs.New(errs.RateLimited, "too many keys. Try again after some have expired.")
+
+	// ErrTaken is returned when a key belongs to another owner.
+	ErrTaken = errors.New("key is taken. Try another one.")
+)
+
+// Store is an in-memory key-value store with expiring keys.
+type Store struct {
+	ttl         time.Duration
+	max         int
+	maxPerOwner int
+
+	mu     sync.Mutex
+	items  map[string]*Item
+	owners map[string]int
+
+	// Number of lookups that were (not) found.
+	hits, misses uint64
+}
+
+// Item is a value in the store.
+type Item struct {
+	Val     string
+	Owner   string
+	Created time.Time
+	Expires time.Time
+}
+
+// New returns a new store that holds up to max keys and up to maxPerOwner
+// keys per owner, each of which expires ttl after it was set.
+func New(ttl time.Duration, max, maxPerOwner int) *Store {
+	s := &Store{
+		ttl:         ttl,
+		max:         max,
+		maxPerOwner: maxPerOwner,
+		items:       make(map[string]*Item),
+		owners:      make(map[string]int),
+	}
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			s.mu.Lock()
+			s.sweep()
+			s.mu.Unlock()
+		}
+	}()
+
+	return s
+}
+
+// Get returns the value of a key.
+func (s *Store) Get(key string) (Item, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.get(key)
+	if !ok {
+		s.misses++
+		return Item{}, false
+	}
+	s.hits++
+
+	return *it, true
+}
+
+// Set sets the value of a key for an owner. A key can only be overwritten
+// by its owner, which doesn't extend its expiry.
+func (s *Store) Set(key, val, owner string) (Item, error) {
+	return s.Update(key, owner, func(string, bool) (string, error) {
+		return val, nil
+	})
+}
+
+// Update sets the value of a key for an owner to the value returned by fn,
+// which gets the current value (if the key exists), holding a lock on
+// the store. It is subject to the same rules as Set.
+func (s *Store) Update(key, owner string, fn func(val string, ok bool) (string, error)) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(key, owner, fn)
+}
+
+// update implements Update. The lock should be held by the caller.
+func (s *Store) update(key, owner string, fn func(val string, ok bool) (string, error)) (Item, error) {
+	it, ok := s.get(key)
+	if ok && it.Owner != owner {
+		return Item{}, ErrTaken
+	}
+
+	if !ok {
+		if len(s.items) >= s.max {
+			s.sweep()
+			if len(s.items) >= s.max {
+				return Item{}, ErrFull
+			}
+		}
+		if s.owners[owner] >= s.maxPerOwner {
+			return Item{}, ErrQuota
+		}
+	}
+
+	var cur string
+	if ok {
+		cur = it.Val
+	}
+	val, err := fn(cur, ok)
+	if err != nil {
+		return Item{}, err
+	}
+
+	if ok {
+		it.Val = val
+		return *it, nil
+	}
+
+	now := time.Now()
+	it = &Item{Val: val, Owner: owner, Created: now, Expires: now.Add(s.ttl)}
+	s.items[key] = it
+	s.owners[owner]++
+
+	return *it, nil
+}
+
+// Delete deletes a key if it belongs to the owner.
+func (s *Store) Delete(key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.get(key)
+	if !ok {
+		return nil
+	}
+	if it.Owner != owner {
+		return ErrTaken
+	}
+
+	s.delete(key)
+	return nil
+}
+
+// Take returns the value of a key and deletes it, regardless of its owner.
+func (s *Store) Take(key string) (Item, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.get(key)
+	if !ok {
+		s.misses++
+		return Item{}, false
+	}
+	s.hits++
+
+	s.delete(key)
+	return *it, true
+}
+
+// Add stores a value for an owner under a new random key of n characters
+// and returns the key.
+func (s *Store) Add(val, owner string, n int) (string, Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		key, err := newKey(n)
+		if err != nil {
+			return "", Item{}, err
+		}
+
+		if _, exists := s.get(key); exists {
+			continue
+		}
+
+		it, err := s.update(key, owner, func(string, bool) (string, error) {
+			return val, nil
+		})
+		return key, it, err
+	}
+}
+
+// CacheStats returns the statistics of the store.
+func (s *Store) CacheStats() cache.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := cache.Stats{
+		Entries: len(s.items),
+		Hits:    s.hits,
+		Misses:  s.misses,
+	}
+	for k, it := range s.items {
+		st.Bytes += int64(len(k) + len(it.Val) + len(it.Owner))
+		if st.Oldest.IsZero() || it.Created.Before(st.Oldest) {
+			st.Oldest = it.Created
+		}
+		if it.Created.After(st.Newest) {
+			st.Newest = it.Created
+		}
+	}
+
+	return st
+}
+
+// Dump produces a gob dump of the store.
+func (s *Store) Dump() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(s.items); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load loads a gob dump of the store.
+func (s *Store) Load(b []byte) error {
+	items := make(map[string]*Item)
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&items); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = items
+	s.owners = make(map[string]int)
+	for _, it := range items {
+		s.owners[it.Owner]++
+	}
+	s.sweep()
+
+	return nil
+}
+
+// get returns an unexpired item. The lock should be held by the caller.
+func (s *Store) get(key string) (*Item, bool) {
+	it, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(it.Expires) {
+		s.delete(key)
+		return nil, false
+	}
+
+	return it, true
+}
+
+// delete removes a key. The lock should be held by the caller.
+func (s *Store) delete(key string) {
+	it, ok := s.items[key]
+	if !ok {
+		return
+	}
+
+	delete(s.items, key)
+	if s.owners[it.Owner]--; s.owners[it.Owner] <= 0 {
+		delete(s.owners, it.Owner)
+	}
+}
+
+// sweep removes expired keys. The lock should be held by the caller.
+func (s *Store) sweep() {
+	now := time.Now()
+	for k, it := range s.items {
+		if now.After(it.Expires) {
+			s.delete(k)
+		}
+	}
+}
+
+// newKey returns a random lowercase alphanumeric key as DNS names are
+// case insensitive.
+func newKey(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = keyChars[int(b[i])%len(keyChars)]
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,108 @@
+// Package errs has typed errors that services return so that the DNS
+// handler can respond to every kind of failure with a consistent RCODE.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind is the kind of an error, which decides the response code.
+type Kind int
+
+const (
+	// User is an invalid or unknown query (NXDOMAIN). Errors that aren't
+	// typed are assumed to be user errors as services mostly return them
+	// for bad input.
+	User Kind = iota
+
+	// NotImplemented is a valid query that is not supported (NOTIMP).
+	NotImplemented
+
+	// Upstream is a failure of a third party API or data source that the
+	// service depends on (SERVFAIL with an extended DNS error).
+	Upstream
+
+	// RateLimited is a query refused because of a rate limit or an
+	// exhausted quota (REFUSED).
+	RateLimited
+
+	// Internal is an unexpected error in the server (SERVFAIL).
+	Internal
+)
+
+// String returns the name of the kind. eg: rate_limited
+func (k Kind) String() string {
+	switch k {
+	case User:
+		return "user"
+	case NotImplemented:
+		return "not_implemented"
+	case Upstream:
+		return "upstream"
+	case RateLimited:
+		return "rate_limited"
+	case Internal:
+		return "internal"
+	}
+
+	return "unknown"
+}
+
+// Error is an error with a Kind. Msg is the message shown to users and
+// Err, if set, is the underlying error.
+type Error struct {
+	Kind Kind
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind.
+func New(k Kind, msg string) error {
+	return &Error{Kind: k, Msg: msg}
+}
+
+// Newf returns an error of the given kind with a formatted message.
+func Newf(k Kind, format string, a ...interface{}) error {
+	return &Error{Kind: k, Msg: fmt.Sprintf(format, a...)}
+}
+
+// Wrap returns an error of the given kind that shows msg to users and
+// wraps err. If msg is empty, err's message is shown.
+func Wrap(k Kind, msg string, err error) error {
+	if msg == "" {
+		msg = err.Error()
+	}
+	return &Error{Kind: k, Msg: msg, Err: err}
+}
+
+// WithMsg returns a copy of err with a different message (eg: translated),
+// retaining its kind.
+func WithMsg(err error, msg string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return errors.New(msg)
+	}
+
+	c := *e
+	c.Msg = msg
+	return &c
+}
+
+// KindOf returns the Kind of an error. Untyped errors are User errors.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return User
+}
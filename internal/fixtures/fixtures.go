@@ -0,0 +1,68 @@
+// Package fixtures is an http.RoundTripper that answers upstream API requests
+// with canned responses from files instead of making network requests, for
+// running upstream-backed services offline (eg: local development and tests).
+//
+// The response to a request is the file $dir/$host.json, eg:
+// fixtures/api.met.no.json. {{date}} and {{date+N}} in the files are replaced
+// with the current and N days later UTC dates (YYYY-MM-DD) so that forecasts
+// and rates don't appear to be stale.
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var reDate = regexp.MustCompile(`\{\{date(\+[0-9]+)?\}\}`)
+
+// Transport serves HTTP responses from fixture files.
+type Transport struct {
+	dir string
+}
+
+// New returns a Transport that serves fixtures from dir.
+func New(dir string) *Transport {
+	return &Transport{dir: dir}
+}
+
+// RoundTrip returns the fixture for the request's host, or a 404 response
+// if there's none.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadFile(filepath.Join(t.dir, filepath.Base(r.URL.Hostname())+".json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		return t.response(r, http.StatusNotFound, []byte(fmt.Sprintf(`{"error": "no fixture for %s"}`, r.URL.Hostname()))), nil
+	}
+
+	today := time.Now().UTC()
+	b = reDate.ReplaceAllFunc(b, func(m []byte) []byte {
+		n, _ := strconv.Atoi(string(reDate.FindSubmatch(m)[1]))
+		return []byte(today.AddDate(0, 0, n).Format("2006-01-02"))
+	})
+
+	return t.response(r, http.StatusOK, b), nil
+}
+
+func (t *Transport) response(r *http.Request, code int, b []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       r,
+		Uncompressed:  true,
+	}
+}
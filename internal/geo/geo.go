@@ -2,13 +2,17 @@
 package geo
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Geo is the geolocation controller.
@@ -18,7 +22,14 @@ type Geo struct {
 	// { $keyword: { $timezone: $country_code }}
 	tzMap map[string][]Location
 
+	// k-d tree of locations for nearest neighbour lookups.
+	tree *kdNode
+
 	count int
+
+	// Guards the maps and the tree which are swapped when a new
+	// geolocation file is loaded.
+	mut sync.RWMutex
 }
 
 // Location represents a geographic location.
@@ -36,13 +47,21 @@ var (
 	reClean = regexp.MustCompile("[^a-z/]+")
 )
 
+const minFuzzyLen = 4
+
 // New initiates a new geo location map.
 func New(filePath string) (*Geo, error) {
 	g := &Geo{
 		tzMap: make(map[string][]Location),
 	}
 
-	locs, err := g.readFile(filePath)
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	locs, err := parse(f)
 	if err != nil {
 		return nil, err
 	}
@@ -51,10 +70,40 @@ func New(filePath string) (*Geo, error) {
 	return g, nil
 }
 
+// Load parses a geonames.org geolocation file and replaces the loaded
+// locations with it. Files with less than half the number of locations
+// that are currently loaded are rejected as they're likely truncated.
+func (g *Geo) Load(b []byte) error {
+	locs, err := parse(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	n := g.Count()
+	if len(locs) == 0 || len(locs) < n/2 {
+		return fmt.Errorf("too few locations (%d) in the file. %d are loaded", len(locs), n)
+	}
+
+	ng := &Geo{tzMap: make(map[string][]Location)}
+	ng.load(locs)
+	if ng.tree == nil {
+		return errors.New("no locations indexed")
+	}
+
+	g.mut.Lock()
+	g.locations, g.tzMap, g.tree, g.count = ng.locations, ng.tzMap, ng.tree, ng.count
+	g.mut.Unlock()
+
+	return nil
+}
+
 // Query queries a loaded geo location by the given keyword.
 func (g *Geo) Query(q string) []Location {
 	q = reClean.ReplaceAllString(strings.ToLower(q), "")
 
+	g.mut.RLock()
+	defer g.mut.RUnlock()
+
 	zones, ok := g.tzMap[q]
 	if !ok {
 		return nil
@@ -63,12 +112,64 @@ func (g *Geo) Query(q string) []Location {
 	return zones
 }
 
+// Fuzzy returns the locations of the name closest to the given keyword
+// that's within maxDist edits (Levenshtein distance) of it. Of names that
+// are equally close, the one with the most populous location is picked.
+// Keywords shorter than minFuzzyLen aren't matched as they'd match too much.
+func (g *Geo) Fuzzy(q string, maxDist int) []Location {
+	q = reClean.ReplaceAllString(strings.ToLower(q), "")
+	if len(q) < minFuzzyLen || maxDist < 1 {
+		return nil
+	}
+
+	g.mut.RLock()
+	defer g.mut.RUnlock()
+
+	var (
+		best []Location
+		dist = maxDist + 1
+	)
+	for name, locs := range g.tzMap {
+		// The distance is at least the difference in lengths.
+		if d := len(name) - len(q); d >= dist || -d >= dist {
+			continue
+		}
+
+		d := levenshtein(q, name)
+		if d < dist || (d == dist && best != nil && locs[0].Population > best[0].Population) {
+			best, dist = locs, d
+		}
+	}
+
+	return best
+}
+
+// Nearest returns up to n locations nearest to the given lat, lon
+// ordered by distance.
+func (g *Geo) Nearest(lat, lon float64, n int) []Location {
+	g.mut.RLock()
+	defer g.mut.RUnlock()
+
+	res := g.tree.nearest(toCartesian(lat, lon), n, 0, make([]kdResult, 0, n+1))
+
+	out := make([]Location, 0, len(res))
+	for _, r := range res {
+		out = append(out, r.loc)
+	}
+
+	return out
+}
+
 // Count returns the number of unique locations loaded.
 func (g *Geo) Count() int {
+	g.mut.RLock()
+	defer g.mut.RUnlock()
+
 	return g.count
 }
 
 func (g *Geo) load(locs []Location) {
+	pts := make([]kdPoint, 0, len(locs))
 	for _, l := range locs {
 		// Add the city name.
 		name := reClean.ReplaceAllString(strings.ToLower(l.Name), "")
@@ -77,13 +178,22 @@ func (g *Geo) load(locs []Location) {
 			g.tzMap[name] = []Location{}
 		}
 		g.tzMap[name] = append(g.tzMap[name], l)
+		pts = append(pts, kdPoint{loc: l, pt: toCartesian(l.Lat, l.Lon)})
 
 		g.count++
 	}
 
+	// Index all locations in the k-d tree.
+	g.tree = buildKDTree(pts, 0)
+
 	// Cities in timezone names that don't exist in the map, add to the map.
 	for _, l := range locs {
-		city := reClean.ReplaceAllString(strings.Split(l.Timezone, "/")[1], "")
+		tz := strings.Split(l.Timezone, "/")
+		if len(tz) < 2 {
+			continue
+		}
+
+		city := reClean.ReplaceAllString(strings.ToLower(tz[1]), "")
 		_, ok := g.tzMap[city]
 		if !ok {
 			g.tzMap[city] = []Location{l}
@@ -99,16 +209,10 @@ func (g *Geo) load(locs []Location) {
 	}
 }
 
-// readFile loads a geonames.org geolocation file and returns the list
-// of parses Locations.
-func (g *Geo) readFile(filePath string) ([]Location, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	rd := csv.NewReader(f)
+// parse parses a geonames.org geolocation file and returns the list
+// of parsed Locations.
+func parse(r io.Reader) ([]Location, error) {
+	rd := csv.NewReader(r)
 	rd.Comma = '\t'
 
 	out := []Location{}
@@ -149,3 +253,34 @@ func (g *Geo) readFile(filePath string) ([]Location, error) {
 
 	return out, nil
 }
+
+// levenshtein returns the number of single byte insertions, deletions, and
+// substitutions it takes to turn a into b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j-1] + cost
+			if n := prev[j] + 1; n < cur[j] {
+				cur[j] = n
+			}
+			if n := cur[j-1] + 1; n < cur[j] {
+				cur[j] = n
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,114 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// kdNode is a node in a 3-dimensional k-d tree of locations. Locations are
+// stored as points on the unit sphere so that the Euclidean (chord) distance
+// between two points increases monotonically with the great-circle distance.
+type kdNode struct {
+	loc   Location
+	pt    [3]float64
+	left  *kdNode
+	right *kdNode
+}
+
+type kdPoint struct {
+	loc Location
+	pt  [3]float64
+}
+
+// kdResult is a candidate in a nearest-neighbour search.
+type kdResult struct {
+	loc  Location
+	dist float64
+}
+
+// buildKDTree recursively builds a balanced k-d tree from the given points.
+func buildKDTree(pts []kdPoint, depth int) *kdNode {
+	if len(pts) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(pts, func(i, j int) bool {
+		return pts[i].pt[axis] < pts[j].pt[axis]
+	})
+
+	mid := len(pts) / 2
+	return &kdNode{
+		loc:   pts[mid].loc,
+		pt:    pts[mid].pt,
+		left:  buildKDTree(pts[:mid], depth+1),
+		right: buildKDTree(pts[mid+1:], depth+1),
+	}
+}
+
+// nearest collects the n nearest locations to pt into res, which is kept
+// sorted by distance.
+func (k *kdNode) nearest(pt [3]float64, n, depth int, res []kdResult) []kdResult {
+	if k == nil {
+		return res
+	}
+
+	// Add the current node to the results if it's closer than the farthest one.
+	d := sqDist(pt, k.pt)
+	if len(res) < n || d < res[len(res)-1].dist {
+		i := sort.Search(len(res), func(i int) bool {
+			return res[i].dist > d
+		})
+
+		res = append(res, kdResult{})
+		copy(res[i+1:], res[i:])
+		res[i] = kdResult{loc: k.loc, dist: d}
+
+		if len(res) > n {
+			res = res[:n]
+		}
+	}
+
+	var (
+		axis  = depth % 3
+		diff  = pt[axis] - k.pt[axis]
+		first = k.left
+		other = k.right
+	)
+	if diff > 0 {
+		first, other = other, first
+	}
+
+	res = first.nearest(pt, n, depth+1, res)
+
+	// Only descend into the other side of the splitting plane if it
+	// can contain a closer point.
+	if len(res) < n || diff*diff < res[len(res)-1].dist {
+		res = other.nearest(pt, n, depth+1, res)
+	}
+
+	return res
+}
+
+// toCartesian converts lat, lon in degrees to a point on the unit sphere.
+func toCartesian(lat, lon float64) [3]float64 {
+	var (
+		la = lat * math.Pi / 180
+		lo = lon * math.Pi / 180
+	)
+
+	return [3]float64{
+		math.Cos(la) * math.Cos(lo),
+		math.Cos(la) * math.Sin(lo),
+		math.Sin(la),
+	}
+}
+
+func sqDist(a, b [3]float64) float64 {
+	var d float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return d
+}
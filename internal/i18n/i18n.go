@@ -0,0 +1,81 @@
+// Package i18n translates informational messages such as errors and help
+// text using catalogs embedded from lang/$code.json. The catalogs map English
+// messages to their translations. Messages that are not in a catalog are
+// returned as-is.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed lang/*.json
+var langFS embed.FS
+
+// nameKey is the catalog key that holds the name of the language.
+const nameKey = "_name"
+
+// I18n holds translation catalogs.
+type I18n struct {
+	langs map[string]map[string]string
+}
+
+// New loads the embedded translation catalogs.
+func New() (*I18n, error) {
+	files, err := langFS.ReadDir("lang")
+	if err != nil {
+		return nil, err
+	}
+
+	i := &I18n{langs: make(map[string]map[string]string)}
+	for _, f := range files {
+		b, err := langFS.ReadFile(path.Join("lang", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var cat map[string]string
+		if err := json.Unmarshal(b, &cat); err != nil {
+			return nil, err
+		}
+
+		i.langs[strings.TrimSuffix(f.Name(), ".json")] = cat
+	}
+
+	return i, nil
+}
+
+// Langs returns the sorted codes of the available languages.
+func (i *I18n) Langs() []string {
+	out := make([]string, 0, len(i.langs))
+	for l := range i.langs {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Has checks whether a language is available.
+func (i *I18n) Has(lang string) bool {
+	_, ok := i.langs[lang]
+	return ok
+}
+
+// Name returns the name of a language in that language.
+func (i *I18n) Name(lang string) string {
+	return i.langs[lang][nameKey]
+}
+
+// T translates a message to the given language. The message is returned
+// untranslated if the language is empty or there's no translation.
+func (i *I18n) T(lang, msg string) string {
+	if t, ok := i.langs[lang][msg]; ok {
+		return t
+	}
+
+	return msg
+}
@@ -0,0 +1,120 @@
+// Package logging sets up structured logging with log/slog and carries the
+// request ID and outcome of a query through its context so that the log
+// lines of a query, including ones from upstream fetches, can be tied
+// together.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Query outcomes set by the code that answers a query.
+const (
+	CacheHit = "cache_hit"
+	Stale    = "stale"
+	Fetched  = "fetched"
+	Queued   = "queued"
+)
+
+type ctxKey struct{}
+
+// request is the state of a query carried in its context.
+type request struct {
+	id string
+
+	outcome string
+	mut     sync.Mutex
+}
+
+// New returns a logger that writes to w in the given format (text or json)
+// at and above the given level (debug, info, warn, error). Log lines with
+// a query context get its request ID.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lv slog.Level
+	if level == "" {
+		level = "info"
+	}
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", level)
+	}
+
+	o := &slog.HandlerOptions{Level: lv}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, o)
+	case "json":
+		h = slog.NewJSONHandler(w, o)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+
+	return slog.New(&handler{Handler: h}), nil
+}
+
+// WithRequest returns a context for a query with a new random request ID.
+func WithRequest(ctx context.Context) context.Context {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return context.WithValue(ctx, ctxKey{}, &request{id: hex.EncodeToString(b)})
+}
+
+// RequestID returns the request ID of a query context or "" if there's none.
+func RequestID(ctx context.Context) string {
+	if r, ok := ctx.Value(ctxKey{}).(*request); ok {
+		return r.id
+	}
+
+	return ""
+}
+
+// SetOutcome sets how a query was answered (eg: CacheHit, Queued) on its
+// context. It's a no-op for contexts without a request.
+func SetOutcome(ctx context.Context, outcome string) {
+	if r, ok := ctx.Value(ctxKey{}).(*request); ok {
+		r.mut.Lock()
+		r.outcome = outcome
+		r.mut.Unlock()
+	}
+}
+
+// Outcome returns the outcome set on a query context, if any.
+func Outcome(ctx context.Context) string {
+	if r, ok := ctx.Value(ctxKey{}).(*request); ok {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		return r.outcome
+	}
+
+	return ""
+}
+
+// handler adds the request ID of a query context to log records.
+type handler struct {
+	slog.Handler
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("req_id", id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name)}
+}
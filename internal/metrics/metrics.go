@@ -0,0 +1,212 @@
+// Package metrics counts queries, errors, and latencies per service and
+// writes them, along with the statistics of service caches and upstreams,
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/upstream"
+)
+
+// Upper bounds (seconds) of the query latency histogram buckets.
+var buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// Metrics holds the counters of services.
+type Metrics struct {
+	services map[string]*service
+	mut      sync.Mutex
+
+	// Caches by name. Ones that implement upstream.Stater also have
+	// their upstream fetches written.
+	caches map[string]cache.Cacher
+
+	// Returns the number of queries refused by the client rate limit.
+	limited func() uint64
+}
+
+type service struct {
+	queries uint64
+
+	// Errors by errs.Kind name (or timeout).
+	errors map[string]uint64
+
+	// Latency histogram. counts[i] is the number of queries that took
+	// <= buckets[i] and the last one is +Inf.
+	counts []uint64
+	sum    float64
+}
+
+// New returns a new instance of Metrics. caches are read when the metrics
+// are written and can be added to after this.
+func New(caches map[string]cache.Cacher) *Metrics {
+	return &Metrics{
+		services: make(map[string]*service),
+		caches:   caches,
+	}
+}
+
+// CountLimited sets the function that returns the number of queries
+// refused by the client rate limiter.
+func (m *Metrics) CountLimited(fn func() uint64) {
+	m.limited = fn
+}
+
+// Observe counts a query to a service that took d. errKind is the kind of
+// error the query failed with or empty if it succeeded.
+func (m *Metrics) Observe(svc string, d time.Duration, errKind string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	s, ok := m.services[svc]
+	if !ok {
+		s = &service{
+			errors: make(map[string]uint64),
+			counts: make([]uint64, len(buckets)+1),
+		}
+		m.services[svc] = s
+	}
+
+	s.queries++
+	if errKind != "" {
+		s.errors[errKind]++
+	}
+
+	sec := d.Seconds()
+	s.sum += sec
+	i := sort.SearchFloat64s(buckets, sec)
+	s.counts[i]++
+}
+
+// Write writes all the metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	m.writeServices(b)
+	m.writeCaches(b)
+
+	if m.limited != nil {
+		header(b, "dnstoys_ratelimited_total", "counter", "Queries refused by the client rate limit.")
+		fmt.Fprintf(b, "dnstoys_ratelimited_total %d\n", m.limited())
+	}
+
+	return b.Flush()
+}
+
+func (m *Metrics) writeServices(b *bufio.Writer) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	names := make([]string, 0, len(m.services))
+	for n := range m.services {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	header(b, "dnstoys_queries_total", "counter", "Queries answered by a service.")
+	for _, n := range names {
+		fmt.Fprintf(b, "dnstoys_queries_total{service=%q} %d\n", n, m.services[n].queries)
+	}
+
+	header(b, "dnstoys_query_errors_total", "counter", "Queries that failed by the kind of error.")
+	for _, n := range names {
+		s := m.services[n]
+		kinds := make([]string, 0, len(s.errors))
+		for k := range s.errors {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		for _, k := range kinds {
+			fmt.Fprintf(b, "dnstoys_query_errors_total{service=%q,kind=%q} %d\n", n, k, s.errors[k])
+		}
+	}
+
+	header(b, "dnstoys_query_duration_seconds", "histogram", "Time taken to answer queries.")
+	for _, n := range names {
+		var (
+			s   = m.services[n]
+			cum uint64
+		)
+		for i, c := range s.counts {
+			cum += c
+			le := "+Inf"
+			if i < len(buckets) {
+				le = strconv.FormatFloat(buckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(b, "dnstoys_query_duration_seconds_bucket{service=%q,le=%q} %d\n", n, le, cum)
+		}
+		fmt.Fprintf(b, "dnstoys_query_duration_seconds_sum{service=%q} %g\n", n, s.sum)
+		fmt.Fprintf(b, "dnstoys_query_duration_seconds_count{service=%q} %d\n", n, s.queries)
+	}
+}
+
+func (m *Metrics) writeCaches(b *bufio.Writer) {
+	names := make([]string, 0, len(m.caches))
+	for n := range m.caches {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	stats := make([]cache.Stats, len(names))
+	for i, n := range names {
+		stats[i] = m.caches[n].CacheStats()
+	}
+
+	header(b, "dnstoys_cache_entries", "gauge", "Entries in a service cache.")
+	for i, n := range names {
+		fmt.Fprintf(b, "dnstoys_cache_entries{cache=%q} %d\n", n, stats[i].Entries)
+	}
+
+	header(b, "dnstoys_cache_hits_total", "counter", "Lookups served from a service cache.")
+	for i, n := range names {
+		fmt.Fprintf(b, "dnstoys_cache_hits_total{cache=%q} %d\n", n, stats[i].Hits)
+	}
+
+	header(b, "dnstoys_cache_misses_total", "counter", "Lookups not served from a service cache.")
+	for i, n := range names {
+		fmt.Fprintf(b, "dnstoys_cache_misses_total{cache=%q} %d\n", n, stats[i].Misses)
+	}
+
+	var (
+		ups    []string
+		upStat []upstream.Stats
+	)
+	for _, n := range names {
+		if s, ok := m.caches[n].(upstream.Stater); ok {
+			ups = append(ups, n)
+			upStat = append(upStat, s.UpstreamStats())
+		}
+	}
+
+	header(b, "dnstoys_upstream_fetches_total", "counter", "Fetches from upstream APIs.")
+	for i, n := range ups {
+		fmt.Fprintf(b, "dnstoys_upstream_fetches_total{upstream=%q} %d\n", n, upStat[i].Fetches)
+	}
+
+	header(b, "dnstoys_upstream_errors_total", "counter", "Fetches from upstream APIs that failed.")
+	for i, n := range ups {
+		fmt.Fprintf(b, "dnstoys_upstream_errors_total{upstream=%q} %d\n", n, upStat[i].Errors)
+	}
+
+	header(b, "dnstoys_upstream_rate_limited_total", "counter", "Fetches that upstream APIs rate limited.")
+	for i, n := range ups {
+		fmt.Fprintf(b, "dnstoys_upstream_rate_limited_total{upstream=%q} %d\n", n, upStat[i].RateLimited)
+	}
+
+	header(b, "dnstoys_upstream_dropped_total", "counter", "Fetches not made because of the local rate limit or quota.")
+	for i, n := range ups {
+		fmt.Fprintf(b, "dnstoys_upstream_dropped_total{upstream=%q} %d\n", n, upStat[i].Dropped)
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func header(b *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
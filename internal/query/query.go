@@ -0,0 +1,187 @@
+// Package query normalizes incoming query names and parses them into
+// arguments with per-service grammars so that services don't have to
+// handle malformed names themselves.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxNameLen  = 253
+	maxLabelLen = 63
+)
+
+var (
+	// Characters other than these are removed from queries.
+	reClean = regexp.MustCompile(`[^\p{L}\p{N}/\-\.:]`)
+
+	// ErrInvalid is returned for names that are not valid DNS names.
+	ErrInvalid = errors.New("invalid query.")
+)
+
+// Normalize validates a query name, trims the (lowercase) service suffix
+// from it, decodes escaped characters (eg: \032) and punycode (IDN) labels,
+// and removes characters that are not letters, digits, or /-.:
+// Resolvers may randomize the case of names (0x20 encoding), so the suffix
+// is matched in any case. The rest of the name keeps its case for services
+// that depend on it (eg: Co.molar). Grammars match queries in lowercase.
+func Normalize(name, suffix string) (string, error) {
+	name, err := normalize(name, suffix)
+	if err != nil {
+		return "", err
+	}
+
+	return reClean.ReplaceAllString(name, ""), nil
+}
+
+// NormalizeStrict is Normalize for services that can't answer a query with
+// characters removed from it. It returns an error instead of removing them.
+// eg: the parentheses in Ca(OH)2
+func NormalizeStrict(name, suffix string) (string, error) {
+	name, err := normalize(name, suffix)
+	if err != nil {
+		return "", err
+	}
+
+	if c := reClean.FindString(name); c != "" {
+		return "", fmt.Errorf("invalid character in query: %s.", c)
+	}
+
+	return name, nil
+}
+
+// normalize validates a name, trims the suffix from it, and decodes it.
+func normalize(name, suffix string) (string, error) {
+	if len(name) > maxNameLen+1 {
+		return "", ErrInvalid
+	}
+
+	if n := len(name) - len(suffix); n >= 0 && strings.EqualFold(name[n:], suffix) {
+		name = name[:n]
+	}
+
+	name, err := unescape(name)
+	if err != nil {
+		return "", err
+	}
+
+	labels := strings.Split(name, ".")
+	for i, l := range labels {
+		if len(l) > maxLabelLen {
+			return "", ErrInvalid
+		}
+
+		if strings.HasPrefix(strings.ToLower(l), "xn--") {
+			u, err := idna.ToUnicode(strings.ToLower(l))
+			if err != nil {
+				return "", ErrInvalid
+			}
+			labels[i] = u
+		}
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// unescape decodes the \DDD and \X escapes in a name in the
+// presentation format.
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			n, _ := strconv.Atoi(s[i+1 : i+4])
+			if n > 255 {
+				return "", ErrInvalid
+			}
+			b.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+
+		if i+1 < len(s) {
+			b.WriteByte(s[i+1])
+			i++
+		}
+	}
+
+	return b.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Grammar is the syntax of a service's queries as a regexp with named
+// groups that are returned as arguments.
+type Grammar struct {
+	re *regexp.Regexp
+
+	// Error returned for queries that don't match.
+	err error
+}
+
+// Args are the named arguments parsed from a query. Optional groups
+// that didn't match are empty.
+type Args map[string]string
+
+// NewGrammar returns a Grammar for a pattern that's matched against the whole
+// lowercased query. errMsg is returned for queries that don't match it.
+func NewGrammar(pattern, errMsg string) *Grammar {
+	return &Grammar{
+		re:  regexp.MustCompile("^(?:" + pattern + ")$"),
+		err: errors.New(errMsg),
+	}
+}
+
+// Parse parses a query into its arguments.
+func (g *Grammar) Parse(q string) (Args, error) {
+	res := g.re.FindStringSubmatch(strings.ToLower(q))
+	if res == nil {
+		return nil, g.err
+	}
+
+	a := make(Args, len(res))
+	for i, name := range g.re.SubexpNames() {
+		if name != "" {
+			a[name] = res[i]
+		}
+	}
+
+	return a, nil
+}
+
+// Float returns an argument as a float.
+func (a Args) Float(name string) (float64, error) {
+	v, err := strconv.ParseFloat(a[name], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s.", name)
+	}
+
+	return v, nil
+}
+
+// Int returns an argument as an int.
+func (a Args) Int(name string) (int, error) {
+	v, err := strconv.Atoi(a[name])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s.", name)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,71 @@
+package query
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name, suffix, want string
+		err                bool
+	}{
+		{"mumbai.time.", ".time.", "mumbai", false},
+		{"MUMBAI.TIME.", ".time.", "MUMBAI", false},
+		{"MuMbAi.tImE.", ".time.", "MuMbAi", false},
+		{"Co.molar.", ".molar.", "Co", false},
+		{"1GB-MB.UNIT.", ".unit.", "1GB-MB", false},
+		{"new\\032York.time.", ".time.", "newYork", false},
+		{"\\077umbai.time.", ".time.", "Mumbai", false},
+		{"xn--mnchen-3ya.time.", ".time.", "münchen", false},
+		{"XN--MNCHEN-3YA.TIME.", ".time.", "münchen", false},
+		{"a$b!c.time.", ".time.", "abc", false},
+		{"10usd-inr.fx.", ".fx.", "10usd-inr", false},
+		{"1.5in-cm.unit.", ".unit.", "1.5in-cm", false},
+		{string(make([]byte, 64)) + ".time.", ".time.", "", true},
+		{"\\999.time.", ".time.", "", true},
+	}
+
+	for _, c := range cases {
+		got, err := Normalize(c.name, c.suffix)
+		if c.err {
+			if err == nil {
+				t.Errorf("Normalize(%q): expected error, got %q", c.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Normalize(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeStrict(t *testing.T) {
+	cases := []struct {
+		name, suffix, want string
+		err                bool
+	}{
+		{"Ca-OH-2.molar.", ".molar.", "Ca-OH-2", false},
+		{"xn--mnchen-3ya.time.", ".time.", "münchen", false},
+		{"Ca\\(OH\\)2.molar.", ".molar.", "", true},
+		{"a$b!c.time.", ".time.", "", true},
+	}
+
+	for _, c := range cases {
+		got, err := NormalizeStrict(c.name, c.suffix)
+		if c.err {
+			if err == nil {
+				t.Errorf("NormalizeStrict(%q): expected error, got %q", c.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeStrict(%q): %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("NormalizeStrict(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
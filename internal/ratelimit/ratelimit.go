@@ -0,0 +1,107 @@
+// Package ratelimit limits the queries from clients with token buckets
+// keyed by their IP networks so that abusive resolvers or clients can't
+// starve the server.
+package ratelimit
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"golang.org/x/time/rate"
+)
+
+// Opt contains config options for the Limiter.
+type Opt struct {
+	// QPS is the sustained queries/sec allowed per client network and
+	// Burst is the number of queries allowed at once.
+	QPS   float64
+	Burst int
+
+	// Prefix lengths that client addresses are aggregated to. eg: 24 to
+	// limit IPv4 /24 networks together. 0 defaults to 32 and 64.
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// Max client networks tracked. Idle ones are forgotten after
+	// IdleTTL.
+	MaxClients int
+	IdleTTL    time.Duration
+}
+
+// Limiter is a per-client network token bucket rate limiter.
+type Limiter struct {
+	opt     Opt
+	v4Mask  net.IPMask
+	v6Mask  net.IPMask
+	buckets *cache.Map
+
+	// Number of queries that were refused.
+	limited uint64
+}
+
+// New returns a new instance of Limiter.
+func New(o Opt) *Limiter {
+	if o.Burst < 1 {
+		o.Burst = 1
+	}
+	if o.IPv4Prefix < 1 || o.IPv4Prefix > 32 {
+		o.IPv4Prefix = 32
+	}
+	if o.IPv6Prefix < 1 || o.IPv6Prefix > 128 {
+		o.IPv6Prefix = 64
+	}
+	if o.MaxClients < 1 {
+		o.MaxClients = 100000
+	}
+	if o.IdleTTL == 0 {
+		o.IdleTTL = time.Minute * 10
+	}
+
+	return &Limiter{
+		opt:     o,
+		v4Mask:  net.CIDRMask(o.IPv4Prefix, 32),
+		v6Mask:  net.CIDRMask(o.IPv6Prefix, 128),
+		buckets: cache.NewMap(o.IdleTTL, o.MaxClients),
+	}
+}
+
+// Allow returns true if a query from ip is within its network's limit.
+// Queries without an IP (eg: from a local socket) are always allowed.
+func (l *Limiter) Allow(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+
+	key := l.network(ip)
+
+	// A bucket is reset once its network is idle for IdleTTL, by
+	// when it would have refilled anyway.
+	var b *rate.Limiter
+	if v, ok := l.buckets.Get(key); ok {
+		b = v.(*rate.Limiter)
+	} else {
+		b = rate.NewLimiter(rate.Limit(l.opt.QPS), l.opt.Burst)
+	}
+	l.buckets.Set(key, b)
+
+	if !b.Allow() {
+		atomic.AddUint64(&l.limited, 1)
+		return false
+	}
+	return true
+}
+
+// Limited returns the number of queries that were refused.
+func (l *Limiter) Limited() uint64 {
+	return atomic.LoadUint64(&l.limited)
+}
+
+// network returns the network of an IP that it's limited with.
+func (l *Limiter) network(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(l.v4Mask).String()
+	}
+	return ip.Mask(l.v6Mask).String()
+}
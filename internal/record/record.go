@@ -0,0 +1,127 @@
+// Package record defines structured service results for the machine
+// readable output modes (eg: mumbai.time.kv) that services can opt into.
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Max length of a TXT character string.
+const maxTXTLen = 255
+
+// Field is a named value in a Record.
+type Field struct {
+	Key string
+	Val interface{}
+}
+
+// Record is an ordered list of fields that describes one result.
+type Record []Field
+
+// Structured is implemented by services that return structured results
+// in addition to the free-form text returned by Query().
+type Structured interface {
+	Fields(string) ([]Record, error)
+}
+
+// KV returns the fields of the record as key=value strings.
+func (r Record) KV() []string {
+	out := make([]string, 0, len(r))
+	for _, f := range r {
+		out = append(out, f.Key+"="+format(f.Val))
+	}
+
+	return out
+}
+
+// MarshalJSON encodes the record as a JSON object with the fields in order.
+func (r Record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		k, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.Val)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// JSON encodes records as a compact JSON array split into TXT strings.
+// Non-ASCII characters are \u escaped so that the strings can be joined
+// and parsed as-is from dig's output.
+func JSON(recs []Record) ([]string, error) {
+	if recs == nil {
+		recs = []Record{}
+	}
+
+	b, err := json.Marshal(recs)
+	if err != nil {
+		return nil, err
+	}
+	s := asciiJSON(string(b))
+
+	out := make([]string, 0, len(s)/maxTXTLen+1)
+	for len(s) > maxTXTLen {
+		out = append(out, s[:maxTXTLen])
+		s = s[maxTXTLen:]
+	}
+	out = append(out, s)
+
+	return out, nil
+}
+
+// asciiJSON replaces non-ASCII characters in JSON with \u escapes.
+func asciiJSON(s string) string {
+	var b bytes.Buffer
+	for _, c := range s {
+		if c < 0x80 {
+			b.WriteRune(c)
+			continue
+		}
+
+		if c > 0xffff {
+			r1, r2 := utf16.EncodeRune(c)
+			fmt.Fprintf(&b, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+		fmt.Fprintf(&b, "\\u%04x", c)
+	}
+
+	return b.String()
+}
+
+func format(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,164 @@
+// Package registry is where services register themselves so that the server
+// picks them up without any changes to its handlers. A service package calls
+// Register in its init() and is included in the server with a blank import.
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/creds"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/upstream"
+	"github.com/knadh/koanf"
+)
+
+// Service responds to DNS queries for its suffixes. Services can optionally
+// implement Dumper and Loader to persist their state across restarts and
+// cache.Cacher to report their cache in `cache.stats`.
+type Service interface {
+	Query(string) ([]string, error)
+
+	// Help describes the service for the `help` queries.
+	Help() Help
+}
+
+// Dumper is a Service whose state can be dumped to a snapshot.
+type Dumper interface {
+	Dump() ([]byte, error)
+}
+
+// Loader is a Service whose state can be restored from a snapshot.
+type Loader interface {
+	Load([]byte) error
+}
+
+// Group is a set of services created together that share state (eg: a
+// cache), with a Service for each of its entry's suffixes. Like a Service,
+// a Group can implement Dumper, Loader, and cache.Cacher.
+type Group interface {
+	// Service returns the service for one of the entry's suffixes.
+	Service(suffix string) Service
+}
+
+// Services is a Group of services that don't share state.
+type Services map[string]Service
+
+// Service returns the service for a suffix.
+func (s Services) Service(suffix string) Service {
+	return s[suffix]
+}
+
+// Env holds the server's shared dependencies that services are created
+// with.
+type Env struct {
+	// Domain of the server. eg: dns.toys
+	Domain string
+
+	// Transport is the HTTP transport for upstream API requests. nil for
+	// the default (network) transport.
+	Transport http.RoundTripper
+
+	// Creds holds the API keys of upstream providers.
+	Creds *creds.Manager
+
+	// Geo returns the geo locations, which are loaded on first use.
+	Geo func() *geo.Geo
+
+	// Store returns the disk-backed cache store of a config section (eg:
+	// weather.geocoding) if its cache_backend is set, or nil.
+	Store func(section string) upstream.Store
+
+	// Snapshot returns the snapshot of a config section to restore state
+	// from, if there's one, and saves d to it on exit and periodically.
+	// Services only need it for state besides their own Dump().
+	Snapshot func(section string, d Dumper) []byte
+
+	// AddCache adds a cache besides the service's own to `cache.stats`.
+	AddCache func(name string, c cache.Cacher)
+}
+
+// Help describes a service.
+type Help struct {
+	Desc   string
+	Syntax string
+
+	// Example queries with %s for the server's domain. The first
+	// one is shown in the `help` list.
+	Examples []string
+
+	// Upstream indicates whether responses depend on a third party API.
+	Upstream bool
+}
+
+// Entry is a registered service.
+type Entry struct {
+	// Name of the service and its config section. eg: tempo
+	Name string
+
+	// Query suffixes the service is registered for. Defaults to Name.
+	Suffixes []string
+
+	// New returns a new instance of the service with its config section.
+	New func(ko *koanf.Koanf, env *Env) (Service, error)
+
+	// NewGroup is set instead of New for services that share state and
+	// returns a new instance of the Group with its config section.
+	NewGroup func(ko *koanf.Koanf, env *Env) (Group, error)
+}
+
+var (
+	mu      sync.Mutex
+	entries []Entry
+	names   = map[string]bool{}
+)
+
+// Register registers a service. It panics if a service with the same name
+// is already registered.
+func Register(e Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if names[e.Name] {
+		panic(fmt.Sprintf("service %s is already registered", e.Name))
+	}
+	if (e.New == nil) == (e.NewGroup == nil) {
+		panic(fmt.Sprintf("service %s should have one of New or NewGroup", e.Name))
+	}
+	if len(e.Suffixes) == 0 {
+		e.Suffixes = []string{e.Name}
+	}
+
+	names[e.Name] = true
+	entries = append(entries, e)
+}
+
+// Entries returns the registered services in the order of registration.
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	out := make([]Entry, len(entries))
+	copy(out, entries)
+	return out
+}
+
+// APIKeys returns the list of API keys at a config key. The keys can also
+// be a comma separated string, eg: DNSTOYS_FX__API_KEYS="a,b".
+func APIKeys(ko *koanf.Koanf, key string) []string {
+	if k := ko.Strings(key); len(k) > 0 {
+		return k
+	}
+
+	var out []string
+	for _, k := range strings.Split(ko.String(key), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,84 @@
+// Package resolvers identifies public DNS resolver networks by their
+// egress IP ranges.
+package resolvers
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+//go:embed ranges.txt
+var rangesFile string
+
+type network struct {
+	net  *net.IPNet
+	name string
+}
+
+var (
+	networks []network
+	mut      sync.RWMutex
+)
+
+func init() {
+	networks = parse(rangesFile)
+}
+
+// Load parses a ranges file (CIDR<tab>network name per line) and replaces
+// the loaded ranges with it.
+func Load(b []byte) error {
+	n := parse(string(b))
+	if len(n) == 0 {
+		return errors.New("no ranges found")
+	}
+
+	mut.Lock()
+	networks = n
+	mut.Unlock()
+
+	return nil
+}
+
+func parse(file string) []network {
+	var out []network
+
+	s := bufio.NewScanner(strings.NewReader(file))
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		c := strings.SplitN(l, "\t", 2)
+		if len(c) != 2 {
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c[0])
+		if err != nil {
+			continue
+		}
+		out = append(out, network{net: n, name: c[1]})
+	}
+
+	return out
+}
+
+// Lookup returns the name of the public resolver network an IP belongs to,
+// or an empty string if it's unknown.
+func Lookup(ip net.IP) string {
+	mut.RLock()
+	defer mut.RUnlock()
+
+	for _, n := range networks {
+		if n.net.Contains(ip) {
+			return n.name
+		}
+	}
+
+	return ""
+}
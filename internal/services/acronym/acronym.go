@@ -0,0 +1,82 @@
+// Package acronym expands common tech acronyms.
+package acronym
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Acronym expands acronyms from an embedded curated list.
+type Acronym struct {
+	// acronym -> expansions.
+	data map[string][]string
+}
+
+//go:embed acronyms.txt
+var dataB []byte
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "acronym",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Acronym.
+func New() (*Acronym, error) {
+	a := &Acronym{
+		data: make(map[string][]string),
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(dataB))
+	for sc.Scan() {
+		l := sc.Text()
+		if strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		p := strings.SplitN(l, "\t", 2)
+		if len(p) != 2 {
+			continue
+		}
+		a.data[p[0]] = append(a.data[p[0]], p[1])
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Query returns the expansions of an acronym.
+func (a *Acronym) Query(q string) ([]string, error) {
+	exp, ok := a.data[strings.ToLower(q)]
+	if !ok {
+		return nil, errors.New("unknown acronym.")
+	}
+
+	out := make([]string, 0, len(exp))
+	for _, e := range exp {
+		out = append(out, txt.Record(q, strings.ToUpper(q), e))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (a *Acronym) Help() registry.Help {
+	return registry.Help{
+		Desc:     "expand tech acronyms.",
+		Syntax:   "$acronym.acronym",
+		Examples: []string{"dig smtp.acronym @%s", "dig tcp.acronym @%s"},
+	}
+}
@@ -0,0 +1,98 @@
+// Package altitude computes approximate atmospheric conditions at a given elevation.
+package altitude
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Sea level standard pressure in hPa.
+	seaLevelPressure = 1013.25
+
+	// Fraction of oxygen in dry air.
+	oxygenFraction = 20.946
+
+	// The barometric formula used is valid for the troposphere.
+	minAltitude = -500
+	maxAltitude = 11000
+
+	ftToM = 0.3048
+)
+
+var grammar = query.NewGrammar(`(?P<val>-?[0-9\.]+)(?P<unit>m|ft)`, "invalid altitude query. eg: 2500m, 8000ft")
+
+// Altitude computes pressure and boiling points at elevations.
+type Altitude struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "altitude",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Altitude.
+func New() *Altitude {
+	return &Altitude{}
+}
+
+// Query parses an altitude query and returns the answer.
+// Format: $elevation(m|ft). eg: 2500m, 8000ft
+func (a *Altitude) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := args.Float("val")
+	if err != nil {
+		return nil, errors.New("invalid altitude.")
+	}
+	if args["unit"] == "ft" {
+		h = h * ftToM
+	}
+
+	if h < minAltitude || h > maxAltitude {
+		return nil, fmt.Errorf("altitude should be between %dm and %dm.", minAltitude, maxAltitude)
+	}
+
+	var (
+		// International Standard Atmosphere barometric formula.
+		p = seaLevelPressure * math.Pow(1-2.25577e-5*h, 5.25588)
+
+		// Clausius-Clapeyron relation with the latent heat of vaporization
+		// of water (40.66 kJ/mol).
+		bp = 1/(1/373.15-(8.314*math.Log(p/seaLevelPressure))/40660) - 273.15
+
+		ratio = p / seaLevelPressure
+	)
+
+	out := []string{
+		txt.Record(q,
+			fmt.Sprintf("%0.0fm (%0.0fft)", h, h/ftToM),
+			fmt.Sprintf("pressure %0.1f hPa (%0.1f%% of sea level)", p, ratio*100)),
+		txt.Record(q, fmt.Sprintf("water boils at %0.1fC (%0.1fF)", bp, bp*1.8+32)),
+		txt.Record(q,
+			fmt.Sprintf("effective oxygen %0.1f%% (sea level %0.1f%%)", oxygenFraction*ratio, oxygenFraction)),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (a *Altitude) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get air pressure, boiling point, and oxygen at an altitude.",
+		Syntax:   "$altitude(m|ft).altitude",
+		Examples: []string{"dig 2500m.altitude @%s", "dig 29000ft.altitude @%s"},
+	}
+}
@@ -0,0 +1,124 @@
+// Package bac estimates blood alcohol content with the Widmark formula.
+package bac
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Widmark body water ratios.
+	ratioMale   = 0.68
+	ratioFemale = 0.55
+
+	// Alcohol eliminated per hour in BAC %.
+	elimination = 0.015
+
+	// Grams of alcohol in a US standard drink.
+	defaultDrinkGrams = 14
+
+	lbToKg = 0.45359237
+
+	disclaimer = "rough estimate, not medical or legal advice. Never drink and drive."
+)
+
+var grammar = query.NewGrammar(`(?P<weight>[0-9]{1,3}(\.[0-9])?)(?P<unit>kg|lb)-(?P<sex>m|f)-(?P<drinks>[0-9]{1,2}(\.[0-9])?)drinks?-(?P<hours>[0-9]{1,2}(\.[0-9])?)h`,
+	"invalid bac query. eg: 80kg-m-3drinks-2h, 140lb-f-2drinks-1h")
+
+// BAC estimates blood alcohol content.
+type BAC struct {
+	drinkGrams float64
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "bac",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(ko.Float64("drink_grams")), nil
+		},
+	})
+}
+
+// New returns a new instance of BAC where a drink has drinkGrams of alcohol.
+func New(drinkGrams float64) *BAC {
+	if drinkGrams <= 0 {
+		drinkGrams = defaultDrinkGrams
+	}
+
+	return &BAC{drinkGrams: drinkGrams}
+}
+
+// Query returns the estimated BAC of a person of a weight and sex after a
+// number of drinks over a number of hours.
+// Format: $weight(kg|lb)-(m|f)-$ndrinks-$hoursh. eg: 80kg-m-3drinks-2h
+func (b *BAC) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	w, _ := args.Float("weight")
+	if args["unit"] == "lb" {
+		w *= lbToKg
+	}
+	if w < 20 || w > 300 {
+		return nil, errors.New("invalid weight. Should be 20-300kg.")
+	}
+
+	r := ratioMale
+	if args["sex"] == "f" {
+		r = ratioFemale
+	}
+
+	drinks, _ := args.Float("drinks")
+	hours, _ := args.Float("hours")
+
+	// Widmark: grams of alcohol / (body weight in grams x r) as a %,
+	// less what's eliminated over the hours.
+	bac := math.Max(0, b.drinkGrams*drinks/(w*1000*r)*100-elimination*hours)
+
+	out := []string{
+		txt.Record(q, fmt.Sprintf("%0.3f%% BAC", bac), fmt.Sprintf("%0.2f g/L", bac*10), level(bac)),
+		txt.Record(q,
+			fmt.Sprintf("%g drink(s) of %gg alcohol over %gh", drinks, b.drinkGrams, hours),
+			fmt.Sprintf("~%0.1fh until 0", bac/elimination)),
+		txt.Record(q, disclaimer),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (b *BAC) Help() registry.Help {
+	return registry.Help{
+		Desc:     "estimate blood alcohol content from weight, sex, drinks, and hours with the Widmark formula. Not medical advice.",
+		Syntax:   "$weight(kg|lb)-(m|f)-$ndrinks-$hoursh.bac",
+		Examples: []string{"dig 80kg-m-3drinks-2h.bac @%s", "dig 140lb-f-2drinks-1h.bac @%s"},
+	}
+}
+
+// level returns the typical effects at a BAC %.
+func level(bac float64) string {
+	switch {
+	case bac == 0:
+		return "sober"
+	case bac < 0.03:
+		return "mild relaxation"
+	case bac < 0.06:
+		return "impaired judgement"
+	case bac < 0.1:
+		return "impaired coordination. Over many driving limits"
+	case bac < 0.2:
+		return "intoxicated"
+	case bac < 0.3:
+		return "confusion, risk of blackout"
+	}
+
+	return "severe. Risk of alcohol poisoning"
+}
@@ -6,10 +6,23 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
 )
 
 type Base struct{}
 
+func init() {
+	registry.Register(registry.Entry{
+		Name: "base",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
 // New returns a new instance of Base.
 func New() *Base {
 	return &Base{}
@@ -51,11 +64,15 @@ func (n *Base) Query(q string) ([]string, error) {
 
 	res := strconv.FormatInt(num, toBase)
 
-	r := fmt.Sprintf("%s 1 TXT \"%s %s = %s %s\"", q, reg[1], reg[2], res, reg[3])
+	r := txt.Record(q, fmt.Sprintf("%s %s = %s %s", reg[1], reg[2], res, reg[3]))
 	return []string{r}, nil
 }
 
-// Dump is not implemented in this package.
-func (n *Base) Dump() ([]byte, error) {
-	return nil, nil
+// Help returns the help text of the service.
+func (n *Base) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert numbers from one base to another",
+		Syntax:   "$number$from-$to.base",
+		Examples: []string{"dig 100dec-hex.base @%s", "dig ffhex-dec.base @%s"},
+	}
 }
@@ -0,0 +1,123 @@
+// Package beaufort converts wind speeds between units and to the Beaufort
+// and Saffir-Simpson hurricane scales.
+package beaufort
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	kmhToMS = 1 / 3.6
+	mphToMS = 0.44704
+	ktToMS  = 1852.0 / 3600
+
+	// Fastest recorded surface wind is ~113 m/s.
+	maxSpeed = 150
+)
+
+var grammar = query.NewGrammar(`(?P<speed>[0-9]{1,4}(\.[0-9]{1,2})?)(?P<unit>kmh|mph|kt|knots|ms)`,
+	"invalid beaufort query. eg: 45kmh, 30mph, 20kt, 12ms")
+
+// Beaufort scale: the upper bound (m/s) and description of each force.
+// Anything faster than the last bound is force 12.
+var scale = []struct {
+	max  float64
+	desc string
+}{
+	{0.5, "calm"},
+	{1.5, "light air"},
+	{3.3, "light breeze"},
+	{5.5, "gentle breeze"},
+	{7.9, "moderate breeze"},
+	{10.7, "fresh breeze"},
+	{13.8, "strong breeze"},
+	{17.1, "near gale"},
+	{20.7, "gale"},
+	{24.4, "strong gale"},
+	{28.4, "storm"},
+	{32.6, "violent storm"},
+}
+
+// Saffir-Simpson hurricane categories by the minimum sustained wind (knots).
+var categories = []float64{64, 83, 96, 113, 137}
+
+// Beaufort converts wind speeds.
+type Beaufort struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "beaufort",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Beaufort.
+func New() *Beaufort {
+	return &Beaufort{}
+}
+
+// Query returns the Beaufort force and hurricane category of a wind speed
+// along with the speed in other units.
+// Format: $speed(kmh|mph|kt|ms). eg: 45kmh
+func (b *Beaufort) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	v, _ := args.Float("speed")
+	ms := v
+	switch args["unit"] {
+	case "kmh":
+		ms = v * kmhToMS
+	case "mph":
+		ms = v * mphToMS
+	case "kt", "knots":
+		ms = v * ktToMS
+	}
+	if ms > maxSpeed {
+		return nil, errors.New("wind speed too high.")
+	}
+
+	force, desc := 12, "hurricane force"
+	for i, s := range scale {
+		if ms <= s.max {
+			force, desc = i, s.desc
+			break
+		}
+	}
+
+	kt := ms / ktToMS
+	cat := "below hurricane strength"
+	for i := len(categories) - 1; i >= 0; i-- {
+		if kt >= categories[i] {
+			cat = fmt.Sprintf("category %d hurricane", i+1)
+			break
+		}
+	}
+
+	return []string{
+		txt.Record(q,
+			fmt.Sprintf("%0.1f km/h", ms/kmhToMS), fmt.Sprintf("%0.1f mph", ms/mphToMS),
+			fmt.Sprintf("%0.1f kt", kt), fmt.Sprintf("%0.1f m/s", ms)),
+		txt.Record(q, fmt.Sprintf("Beaufort %d", force), desc),
+		txt.Record(q, "Saffir-Simpson", cat),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (b *Beaufort) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert a wind speed to the Beaufort scale, hurricane category, and km/h, mph, knots, and m/s.",
+		Syntax:   "$speed(kmh|mph|kt|ms).beaufort",
+		Examples: []string{"dig 45kmh.beaufort @%s", "dig 120kt.beaufort @%s"},
+	}
+}
@@ -0,0 +1,101 @@
+// Package biorhythm computes the physical, emotional, and intellectual
+// biorhythm cycles of the day for a birth date.
+package biorhythm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var grammar = query.NewGrammar(`(?P<date>[0-9]{4}-[0-9]{2}-[0-9]{2})`, "invalid birth date. eg: 1990-05-17")
+
+// Cycles and their lengths in days. They all start at 0 and rise on the
+// day of birth.
+var cycles = []struct {
+	name string
+	days float64
+}{
+	{"physical", 23},
+	{"emotional", 28},
+	{"intellectual", 33},
+}
+
+// Biorhythm computes biorhythms.
+type Biorhythm struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "biorhythm",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Biorhythm.
+func New() *Biorhythm {
+	return &Biorhythm{}
+}
+
+// Query returns the day's (UTC) cycle percentages for a birth date.
+// Format: $yyyy-$mm-$dd. eg: 1990-05-17
+func (b *Biorhythm) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	birth, err := time.Parse("2006-01-02", args["date"])
+	if err != nil {
+		return nil, errors.New("invalid birth date. eg: 1990-05-17")
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if birth.After(today) {
+		return nil, errors.New("birth date is in the future.")
+	}
+
+	days := math.Round(today.Sub(birth).Hours() / 24)
+
+	out := make([]string, 0, len(cycles)+1)
+	out = append(out, txt.Record(q,
+		today.Format("Mon, 02 Jan 2006"),
+		fmt.Sprintf("day %0.f since birth", days)))
+	for _, c := range cycles {
+		var (
+			v    = math.Sin(2 * math.Pi * days / c.days)
+			next = math.Sin(2 * math.Pi * (days + 1) / c.days)
+		)
+
+		trend := "rising"
+		if next < v {
+			trend = "falling"
+		}
+
+		// The days on which a cycle crosses zero are "critical".
+		if (v >= 0) != (next >= 0) || math.Abs(v) < 0.05 {
+			trend += ", critical"
+		}
+
+		out = append(out, txt.Record(q, c.name, fmt.Sprintf("%d%%", int(math.Round(v*100))), trend))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (b *Biorhythm) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the day's physical, emotional, and intellectual biorhythm cycles for a birth date.",
+		Syntax:   "$yyyy-$mm-$dd.biorhythm",
+		Examples: []string{"dig 1990-05-17.biorhythm @%s"},
+	}
+}
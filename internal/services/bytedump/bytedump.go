@@ -0,0 +1,124 @@
+// Package bytedump returns binary and hex dumps of the UTF-8 bytes of text.
+package bytedump
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Max length of the text to dump.
+	maxLen = 100
+
+	// Number of bytes per TXT string.
+	binChunk = 8
+	hexChunk = 16
+)
+
+// Dump dumps text bytes with a formatter.
+type Dump struct {
+	fn   func(b []byte) []string
+	help registry.Help
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "bytedump",
+		Suffixes: []string{"bin", "hexdump"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"bin":     NewBin(),
+				"hexdump": NewHex(),
+			}, nil
+		},
+	})
+}
+
+// NewBin returns a binary Dump.
+func NewBin() *Dump {
+	return &Dump{fn: binDump, help: registry.Help{
+		Desc:     "dump the bytes of text in binary.",
+		Syntax:   "$text.bin",
+		Examples: []string{"dig hi.bin @%s", "dig a.bin @%s"},
+	}}
+}
+
+// NewHex returns a hex Dump.
+func NewHex() *Dump {
+	return &Dump{fn: hexDump, help: registry.Help{
+		Desc:     "dump the bytes of text in hex.",
+		Syntax:   "$text.hexdump",
+		Examples: []string{"dig hello.hexdump @%s", "dig dns-toys.hexdump @%s"},
+	}}
+}
+
+// Query dumps the bytes of the given text. Words may be separated by -
+// which are dumped as spaces.
+func (d *Dump) Query(q string) ([]string, error) {
+	if len(q) == 0 || len(q) > maxLen {
+		return nil, fmt.Errorf("text should be 1 to %d characters.", maxLen)
+	}
+
+	b := []byte(strings.ReplaceAll(q, "-", " "))
+
+	r := txt.Record(q, d.fn(b)...)
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Dump) Help() registry.Help {
+	return d.help
+}
+
+// binDump returns the bytes in binary with binChunk bytes per string.
+func binDump(b []byte) []string {
+	out := make([]string, 0, len(b)/binChunk+1)
+	for i := 0; i < len(b); i += binChunk {
+		end := i + binChunk
+		if end > len(b) {
+			end = len(b)
+		}
+
+		s := make([]string, 0, binChunk)
+		for _, c := range b[i:end] {
+			s = append(s, fmt.Sprintf("%08b", c))
+		}
+		out = append(out, strings.Join(s, " "))
+	}
+
+	return out
+}
+
+// hexDump returns a canonical hex+ASCII dump (like hexdump -C) with
+// hexChunk bytes per string.
+func hexDump(b []byte) []string {
+	out := make([]string, 0, len(b)/hexChunk+1)
+	for i := 0; i < len(b); i += hexChunk {
+		end := i + hexChunk
+		if end > len(b) {
+			end = len(b)
+		}
+
+		var (
+			hex   = make([]string, 0, hexChunk)
+			ascii strings.Builder
+		)
+		for _, c := range b[i:end] {
+			hex = append(hex, fmt.Sprintf("%02x", c))
+
+			if c >= 0x20 && c < 0x7f {
+				ascii.WriteByte(c)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+
+		out = append(out, fmt.Sprintf("%08x  %-47s  |%s|", i, strings.Join(hex, " "), ascii.String()))
+	}
+
+	return out
+}
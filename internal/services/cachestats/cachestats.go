@@ -0,0 +1,112 @@
+// Package cachestats returns the statistics of the caches held by services.
+package cachestats
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/txt"
+)
+
+// Stats are computed at most once in this period as estimating the memory
+// of large caches is expensive.
+const refreshInterval = time.Second * 30
+
+// CacheStats returns the statistics of a set of named caches.
+type CacheStats struct {
+	caches map[string]cache.Cacher
+	names  []string
+
+	out       []string
+	updatedAt time.Time
+	mut       sync.Mutex
+}
+
+// New returns a new instance of CacheStats for the given caches
+// keyed by service name.
+func New(caches map[string]cache.Cacher) *CacheStats {
+	names := make([]string, 0, len(caches))
+	for n := range caches {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return &CacheStats{caches: caches, names: names}
+}
+
+// Query returns one TXT record per cache with its entry count, memory
+// estimate, hit ratio, and the ages of the oldest and newest entries.
+// Format: cache
+func (c *CacheStats) Query(q string) ([]string, error) {
+	if !strings.EqualFold(q, "cache") {
+		return nil, errors.New("invalid query. eg: cache.stats")
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := time.Now()
+	if c.out != nil && now.Sub(c.updatedAt) < refreshInterval {
+		return c.out, nil
+	}
+
+	out := make([]string, 0, len(c.names))
+	for _, n := range c.names {
+		st := c.caches[n].CacheStats()
+
+		mem := "n/a"
+		if st.Bytes >= 0 {
+			mem = fmtBytes(st.Bytes)
+		}
+
+		ratio := "n/a"
+		if r := st.HitRatio(); r >= 0 {
+			ratio = fmt.Sprintf("%0.1f%%", r)
+		}
+
+		out = append(out, txt.Record(q,
+			n, fmt.Sprintf("entries %d", st.Entries),
+			fmt.Sprintf("memory %s", mem), fmt.Sprintf("hit ratio %s", ratio),
+			fmt.Sprintf("oldest %s", fmtAge(now, st.Oldest)),
+			fmt.Sprintf("newest %s", fmtAge(now, st.Newest))))
+	}
+
+	if len(out) == 0 {
+		out = append(out, txt.Record(q, "no caches"))
+	}
+
+	c.out = out
+	c.updatedAt = now
+
+	return out, nil
+}
+
+// fmtAge returns the age of a time relative to now, eg: 2h5m0s.
+func fmtAge(now, t time.Time) string {
+	if t.IsZero() {
+		return "n/a"
+	}
+
+	return now.Sub(t).Round(time.Second).String()
+}
+
+// fmtBytes returns a human readable byte size, eg: 1.5 MB.
+func fmtBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%0.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
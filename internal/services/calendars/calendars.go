@@ -0,0 +1,188 @@
+// Package calendars converts Gregorian dates to the Hijri (Islamic),
+// Hebrew, and Indian national (Saka) calendars and back with arithmetic
+// calendar algorithms.
+package calendars
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Dates are limited to four digit years on either side.
+const (
+	minYear = 1
+	maxYear = 9999
+)
+
+var grammar = query.NewGrammar(`today|(?P<from>from-)?(?P<y>[0-9]{1,4})-(?P<m>[0-9]{1,2})-(?P<d>[0-9]{1,2})`,
+	"invalid date. eg: 2025-03-14, today, from-1446-09-14")
+
+// Calendar is a calendar conversion service.
+type Calendar struct {
+	name  string
+	title string
+	era   string
+
+	// A from- query for the help.
+	example string
+
+	// Shown with the dates, eg: caveats of the calculation.
+	note string
+
+	toFixed       func(date) int
+	fromGregorian func(date) date
+	monthName     func(date) string
+}
+
+var (
+	hijriMonths = []string{"Muharram", "Safar", "Rabi al-Awwal", "Rabi al-Thani", "Jumada al-Ula",
+		"Jumada al-Akhirah", "Rajab", "Shaban", "Ramadan", "Shawwal", "Dhu al-Qadah", "Dhu al-Hijjah"}
+
+	// From Nisan, the first month in the Hebrew month numbering.
+	hebrewMonths = []string{"Nisan", "Iyar", "Sivan", "Tammuz", "Av", "Elul", "Tishrei",
+		"Cheshvan", "Kislev", "Tevet", "Shevat", "Adar", "Adar II"}
+
+	sakaMonths = []string{"Chaitra", "Vaishakha", "Jyeshtha", "Ashadha", "Shravana", "Bhadra",
+		"Ashvin", "Kartika", "Agrahayana", "Pausha", "Magha", "Phalguna"}
+)
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "hijri",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewHijri(), nil
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "hebrew",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewHebrew(), nil
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "saka",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewSaka(), nil
+		},
+	})
+}
+
+// NewHijri returns the tabular Islamic calendar.
+func NewHijri() *Calendar {
+	return &Calendar{
+		name:          "hijri",
+		title:         "Hijri (Islamic)",
+		example:       "from-1446-09-14",
+		era:           "AH",
+		note:          "tabular calendar. May differ by a day from local moon sighting.",
+		toFixed:       fixedFromIslamic,
+		fromGregorian: func(g date) date { return islamicFromFixed(fixedFromGregorian(g)) },
+		monthName:     func(d date) string { return hijriMonths[d.m-1] },
+	}
+}
+
+// NewHebrew returns the Hebrew calendar. Months are numbered from Nisan
+// (1) with Tishrei as 7 and Adar II as 13 in leap years.
+func NewHebrew() *Calendar {
+	return &Calendar{
+		name:          "hebrew",
+		title:         "Hebrew",
+		example:       "from-5785-12-14",
+		era:           "AM",
+		note:          "months are numbered from Nisan (1). Tishrei is 7.",
+		toFixed:       fixedFromHebrew,
+		fromGregorian: func(g date) date { return hebrewFromFixed(fixedFromGregorian(g)) },
+		monthName: func(d date) string {
+			if d.m == 12 && hebrewLeap(d.y) {
+				return "Adar I"
+			}
+			return hebrewMonths[d.m-1]
+		},
+	}
+}
+
+// NewSaka returns the Indian national calendar.
+func NewSaka() *Calendar {
+	return &Calendar{
+		name:          "saka",
+		title:         "Indian national (Saka)",
+		example:       "from-1946-12-23",
+		era:           "Saka",
+		note:          "Indian national calendar.",
+		toFixed:       fixedFromSaka,
+		fromGregorian: func(g date) date { return sakaFromFixed(fixedFromGregorian(g)) },
+		monthName:     func(d date) string { return sakaMonths[d.m-1] },
+	}
+}
+
+// Query converts a Gregorian date to the calendar or, with the from-
+// prefix, a date in the calendar to Gregorian.
+// Format: today, $yyyy-$mm-$dd, or from-$yyyy-$mm-$dd.
+// eg: 2025-03-14, from-1446-09-14
+func (c *Calendar) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		y, _ = args.Int("y")
+		m, _ = args.Int("m")
+		d, _ = args.Int("d")
+		in   = date{y, m, d}
+	)
+
+	if q == "today" {
+		now := time.Now().UTC()
+		in = date{now.Year(), int(now.Month()), now.Day()}
+	}
+
+	var g, cd date
+	if args["from"] != "" {
+		// Dates that don't exist in the calendar don't survive the
+		// round trip. eg: day 30 of a 29 day month.
+		if in.y < minYear || in.y > maxYear || in.m < 1 || in.m > 13 || in.d < 1 || in.d > 31 {
+			return nil, fmt.Errorf("invalid %s date.", c.name)
+		}
+		g = gregorianFromFixed(c.toFixed(in))
+		if c.fromGregorian(g) != in {
+			return nil, fmt.Errorf("invalid %s date.", c.name)
+		}
+		cd = in
+	} else {
+		t := time.Date(in.y, time.Month(in.m), in.d, 0, 0, 0, 0, time.UTC)
+		if in.y < minYear || in.y > maxYear || t.Day() != in.d || int(t.Month()) != in.m {
+			return nil, errors.New("invalid date.")
+		}
+		g = in
+		cd = c.fromGregorian(g)
+	}
+
+	t := time.Date(g.y, time.Month(g.m), g.d, 0, 0, 0, 0, time.UTC)
+	out := []string{
+		txt.Record(q,
+			t.Format("Mon, 02 Jan 2006"),
+			fmt.Sprintf("%d %s %d %s", cd.d, c.monthName(cd), cd.y, c.era),
+			fmt.Sprintf("%04d-%02d-%02d", cd.y, cd.m, cd.d)),
+		txt.Record(q, c.note),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *Calendar) Help() registry.Help {
+	return registry.Help{
+		Desc:     fmt.Sprintf("convert Gregorian dates to the %s calendar and back.", c.title),
+		Syntax:   fmt.Sprintf("$yyyy-$mm-$dd.%s, today.%s, or from-$yyyy-$mm-$dd.%s", c.name, c.name, c.name),
+		Examples: []string{"dig 2025-03-14." + c.name + " @%s", "dig " + c.example + "." + c.name + " @%s"},
+	}
+}
@@ -0,0 +1,257 @@
+package calendars
+
+// Conversions between calendars go through fixed day numbers (days since
+// Jan 1, 1 CE in the proleptic Gregorian calendar is day 1) with the
+// arithmetic algorithms from Reingold and Dershowitz, Calendrical
+// Calculations.
+
+const (
+	// Fixed dates of the calendar epochs.
+	islamicEpoch = 227015   // Jul 16, 622 CE (Julian).
+	hebrewEpoch  = -1373427 // Oct 7, 3761 BCE (Julian).
+
+	// Hebrew month numbers. Months are numbered from Nisan but the
+	// year starts in Tishrei.
+	nisan  = 1
+	tishri = 7
+)
+
+// date is a day in a calendar.
+type date struct {
+	y, m, d int
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func mod(a, b int) int {
+	return a - b*floorDiv(a, b)
+}
+
+// Gregorian.
+
+func gregorianLeap(y int) bool {
+	return mod(y, 4) == 0 && (mod(y, 100) != 0 || mod(y, 400) == 0)
+}
+
+func fixedFromGregorian(g date) int {
+	f := 365*(g.y-1) + floorDiv(g.y-1, 4) - floorDiv(g.y-1, 100) + floorDiv(g.y-1, 400) +
+		floorDiv(367*g.m-362, 12) + g.d
+	if g.m > 2 {
+		if gregorianLeap(g.y) {
+			f--
+		} else {
+			f -= 2
+		}
+	}
+	return f
+}
+
+func gregorianYearFromFixed(f int) int {
+	var (
+		d0   = f - 1
+		n400 = floorDiv(d0, 146097)
+		d1   = mod(d0, 146097)
+		n100 = floorDiv(d1, 36524)
+		d2   = mod(d1, 36524)
+		n4   = floorDiv(d2, 1461)
+		d3   = mod(d2, 1461)
+		n1   = floorDiv(d3, 365)
+		y    = 400*n400 + 100*n100 + 4*n4 + n1
+	)
+	if n100 == 4 || n1 == 4 {
+		return y
+	}
+	return y + 1
+}
+
+func gregorianFromFixed(f int) date {
+	y := gregorianYearFromFixed(f)
+
+	prior := f - fixedFromGregorian(date{y, 1, 1})
+	corr := 0
+	if f >= fixedFromGregorian(date{y, 3, 1}) {
+		corr = 2
+		if gregorianLeap(y) {
+			corr = 1
+		}
+	}
+
+	m := floorDiv(12*(prior+corr)+373, 367)
+	return date{y, m, f - fixedFromGregorian(date{y, m, 1}) + 1}
+}
+
+// Islamic (tabular, civil epoch).
+
+func fixedFromIslamic(i date) int {
+	return i.d + 29*(i.m-1) + floorDiv(6*i.m-1, 11) + (i.y-1)*354 + floorDiv(3+11*i.y, 30) + islamicEpoch - 1
+}
+
+func islamicFromFixed(f int) date {
+	y := floorDiv(30*(f-islamicEpoch)+10646, 10631)
+	prior := f - fixedFromIslamic(date{y, 1, 1})
+	m := floorDiv(11*prior+330, 325)
+	return date{y, m, f - fixedFromIslamic(date{y, m, 1}) + 1}
+}
+
+// Hebrew.
+
+func hebrewLeap(y int) bool {
+	return mod(7*y+1, 19) < 7
+}
+
+func hebrewLastMonth(y int) int {
+	if hebrewLeap(y) {
+		return 13
+	}
+	return 12
+}
+
+// hebrewElapsedDays returns the days from the epoch to the molad of
+// Tishrei of a year, delayed if it falls on Sun, Wed, or Fri.
+func hebrewElapsedDays(y int) int {
+	var (
+		months = floorDiv(235*y-234, 19)
+		parts  = 12084 + 13753*months
+		days   = 29*months + floorDiv(parts, 25920)
+	)
+	if mod(3*(days+1), 7) < 3 {
+		return days + 1
+	}
+	return days
+}
+
+// hebrewYearCorrection returns the delay of a new year to keep the
+// lengths of the adjacent years valid.
+func hebrewYearCorrection(y int) int {
+	var (
+		ny0 = hebrewElapsedDays(y - 1)
+		ny1 = hebrewElapsedDays(y)
+		ny2 = hebrewElapsedDays(y + 1)
+	)
+	switch {
+	case ny2-ny1 == 356:
+		return 2
+	case ny1-ny0 == 382:
+		return 1
+	}
+	return 0
+}
+
+func hebrewNewYear(y int) int {
+	return hebrewEpoch + hebrewElapsedDays(y) + hebrewYearCorrection(y)
+}
+
+func hebrewYearDays(y int) int {
+	return hebrewNewYear(y+1) - hebrewNewYear(y)
+}
+
+func hebrewMonthDays(y, m int) int {
+	switch {
+	case m == 2 || m == 4 || m == 6 || m == 10 || m == 13:
+		return 29
+	case m == 12 && !hebrewLeap(y):
+		return 29
+	case m == 8 && mod(hebrewYearDays(y), 10) != 5:
+		// Cheshvan is long only in complete years (355, 385 days).
+		return 29
+	case m == 9 && mod(hebrewYearDays(y), 10) == 3:
+		// Kislev is short in deficient years (353, 383 days).
+		return 29
+	}
+	return 30
+}
+
+func fixedFromHebrew(h date) int {
+	f := hebrewNewYear(h.y) + h.d - 1
+	if h.m < tishri {
+		for m := tishri; m <= hebrewLastMonth(h.y); m++ {
+			f += hebrewMonthDays(h.y, m)
+		}
+		for m := nisan; m < h.m; m++ {
+			f += hebrewMonthDays(h.y, m)
+		}
+	} else {
+		for m := tishri; m < h.m; m++ {
+			f += hebrewMonthDays(h.y, m)
+		}
+	}
+	return f
+}
+
+func hebrewFromFixed(f int) date {
+	// Average Hebrew year length in days is 35975351/98496.
+	approx := floorDiv((f-hebrewEpoch)*98496, 35975351) + 1
+
+	// The approximation can be a year off either way.
+	y := approx - 1
+	for hebrewNewYear(y+1) <= f {
+		y++
+	}
+
+	m := tishri
+	if f < fixedFromHebrew(date{y, nisan, 1}) {
+		for f > fixedFromHebrew(date{y, m, hebrewMonthDays(y, m)}) {
+			m++
+		}
+	} else {
+		m = nisan
+		for f > fixedFromHebrew(date{y, m, hebrewMonthDays(y, m)}) {
+			m++
+		}
+	}
+
+	return date{y, m, f - fixedFromHebrew(date{y, m, 1}) + 1}
+}
+
+// Indian national (Saka). The year starts on Mar 22 (Mar 21 in Gregorian
+// leap years) and is 78 years behind the Gregorian year.
+
+const sakaOffset = 78
+
+func sakaMonthDays(y, m int) int {
+	switch {
+	case m == 1 && gregorianLeap(y+sakaOffset):
+		return 31
+	case m >= 2 && m <= 6:
+		return 31
+	}
+	return 30
+}
+
+func sakaNewYear(y int) int {
+	gy := y + sakaOffset
+	d := 22
+	if gregorianLeap(gy) {
+		d = 21
+	}
+	return fixedFromGregorian(date{gy, 3, d})
+}
+
+func fixedFromSaka(s date) int {
+	f := sakaNewYear(s.y) + s.d - 1
+	for m := 1; m < s.m; m++ {
+		f += sakaMonthDays(s.y, m)
+	}
+	return f
+}
+
+func sakaFromFixed(f int) date {
+	y := gregorianYearFromFixed(f) - sakaOffset
+	if f < sakaNewYear(y) {
+		y--
+	}
+
+	d := f - sakaNewYear(y) + 1
+	m := 1
+	for d > sakaMonthDays(y, m) {
+		d -= sakaMonthDays(y, m)
+		m++
+	}
+	return date{y, m, d}
+}
@@ -0,0 +1,93 @@
+// Package checksum computes and verifies checksums of text.
+package checksum
+
+import (
+	"fmt"
+	"hash/adler32"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max length of the text to checksum.
+const maxLen = 200
+
+// Checksum computes a 32 bit checksum of text.
+type Checksum struct {
+	name string
+	fn   func([]byte) uint32
+	help registry.Help
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "checksum",
+		Suffixes: []string{"crc32", "adler32"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"crc32":   NewCRC32(),
+				"adler32": NewAdler32(),
+			}, nil
+		},
+	})
+}
+
+// NewCRC32 returns a CRC-32 (IEEE) Checksum.
+func NewCRC32() *Checksum {
+	return &Checksum{name: "crc32", fn: crc32.ChecksumIEEE, help: registry.Help{
+		Desc:     "compute or verify the CRC-32 checksum of text.",
+		Syntax:   "$text.crc32 or verify-$hex-$text.crc32",
+		Examples: []string{"dig hello.crc32 @%s", "dig verify-3610a686-hello.crc32 @%s"},
+	}}
+}
+
+// NewAdler32 returns an Adler-32 Checksum.
+func NewAdler32() *Checksum {
+	return &Checksum{name: "adler32", fn: adler32.Checksum, help: registry.Help{
+		Desc:     "compute or verify the Adler-32 checksum of text.",
+		Syntax:   "$text.adler32 or verify-$hex-$text.adler32",
+		Examples: []string{"dig hello.adler32 @%s", "dig verify-062c0215-hello.adler32 @%s"},
+	}}
+}
+
+// Query returns the checksum of the given text, or with the
+// verify-$hexdigest-$text format, verifies the digest against it.
+func (c *Checksum) Query(q string) ([]string, error) {
+	if len(q) == 0 || len(q) > maxLen {
+		return nil, fmt.Errorf("text should be 1 to %d characters.", maxLen)
+	}
+
+	// Verify mode.
+	if p := strings.SplitN(q, "-", 3); len(p) == 3 && p[0] == "verify" {
+		want, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(p[1]), "0x"), 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s digest: %s.", c.name, p[1])
+		}
+
+		var (
+			got    = c.fn([]byte(p[2]))
+			result = "MISMATCH"
+		)
+		if uint32(want) == got {
+			result = "OK"
+		}
+
+		r := txt.Record(q,
+			p[2], fmt.Sprintf("%s: %08x", c.name, got), fmt.Sprintf("expected: %08x", want),
+			result)
+		return []string{r}, nil
+	}
+
+	sum := c.fn([]byte(q))
+	r := txt.Record(q, q, fmt.Sprintf("%s: %08x", c.name, sum), fmt.Sprintf("%d", sum))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (c *Checksum) Help() registry.Help {
+	return c.help
+}
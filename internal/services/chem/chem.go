@@ -0,0 +1,164 @@
+// Package chem computes the pH of solutions and solves dilutions.
+package chem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Ion product of water at 25C, where pH + pOH = 14.
+const pKw = 14
+
+const num = `[0-9]{1,6}(\.[0-9]{1,9})?(e-?[0-9]{1,2})?`
+
+var (
+	phGrammar = query.NewGrammar(`(?P<conc>`+num+`)m`,
+		"invalid ph query. eg: 0.001m, 2.5e-8m")
+	diluteGrammar = query.NewGrammar(`(?P<c1>`+num+`)(?P<cu1>m|mm|um|nm|pc|x)-(?P<v1>`+num+`)(?P<vu>l|ml|ul)-(?P<c2>`+num+`)(?P<cu2>m|mm|um|nm|pc|x)`,
+		"invalid dilute query. eg: 2m-50ml-0.5m, 10pc-100ml-2pc")
+)
+
+// Molar concentration units in M.
+var molar = map[string]float64{
+	"m":  1,
+	"mm": 1e-3,
+	"um": 1e-6,
+	"nm": 1e-9,
+}
+
+// Display names of units.
+var unitNames = map[string]string{
+	"m": "M", "mm": "mM", "um": "uM", "nm": "nM", "pc": "%", "x": "x",
+	"l": "L", "ml": "mL", "ul": "uL",
+}
+
+// PH computes pH.
+type PH struct{}
+
+// Dilute solves dilutions.
+type Dilute struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "ph",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return &PH{}, nil
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "dilute",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return &Dilute{}, nil
+		},
+	})
+}
+
+// Query returns the pH, pOH, and [OH-] of a H+ concentration in mol/L.
+// Format: $concm. eg: 0.001m
+func (p *PH) Query(q string) ([]string, error) {
+	args, err := phGrammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := strconv.ParseFloat(args["conc"], 64)
+	if err != nil || h <= 0 || h > 15 {
+		return nil, errors.New("invalid concentration. Should be > 0 and <= 15 M.")
+	}
+
+	var (
+		ph  = -math.Log10(h)
+		poh = pKw - ph
+	)
+
+	return []string{
+		txt.Record(q, fmt.Sprintf("[H+] %g M", h), fmt.Sprintf("pH %0.2f", ph), acidity(ph)),
+		txt.Record(q,
+			fmt.Sprintf("[OH-] %0.3g M", math.Pow(10, -poh)), fmt.Sprintf("pOH %0.2f", poh),
+			"at 25C"),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (p *PH) Help() registry.Help {
+	return registry.Help{
+		Desc:     "compute the pH and pOH of a solution from its H+ concentration in mol/L.",
+		Syntax:   "$concentrationm.ph",
+		Examples: []string{"dig 0.001m.ph @%s", "dig 2.5e-8m.ph @%s"},
+	}
+}
+
+// Query solves C1V1 = C2V2 for the final volume to dilute a stock solution
+// to and the solvent to add.
+// Format: $c1-$v1-$c2. eg: 2m-50ml-0.5m
+func (d *Dilute) Query(q string) ([]string, error) {
+	args, err := diluteGrammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		c1, _ = strconv.ParseFloat(args["c1"], 64)
+		v1, _ = strconv.ParseFloat(args["v1"], 64)
+		c2, _ = strconv.ParseFloat(args["c2"], 64)
+		cu1   = args["cu1"]
+		cu2   = args["cu2"]
+		vu    = args["vu"]
+	)
+
+	// Molar units can be mixed. Others have to be the same.
+	m1, ok1 := molar[cu1]
+	m2, ok2 := molar[cu2]
+	switch {
+	case ok1 && ok2:
+		c2 = c2 * m2 / m1
+	case cu1 != cu2:
+		return nil, fmt.Errorf("the concentrations should be in the same units (%s and %s).", unitNames[cu1], unitNames[cu2])
+	}
+
+	if c1 <= 0 || v1 <= 0 || c2 <= 0 {
+		return nil, errors.New("the concentrations and volume should be more than 0.")
+	}
+	if c2 >= c1 {
+		return nil, errors.New("the final concentration should be lower than the stock concentration.")
+	}
+
+	v2 := c1 * v1 / c2
+	return []string{
+		txt.Record(q,
+			fmt.Sprintf("dilute %g %s of %g %s to %s %s", v1, unitNames[vu], c1, unitNames[cu1], args["c2"], unitNames[cu2])),
+		txt.Record(q,
+			fmt.Sprintf("final volume %0.4g %s", v2, unitNames[vu]),
+			fmt.Sprintf("add %0.4g %s of solvent", v2-v1, unitNames[vu]),
+			fmt.Sprintf("%0.4g times dilution", c1/c2)),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Dilute) Help() registry.Help {
+	return registry.Help{
+		Desc:     "solve C1V1 = C2V2 for the final volume and solvent to add to dilute a stock solution.",
+		Syntax:   "$c1(m|mm|um|nm|pc|x)-$v1(l|ml|ul)-$c2.dilute",
+		Examples: []string{"dig 2m-50ml-0.5m.dilute @%s", "dig 10pc-100ml-2pc.dilute @%s"},
+	}
+}
+
+// acidity describes a pH.
+func acidity(ph float64) string {
+	switch {
+	case ph < 6.95:
+		return "acidic"
+	case ph > 7.05:
+		return "basic"
+	}
+
+	return "neutral"
+}
@@ -0,0 +1,200 @@
+// Package chess looks up chess openings by their moves.
+package chess
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max number of moves in a query.
+const maxMoves = 30
+
+type opening struct {
+	ECO   string
+	Name  string
+	Moves []string
+
+	// Normalized moves for matching.
+	keys []string
+}
+
+// Chess looks up openings from the embedded ECO database.
+type Chess struct {
+	openings []opening
+}
+
+//go:embed openings.tsv
+var dataB []byte
+
+var keyCleaner = strings.NewReplacer("x", "", "+", "", "#", "", "=", "", "-", "", "0", "o")
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "chess",
+		Suffixes: []string{"opening"},
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Chess.
+func New() (*Chess, error) {
+	c := &Chess{}
+
+	sc := bufio.NewScanner(bytes.NewReader(dataB))
+	for sc.Scan() {
+		l := strings.Split(sc.Text(), "\t")
+		if len(l) != 3 {
+			continue
+		}
+
+		moves := strings.Fields(l[2])
+		c.openings = append(c.openings, opening{
+			ECO:   l[0],
+			Name:  l[1],
+			Moves: moves,
+			keys:  normalize(moves),
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(c.openings) == 0 {
+		return nil, errors.New("no openings loaded.")
+	}
+
+	return c, nil
+}
+
+// Query parses a sequence of moves in the standard algebraic notation
+// separated by hyphens and returns the matching opening.
+// eg: e4-e5-nf3, d4-nf6-c4-g6. Castling is oo or ooo.
+func (c *Chess) Query(q string) ([]string, error) {
+	moves := strings.Split(q, "-")
+	if len(moves) == 0 || len(moves) > maxMoves {
+		return nil, errors.New("invalid opening query. eg: e4-e5-nf3")
+	}
+	keys := normalize(moves)
+
+	var (
+		match *opening
+
+		// Openings that continue the query grouped by the next move.
+		next = map[string][]*opening{}
+	)
+	for n := range c.openings {
+		o := &c.openings[n]
+
+		// The deepest opening whose moves are a prefix of the query.
+		if isPrefix(o.keys, keys) {
+			if match == nil || len(o.keys) > len(match.keys) {
+				match = o
+			}
+			continue
+		}
+
+		if isPrefix(keys, o.keys) {
+			m := o.keys[len(keys)]
+			next[m] = append(next[m], o)
+		}
+	}
+
+	if match == nil {
+		return nil, errors.New("unknown opening.")
+	}
+
+	out := []string{
+		txt.Record(q, match.ECO, match.Name, formatMoves(match.Moves, 0)),
+	}
+	if next := mainLine(next); next != nil {
+		out = append(out, txt.Record(q, "continuation", next.ECO+": "+next.Name, formatMoves(next.Moves, len(keys))))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *Chess) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the chess opening for a sequence of moves.",
+		Syntax:   "$move-$move....opening",
+		Examples: []string{"dig e4-e5-nf3.opening @%s", "dig d4-d5-c4.opening @%s"},
+	}
+}
+
+// mainLine picks the main continuation from openings grouped by the next
+// move. The most explored next move (the one with the most openings in the
+// database) is assumed to be the main line, and its shortest opening is returned.
+func mainLine(next map[string][]*opening) *opening {
+	var best []*opening
+	for _, g := range next {
+		if len(g) > len(best) || (len(g) == len(best) && len(g) > 0 && g[0].ECO < best[0].ECO) {
+			best = g
+		}
+	}
+
+	var out *opening
+	for _, o := range best {
+		if out == nil || len(o.keys) < len(out.keys) {
+			out = o
+		}
+	}
+
+	return out
+}
+
+// normalize lowercases moves and strips captures, checks, and castling
+// hyphens so that moves can be matched irrespective of notation.
+func normalize(moves []string) []string {
+	out := make([]string, 0, len(moves))
+	for _, m := range moves {
+		out = append(out, keyCleaner.Replace(strings.ToLower(m)))
+	}
+
+	return out
+}
+
+// isPrefix checks if a is a prefix of b.
+func isPrefix(a, b []string) bool {
+	if len(a) > len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// formatMoves formats moves with move numbers starting from the given
+// half-move offset. eg: 1.e4 e5 2.Nf3
+func formatMoves(moves []string, offset int) string {
+	var b strings.Builder
+	for i := offset; i < len(moves); i++ {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+
+		if i%2 == 0 {
+			fmt.Fprintf(&b, "%d.", i/2+1)
+		} else if i == offset {
+			fmt.Fprintf(&b, "%d...", i/2+1)
+		}
+		b.WriteString(moves[i])
+	}
+
+	return b.String()
+}
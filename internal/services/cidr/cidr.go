@@ -6,10 +6,23 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
 )
 
 type CIDR struct{}
 
+func init() {
+	registry.Register(registry.Entry{
+		Name: "cidr",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
 // New returns a new instance of CIDR.
 func New() *CIDR {
 	return &CIDR{}
@@ -52,7 +65,7 @@ func (c *CIDR) Query(q string) ([]string, error) {
 		// Get the size of subnet.
 		size := 1 << (uint64(bits) - uint64(prefixLen))
 
-		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%d\"", q, first, last, size)
+		r := txt.Record(q, first, last, fmt.Sprintf("%d", size))
 		return []string{r}, nil
 
 	// Handle ipv6.
@@ -71,7 +84,7 @@ func (c *CIDR) Query(q string) ([]string, error) {
 		size := big.NewInt(1)
 		size = size.Lsh(size, uint(bits-prefixLen))
 
-		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%d\"", q, first, last, size)
+		r := txt.Record(q, first, last, fmt.Sprintf("%d", size))
 		return []string{r}, nil
 
 	default:
@@ -79,7 +92,11 @@ func (c *CIDR) Query(q string) ([]string, error) {
 	}
 }
 
-// Dump produces a gob dump of the cached data.
-func (c *CIDR) Dump() ([]byte, error) {
-	return nil, nil
+// Help returns the help text of the service.
+func (c *CIDR) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert cidr to ip range.",
+		Syntax:   "$ip/$prefix.cidr",
+		Examples: []string{"dig 10.100.0.0/24.cidr @%s", "dig 2001:db8::/108.cidr @%s"},
+	}
 }
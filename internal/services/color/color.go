@@ -0,0 +1,190 @@
+// Package color checks pairs of colors for accessibility: WCAG contrast
+// and distinguishability under color vision deficiencies.
+package color
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// rgb is a color with linear (not gamma encoded) sRGB components in 0-1.
+type rgb [3]float64
+
+// Machado et al. (2009) simulation matrices for full severity
+// dichromacy, applied to linear RGB.
+var deficiencies = []struct {
+	name string
+	m    [3][3]float64
+}{
+	{"protanopia", [3][3]float64{
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	}},
+	{"deuteranopia", [3][3]float64{
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	}},
+	{"tritanopia", [3][3]float64{
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	}},
+}
+
+// Minimum CIE76 color difference (delta E) for two colors to be
+// considered easily distinguishable.
+const minDeltaE = 20
+
+// CBCheck checks whether two colors are distinguishable for
+// color blind people.
+type CBCheck struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "color",
+		Suffixes: []string{"cbcheck", "contrast"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"cbcheck":  NewCBCheck(),
+				"contrast": NewContrast(),
+			}, nil
+		},
+	})
+}
+
+// NewCBCheck returns a new instance of CBCheck.
+func NewCBCheck() *CBCheck {
+	return &CBCheck{}
+}
+
+// Query parses a color pair and returns the color differences as seen
+// with normal vision and simulated deficiencies.
+// Format: $hex-$hex. eg: ff0000-00ff00
+func (c *CBCheck) Query(q string) ([]string, error) {
+	a, b, err := parsePair(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{
+		txt.Record(q, "normal", fmt.Sprintf("delta E %0.1f", deltaE(a, b)), verdict(deltaE(a, b))),
+	}
+	for _, d := range deficiencies {
+		de := deltaE(simulate(a, d.m), simulate(b, d.m))
+		out = append(out, txt.Record(q, d.name, fmt.Sprintf("delta E %0.1f", de), verdict(de)))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *CBCheck) Help() registry.Help {
+	return registry.Help{
+		Desc:     "check if two colors are distinguishable with color blindness.",
+		Syntax:   "$hex-$hex.cbcheck",
+		Examples: []string{"dig ff0000-00ff00.cbcheck @%s", "dig d55e00-009e73.cbcheck @%s"},
+	}
+}
+
+func verdict(de float64) string {
+	switch {
+	case de >= minDeltaE:
+		return "distinguishable"
+	case de >= minDeltaE/2:
+		return "hard to distinguish"
+	}
+	return "indistinguishable"
+}
+
+// parsePair parses two hex colors separated by a hyphen.
+func parsePair(q string) (rgb, rgb, error) {
+	p := strings.Split(strings.ToLower(q), "-")
+	if len(p) != 2 {
+		return rgb{}, rgb{}, errors.New("invalid query. Should be two hex colors. eg: ff0000-00ff00")
+	}
+
+	a, err := parseHex(p[0])
+	if err != nil {
+		return rgb{}, rgb{}, err
+	}
+	b, err := parseHex(p[1])
+	if err != nil {
+		return rgb{}, rgb{}, err
+	}
+
+	return a, b, nil
+}
+
+// parseHex parses a 3 or 6 digit hex color into linear RGB.
+func parseHex(s string) (rgb, error) {
+	errInvalid := fmt.Errorf("invalid color '%s'. eg: ff0000, f00", s)
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return rgb{}, errInvalid
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return rgb{}, errInvalid
+	}
+
+	return rgb{
+		linearize(float64(v>>16&0xff) / 255),
+		linearize(float64(v>>8&0xff) / 255),
+		linearize(float64(v&0xff) / 255),
+	}, nil
+}
+
+// linearize converts a gamma encoded sRGB component to linear.
+func linearize(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// simulate applies a deficiency simulation matrix to a color.
+func simulate(c rgb, m [3][3]float64) rgb {
+	var out rgb
+	for i := 0; i < 3; i++ {
+		v := m[i][0]*c[0] + m[i][1]*c[1] + m[i][2]*c[2]
+		out[i] = math.Max(0, math.Min(1, v))
+	}
+	return out
+}
+
+// deltaE returns the CIE76 difference between two colors.
+func deltaE(a, b rgb) float64 {
+	la, lb := lab(a), lab(b)
+	return math.Sqrt(math.Pow(la[0]-lb[0], 2) + math.Pow(la[1]-lb[1], 2) + math.Pow(la[2]-lb[2], 2))
+}
+
+// lab converts linear RGB to CIE L*a*b* (D65).
+func lab(c rgb) [3]float64 {
+	var (
+		x = (0.4124*c[0] + 0.3576*c[1] + 0.1805*c[2]) / 0.95047
+		y = 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+		z = (0.0193*c[0] + 0.1192*c[1] + 0.9505*c[2]) / 1.08883
+	)
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
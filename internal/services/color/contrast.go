@@ -0,0 +1,92 @@
+package color
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/record"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+)
+
+// Contrast computes WCAG 2 contrast ratios.
+type Contrast struct{}
+
+// NewContrast returns a new instance of Contrast.
+func NewContrast() *Contrast {
+	return &Contrast{}
+}
+
+// Query parses a color pair and returns its WCAG contrast ratio
+// and the AA/AAA levels it meets.
+// Format: $hex-$hex. eg: ffffff-777777
+func (c *Contrast) Query(q string) ([]string, error) {
+	r, err := ratio(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{
+		txt.Record(q, fmt.Sprintf("contrast %0.2f:1", r)),
+		txt.Record(q,
+			"normal text", fmt.Sprintf("AA %s", pass(r, 4.5)), fmt.Sprintf("AAA %s", pass(r, 7))),
+		txt.Record(q,
+			"large text", fmt.Sprintf("AA %s", pass(r, 3)), fmt.Sprintf("AAA %s", pass(r, 4.5))),
+	}
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *Contrast) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the WCAG contrast ratio of two colors and the AA/AAA levels met.",
+		Syntax:   "$hex-$hex.contrast",
+		Examples: []string{"dig ffffff-777777.contrast @%s", "dig 000-fff.contrast @%s"},
+	}
+}
+
+// Fields returns the structured result for a color pair.
+func (c *Contrast) Fields(q string) ([]record.Record, error) {
+	r, err := ratio(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return []record.Record{{
+		{Key: "ratio", Val: r},
+		{Key: "normal_aa", Val: pass(r, 4.5)},
+		{Key: "normal_aaa", Val: pass(r, 7)},
+		{Key: "large_aa", Val: pass(r, 3)},
+		{Key: "large_aaa", Val: pass(r, 4.5)},
+	}}, nil
+}
+
+// ratio parses a color pair and returns its contrast ratio.
+func ratio(q string) (float64, error) {
+	a, b, err := parsePair(q)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		la = luminance(a)
+		lb = luminance(b)
+		r  = (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+	)
+
+	// Round down to two decimals so that a ratio such as 4.499 doesn't
+	// show as 4.50 and pass AA.
+	return math.Floor(r*100) / 100, nil
+}
+
+// luminance returns the WCAG relative luminance of a color.
+func luminance(c rgb) float64 {
+	return 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+}
+
+func pass(ratio, min float64) string {
+	if ratio >= min {
+		return "pass"
+	}
+	return "fail"
+}
@@ -0,0 +1,265 @@
+// Package cooking returns safe internal cooking temperatures and steak
+// doneness temperatures with approximate cooking times from embedded tables.
+package cooking
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// USDA safe minimum temperature for whole cuts.
+const safeC = 63
+
+const lbToKg = 0.45359237
+
+//go:embed cooktemp.tsv
+var cookTempB []byte
+
+//go:embed steak.tsv
+var steakB []byte
+
+var (
+	cookGrammar = query.NewGrammar(`(?P<food>[a-z]+)(-(?P<weight>[0-9]{1,3}(\.[0-9]{1,2})?)(?P<unit>kg|lb|g))?`,
+		"invalid cooktemp query. eg: chicken, turkey-5kg, pork-3lb")
+	steakGrammar = query.NewGrammar(`(?P<doneness>[a-z]+(-[a-z]+)?)(-(?P<thick>[0-9]{1,2}(\.[0-9])?)(?P<unit>cm|in))?`,
+		"invalid steak query. eg: medium-rare, medium-4cm, rare-1.5in")
+)
+
+type food struct {
+	Name      string
+	C, F      int
+	RestMin   int
+	OvenC     int
+	MinsPerKg float64
+}
+
+type doneness struct {
+	Name    string
+	C, F    int
+	MinSide float64
+	Desc    string
+}
+
+// CookTemp returns safe cooking temperatures.
+type CookTemp struct {
+	foods map[string]food
+
+	// IDs in the order of the table.
+	ids []string
+}
+
+// Steak returns steak doneness temperatures.
+type Steak struct {
+	levels map[string]doneness
+	ids    []string
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "cooktemp",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewCookTemp()
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "steak",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewSteak()
+		},
+	})
+}
+
+// NewCookTemp returns a new instance of CookTemp.
+func NewCookTemp() (*CookTemp, error) {
+	c := &CookTemp{foods: make(map[string]food)}
+	err := readTSV(cookTempB, 7, func(l []string) error {
+		var (
+			n    [4]int
+			m, e = strconv.ParseFloat(l[6], 64)
+		)
+		if e != nil {
+			return e
+		}
+		for i := range n {
+			v, err := strconv.Atoi(l[i+2])
+			if err != nil {
+				return err
+			}
+			n[i] = v
+		}
+
+		c.foods[l[0]] = food{Name: l[1], C: n[0], F: n[1], RestMin: n[2], OvenC: n[3], MinsPerKg: m}
+		c.ids = append(c.ids, l[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Query returns the safe internal temperature of a food, and with an
+// optional weight, the approximate time to roast it.
+// Format: $food or $food-$weight(kg|lb|g). eg: chicken, turkey-5kg
+func (c *CookTemp) Query(q string) ([]string, error) {
+	args, err := cookGrammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := c.foods[args["food"]]
+	if !ok {
+		return nil, fmt.Errorf("unknown food. Try one of: %s", strings.Join(c.ids, ", "))
+	}
+
+	rest := "no rest time"
+	if f.RestMin > 0 {
+		rest = fmt.Sprintf("rest %d min", f.RestMin)
+	}
+
+	out := []string{
+		txt.Record(q, f.Name, fmt.Sprintf("%dC (%dF) internal", f.C, f.F), rest),
+	}
+
+	if args["weight"] != "" {
+		if f.MinsPerKg == 0 {
+			return nil, fmt.Errorf("no roasting time for %s. Cook until it reaches %dC (%dF).", strings.ToLower(f.Name), f.C, f.F)
+		}
+
+		w, _ := args.Float("weight")
+		switch args["unit"] {
+		case "lb":
+			w *= lbToKg
+		case "g":
+			w /= 1000
+		}
+		if w <= 0 || w > 15 {
+			return nil, errors.New("invalid weight. Should be up to 15kg.")
+		}
+
+		// Ovens are set in steps of 5F.
+		mins := int(w*f.MinsPerKg + 0.5)
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("%0.2gkg", w),
+			fmt.Sprintf("roast at %dC (%dF)", f.OvenC, (f.OvenC*9/5+32+2)/5*5),
+			fmt.Sprintf("~%dh %02dm", mins/60, mins%60),
+			"check with a thermometer"))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *CookTemp) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the safe internal cooking temperature of a food and the approximate roasting time for a weight. Foods: " + strings.Join(c.ids, ", "),
+		Syntax:   "$food.cooktemp or $food-$weight(kg|lb|g).cooktemp",
+		Examples: []string{"dig chicken.cooktemp @%s", "dig turkey-5kg.cooktemp @%s"},
+	}
+}
+
+// NewSteak returns a new instance of Steak.
+func NewSteak() (*Steak, error) {
+	s := &Steak{levels: make(map[string]doneness)}
+	err := readTSV(steakB, 6, func(l []string) error {
+		c, err1 := strconv.Atoi(l[2])
+		f, err2 := strconv.Atoi(l[3])
+		m, err3 := strconv.ParseFloat(l[4], 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return fmt.Errorf("invalid line in steak table: %v", l)
+		}
+
+		s.levels[l[0]] = doneness{Name: l[1], C: c, F: f, MinSide: m, Desc: l[5]}
+		s.ids = append(s.ids, l[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Query returns the internal temperature of a steak doneness with the
+// approximate time per side for an optional thickness (default 2.5cm).
+// Format: $doneness or $doneness-$thickness(cm|in). eg: medium-rare, medium-4cm
+func (s *Steak) Query(q string) ([]string, error) {
+	args, err := steakGrammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := s.levels[args["doneness"]]
+	if !ok {
+		return nil, fmt.Errorf("unknown doneness. Try one of: %s", strings.Join(s.ids, ", "))
+	}
+
+	cm := 2.5
+	if args["thick"] != "" {
+		cm, _ = args.Float("thick")
+		if args["unit"] == "in" {
+			cm *= 2.54
+		}
+		if cm < 1 || cm > 8 {
+			return nil, errors.New("invalid thickness. Should be 1-8cm.")
+		}
+	}
+
+	out := []string{
+		txt.Record(q,
+			d.Name, fmt.Sprintf("%dC (%dF)", d.C, d.F),
+			fmt.Sprintf("off the heat at %dC (%dF)", d.C-3, d.F-5), d.Desc),
+		txt.Record(q,
+			fmt.Sprintf("%0.2gcm thick", cm),
+			fmt.Sprintf("~%0.1f min per side on high heat", d.MinSide*cm/2.5), "rest 5 min"),
+	}
+	if d.C < safeC {
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("below the USDA safe minimum of %dC (145F) for whole cuts", safeC)))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (s *Steak) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the internal temperature of a steak doneness and the approximate time per side for a thickness.",
+		Syntax:   "$doneness.steak or $doneness-$thickness(cm|in).steak",
+		Examples: []string{"dig medium-rare.steak @%s", "dig medium-4cm.steak @%s"},
+	}
+}
+
+// readTSV reads a tab separated table skipping blank and # comment lines
+// and calls fn with every line's n fields.
+func readTSV(b []byte, n int, fn func([]string) error) error {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		l := strings.Split(line, "\t")
+		if len(l) != n {
+			return fmt.Errorf("invalid line in table: %s", line)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,124 @@
+// Package counter keeps named counters per client that expire after a while,
+// for quick tallies from shell scripts.
+package counter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/ephemeral"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var grammar = query.NewGrammar(`(?P<name>[a-z0-9_\-]{1,32})`, "invalid counter name. eg: mycounter.inc, mycounter.get")
+
+// Counter is the counter service. Every instance does one operation
+// (increment or get) on counters in a shared store.
+type Counter struct {
+	store *ephemeral.Store
+	inc   bool
+}
+
+// Counters is the group of the inc and get services on a shared store.
+type Counters struct {
+	store *ephemeral.Store
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "counter",
+		Suffixes: []string{"inc", "get"},
+		NewGroup: func(ko *koanf.Koanf, _ *registry.Env) (registry.Group, error) {
+			return &Counters{
+				store: ephemeral.New(ko.MustDuration("ttl"), ko.MustInt("max_counters"), ko.MustInt("max_per_client")),
+			}, nil
+		},
+	})
+}
+
+// Service returns the counter service for a suffix (inc or get).
+func (c *Counters) Service(suffix string) registry.Service {
+	if suffix == "inc" {
+		return NewInc(c.store)
+	}
+
+	return NewGet(c.store)
+}
+
+// CacheStats returns the statistics of the counters store.
+func (c *Counters) CacheStats() cache.Stats {
+	return c.store.CacheStats()
+}
+
+// NewInc returns a new instance of Counter that increments counters.
+func NewInc(s *ephemeral.Store) *Counter {
+	return &Counter{store: s, inc: true}
+}
+
+// NewGet returns a new instance of Counter that returns counters.
+func NewGet(s *ephemeral.Store) *Counter {
+	return &Counter{store: s}
+}
+
+// Query is not supported as counters are kept per client.
+func (c *Counter) Query(q string) ([]string, error) {
+	return nil, errors.New("unable to detect IP.")
+}
+
+// Help returns the help text of the service.
+func (c *Counter) Help() registry.Help {
+	return registry.Help{
+		Desc:     "increment and get named counters for quick tallies from scripts (per resolver IP).",
+		Syntax:   "$name.inc or $name.get",
+		Examples: []string{"dig mycounter.inc @%s", "dig mycounter.get @%s"},
+	}
+}
+
+// QueryClient increments or returns a client's counter.
+// Format: $name. eg: mycounter
+func (c *Counter) QueryClient(q string, client net.IP) ([]string, error) {
+	if client == nil {
+		return nil, errors.New("unable to detect IP.")
+	}
+
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		owner = client.String()
+		key   = owner + "/" + args["name"]
+	)
+
+	var (
+		it ephemeral.Item
+		ok = true
+	)
+	if c.inc {
+		it, err = c.store.Update(key, owner, func(val string, _ bool) (string, error) {
+			n, _ := strconv.ParseUint(val, 10, 64)
+			return strconv.FormatUint(n+1, 10), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		it, ok = c.store.Get(key)
+	}
+
+	if !ok {
+		return []string{txt.Record(q, args["name"], "0")}, nil
+	}
+
+	return []string{txt.Record(q,
+		args["name"], it.Val,
+		fmt.Sprintf("expires in %s", time.Until(it.Expires).Round(time.Second)))}, nil
+}
@@ -0,0 +1,107 @@
+// Package cssunit converts between CSS length units.
+package cssunit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const defaultBase = 16
+
+// Absolute units in px. rem and em are relative to the base font size.
+var units = map[string]float64{
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+}
+
+var grammar = query.NewGrammar(`(base(?P<base>[0-9\.]+)-)?(?P<val>[0-9\.]+)(?P<unit>px|rem|em|pt|pc|in|cm|mm)`, "invalid CSS length. eg: 16px, 1.5rem, 12pt, base20-24px")
+
+// CSSUnit converts CSS lengths to a unit.
+type CSSUnit struct {
+	to string
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "cssunit",
+		Suffixes: []string{"px", "rem", "em", "pt"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"px":  New("px"),
+				"rem": New("rem"),
+				"em":  New("em"),
+				"pt":  New("pt"),
+			}, nil
+		},
+	})
+}
+
+// New returns a new instance of CSSUnit that converts lengths to the
+// given unit (px, rem, em, or pt).
+func New(to string) *CSSUnit {
+	return &CSSUnit{to: to}
+}
+
+// Query parses a CSS length and converts it to the unit.
+// Format: [base$px-]$value$unit. eg: 16px, 1.5rem, base20-24px
+// The base font size for rem and em defaults to 16px.
+func (c *CSSUnit) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	base := float64(defaultBase)
+	if args["base"] != "" {
+		b, err := args.Float("base")
+		if err != nil || b <= 0 {
+			return nil, errors.New("invalid base font size.")
+		}
+		base = b
+	}
+
+	val, err := args.Float("val")
+	if err != nil {
+		return nil, errors.New("invalid value.")
+	}
+
+	px := toPx(val, args["unit"], base)
+	out := px / toPx(1, c.to, base)
+
+	r := txt.Record(q,
+		fmt.Sprintf("%s%s = %s%s", args["val"], args["unit"], format(out), c.to),
+		fmt.Sprintf("base font size %spx", format(base)))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (c *CSSUnit) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert CSS lengths to px, rem, em, or pt (base$px- prefix for the base font size).",
+		Syntax:   "[base$px-]$value$unit.(px|rem|em|pt)",
+		Examples: []string{"dig 16px.rem @%s", "dig 1.5rem.px @%s"},
+	}
+}
+
+func toPx(v float64, unit string, base float64) float64 {
+	if unit == "rem" || unit == "em" {
+		return v * base
+	}
+	return v * units[unit]
+}
+
+// format formats a value with up to 4 decimals and no trailing zeroes.
+func format(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%0.4f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,148 @@
+// Package decay computes the radioactive decay of isotopes from an embedded
+// table of half-lives.
+package decay
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+//go:embed isotopes.tsv
+var isotopesB []byte
+
+var grammar = query.NewGrammar(`(?P<qty>[0-9]{1,9}(\.[0-9]{1,6})?)(?P<unit>[a-z]{0,3})-(?P<iso>[a-z]{1,2}[0-9]{1,3}m?)-(?P<time>[0-9]{1,12}(\.[0-9]{1,6})?)(?P<tunit>s|min|h|d|y)`,
+	"invalid decay query. eg: 100g-c14-10000y, 1kg-i131-30d")
+
+// Time units in seconds and their names.
+var timeUnits = map[string]struct {
+	sec  float64
+	name string
+}{
+	"s":   {1, "seconds"},
+	"min": {60, "minutes"},
+	"h":   {3600, "hours"},
+	"d":   {86400, "days"},
+	"y":   {365.25 * 86400, "years"},
+}
+
+type isotope struct {
+	Name string
+
+	// Half-life in seconds and as written in the table.
+	HalfLife float64
+	Val      float64
+	Unit     string
+}
+
+// Decay computes radioactive decay.
+type Decay struct {
+	// id (eg: c14) -> isotope map.
+	isotopes map[string]isotope
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "decay",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Decay.
+func New() (*Decay, error) {
+	d := &Decay{isotopes: make(map[string]isotope)}
+
+	sc := bufio.NewScanner(bytes.NewReader(isotopesB))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		l := strings.Split(line, "\t")
+		if len(l) != 4 {
+			return nil, fmt.Errorf("invalid line in isotopes table: %s", line)
+		}
+
+		v, err := strconv.ParseFloat(l[2], 64)
+		u, ok := timeUnits[l[3]]
+		if err != nil || !ok {
+			return nil, fmt.Errorf("invalid half-life in isotopes table: %s", line)
+		}
+
+		d.isotopes[l[0]] = isotope{Name: l[1], HalfLife: v * u.sec, Val: v, Unit: l[3]}
+	}
+
+	return d, sc.Err()
+}
+
+// Query returns the quantity of an isotope remaining after a period of time.
+// Format: $qty[$unit]-$isotope-$time(s|min|h|d|y). eg: 100g-c14-10000y
+func (d *Decay) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	iso, ok := d.isotopes[args["iso"]]
+	if !ok {
+		return nil, fmt.Errorf("unknown isotope. Known: %s", d.list())
+	}
+
+	var (
+		qty, _ = args.Float("qty")
+		t, _   = args.Float("time")
+		tu     = timeUnits[args["tunit"]]
+
+		// N = N0 x 2^(-t/T).
+		halves = t * tu.sec / iso.HalfLife
+		left   = qty * math.Pow(2, -halves)
+		unit   = args["unit"]
+	)
+	if qty == 0 {
+		return nil, errors.New("invalid quantity.")
+	}
+
+	return []string{
+		txt.Record(q, iso.Name, fmt.Sprintf("half-life %g %s", iso.Val, timeUnits[iso.Unit].name)),
+		txt.Record(q,
+			fmt.Sprintf("%g%s after %g %s", qty, unit, t, tu.name),
+			fmt.Sprintf("%0.4g%s remaining", left, unit),
+			fmt.Sprintf("%0.4g%s decayed", qty-left, unit)),
+		txt.Record(q,
+			fmt.Sprintf("%0.3g half-lives", halves), fmt.Sprintf("%0.4g%% remaining", left/qty*100)),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Decay) Help() registry.Help {
+	return registry.Help{
+		Desc:     "compute the quantity of a radioactive isotope remaining after a period of time. Isotopes: " + d.list(),
+		Syntax:   "$qty[$unit]-$isotope-$time(s|min|h|d|y).decay",
+		Examples: []string{"dig 100g-c14-10000y.decay @%s", "dig 1kg-i131-30d.decay @%s"},
+	}
+}
+
+// list returns the sorted, comma separated isotope IDs.
+func (d *Decay) list() string {
+	ids := make([]string, 0, len(d.isotopes))
+	for id := range d.isotopes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,128 @@
+// Package dewpoint computes the dew point from temperature and relative humidity.
+package dewpoint
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/record"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Magnus formula coefficients (Sonntag 1990).
+const (
+	magnusA = 17.62
+	magnusB = 243.12
+)
+
+var grammar = query.NewGrammar(`(?P<temp>-?[0-9\.]+)(?P<unit>c|f)-(?P<humidity>[0-9\.]+)pc`, "invalid dewpoint query. eg: 30c-60pc, 86f-60pc")
+
+// DewPoint computes dew points.
+type DewPoint struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "dewpoint",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of DewPoint.
+func New() *DewPoint {
+	return &DewPoint{}
+}
+
+// Query parses a dew point query and returns the answer.
+// Format: $temp(c|f)-$humidity(pc). eg: 30c-60pc
+func (d *DewPoint) Query(q string) ([]string, error) {
+	t, rh, dp, err := d.compute(q)
+	if err != nil {
+		return nil, err
+	}
+
+	r := txt.Record(q,
+		fmt.Sprintf("%0.1fC (%0.1fF) at %0.0f%% humidity", t, t*1.8+32, rh),
+		fmt.Sprintf("dew point %0.1fC (%0.1fF)", dp, dp*1.8+32), comfort(dp))
+	return []string{r}, nil
+}
+
+// Fields returns the structured result for a dew point query.
+func (d *DewPoint) Fields(q string) ([]record.Record, error) {
+	t, rh, dp, err := d.compute(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return []record.Record{{
+		{Key: "temp_c", Val: round(t)},
+		{Key: "humidity_pc", Val: rh},
+		{Key: "dewpoint_c", Val: round(dp)},
+		{Key: "dewpoint_f", Val: round(dp*1.8 + 32)},
+		{Key: "comfort", Val: comfort(dp)},
+	}}, nil
+}
+
+// compute parses a query and returns the temperature (C), relative
+// humidity, and the dew point (C).
+func (d *DewPoint) compute(q string) (float64, float64, float64, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	t, err := args.Float("temp")
+	if err != nil {
+		return 0, 0, 0, errors.New("invalid temperature.")
+	}
+	if args["unit"] == "f" {
+		t = (t - 32) / 1.8
+	}
+
+	rh, err := args.Float("humidity")
+	if err != nil || rh <= 0 || rh > 100 {
+		return 0, 0, 0, errors.New("invalid humidity. Should be 1-100pc.")
+	}
+
+	g := math.Log(rh/100) + (magnusA*t)/(magnusB+t)
+	return t, rh, (magnusB * g) / (magnusA - g), nil
+}
+
+// Help returns the help text of the service.
+func (d *DewPoint) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the dew point and comfort level.",
+		Syntax:   "$temp(c|f)-$humiditypc.dewpoint",
+		Examples: []string{"dig 30c-60pc.dewpoint @%s", "dig 86f-40pc.dewpoint @%s"},
+	}
+}
+
+// round rounds to one decimal.
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// comfort returns the human comfort level for a dew point in C.
+func comfort(dp float64) string {
+	switch {
+	case dp >= 24:
+		return "oppressive"
+	case dp >= 21:
+		return "very humid"
+	case dp >= 18:
+		return "humid"
+	case dp >= 16:
+		return "somewhat humid"
+	case dp >= 13:
+		return "comfortable"
+	case dp >= 10:
+		return "pleasant"
+	}
+
+	return "dry"
+}
@@ -0,0 +1,391 @@
+// Package dictionary looks up definitions, synonyms, and antonyms of words
+// in several languages from a dictionary API.
+package dictionary
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errs"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/upstream"
+	"github.com/knadh/koanf"
+)
+
+const (
+	defaultAPIURL = "https://api.dictionaryapi.dev/api/v2/entries/%s/%s"
+
+	// Max definitions or words in a response.
+	defaultMaxWords = 10
+
+	defaultLang = "en"
+)
+
+var grammar = query.NewGrammar(`(?P<word>[\p{L}\-]{1,32})(\.(?P<lang>[a-z]{2}(-[a-z]{2})?))?`,
+	"invalid word. eg: happy.dict, perro.es.dict, happy.synonym")
+
+type mode int
+
+const (
+	modeDefine mode = iota
+	modeSynonyms
+	modeAntonyms
+)
+
+// entry is a cached lookup of a word in a language. Definitions, synonyms,
+// and antonyms are fetched in one request and served from the same entry.
+type entry struct {
+	Definitions []definition
+	Synonyms    []string
+	Antonyms    []string
+
+	// The word isn't in the dictionary.
+	NotFound bool
+}
+
+type definition struct {
+	PartOfSpeech string
+	Text         string
+}
+
+type apiData []struct {
+	Meanings []struct {
+		PartOfSpeech string   `json:"partOfSpeech"`
+		Synonyms     []string `json:"synonyms"`
+		Antonyms     []string `json:"antonyms"`
+		Definitions  []struct {
+			Definition string   `json:"definition"`
+			Synonyms   []string `json:"synonyms"`
+			Antonyms   []string `json:"antonyms"`
+		} `json:"definitions"`
+	} `json:"meanings"`
+}
+
+// Opt contains config options for Dictionary.
+type Opt struct {
+	// APIURL is the dictionary API endpoint with %s placeholders for the
+	// language and the word. RateLimit is the max requests/sec to the API.
+	APIURL     string
+	RateLimit  float64
+	ReqTimeout time.Duration
+
+	// Languages are the language codes that the API supports. Queries
+	// without one are in the first language.
+	Languages []string
+
+	CacheTTL time.Duration
+
+	// Max time a query for an uncached word waits for it to be fetched.
+	FetchWait time.Duration
+
+	MaxWords int
+
+	// Transport, if set, is used for API requests instead of the network.
+	// eg: fixtures in offline mode.
+	Transport http.RoundTripper
+
+	// Store, if set, persists lookups to disk with MemEntries of them
+	// held in memory.
+	Store      upstream.Store
+	MemEntries int
+}
+
+// Dictionary fetches and caches word lookups. It's served by the Define,
+// Synonyms, and Antonyms services.
+type Dictionary struct {
+	up     *upstream.Fetcher
+	opt    Opt
+	client *http.Client
+
+	// Language codes as they're sent to the API by their lowercase form.
+	langs map[string]string
+}
+
+// Service answers one kind of query (definitions, synonyms, or antonyms)
+// from a Dictionary.
+type Service struct {
+	d    *Dictionary
+	mode mode
+}
+
+type lookup struct {
+	Lang string
+	Word string
+}
+
+func init() {
+	// Register the cached value type for snapshots.
+	gob.Register(entry{})
+
+	registry.Register(registry.Entry{
+		Name:     "dictionary",
+		Suffixes: []string{"dict", "synonym", "antonym"},
+		NewGroup: func(ko *koanf.Koanf, env *registry.Env) (registry.Group, error) {
+			return New(Opt{
+				APIURL:     ko.String("api_url"),
+				RateLimit:  ko.Float64("rate_limit"),
+				ReqTimeout: ko.Duration("req_timeout"),
+				Languages:  ko.Strings("languages"),
+				CacheTTL:   ko.MustDuration("cache_ttl"),
+				FetchWait:  ko.Duration("fetch_wait"),
+				MaxWords:   ko.Int("max_words"),
+				Transport:  env.Transport,
+				Store:      env.Store("dictionary"),
+				MemEntries: ko.Int("cache_mem_entries"),
+			}), nil
+		},
+	})
+}
+
+// New returns a new instance of Dictionary.
+func New(o Opt) *Dictionary {
+	if o.APIURL == "" {
+		o.APIURL = defaultAPIURL
+	}
+	if o.MaxWords < 1 {
+		o.MaxWords = defaultMaxWords
+	}
+	if len(o.Languages) == 0 {
+		o.Languages = []string{defaultLang}
+	}
+
+	d := &Dictionary{
+		opt:   o,
+		langs: make(map[string]string, len(o.Languages)),
+		client: &http.Client{
+			Timeout:   o.ReqTimeout,
+			Transport: o.Transport,
+		},
+	}
+	for _, l := range o.Languages {
+		d.langs[strings.ToLower(l)] = l
+	}
+
+	d.up = upstream.New(upstream.Opt{
+		Name:      "dictionary",
+		TTL:       o.CacheTTL,
+		RateLimit: o.RateLimit,
+		Retries:   1,
+		RetryWait: time.Second,
+		Wait:      o.FetchWait,
+
+		Store:      o.Store,
+		MemEntries: o.MemEntries,
+	}, func(req interface{}) (interface{}, error) {
+		return d.fetch(req.(lookup))
+	})
+
+	return d
+}
+
+// Define returns the service for definition queries.
+func (d *Dictionary) Define() *Service {
+	return &Service{d: d, mode: modeDefine}
+}
+
+// Synonyms returns the service for synonym queries.
+func (d *Dictionary) Synonyms() *Service {
+	return &Service{d: d, mode: modeSynonyms}
+}
+
+// Antonyms returns the service for antonym queries.
+func (d *Dictionary) Antonyms() *Service {
+	return &Service{d: d, mode: modeAntonyms}
+}
+
+// Service returns the service for a suffix (dict, synonym, or antonym). The
+// services share the Dictionary's cache.
+func (d *Dictionary) Service(suffix string) registry.Service {
+	switch suffix {
+	case "synonym":
+		return d.Synonyms()
+	case "antonym":
+		return d.Antonyms()
+	}
+
+	return d.Define()
+}
+
+// Query returns the definitions, synonyms, or antonyms of a word.
+func (s *Service) Query(q string) ([]string, error) {
+	return s.QueryContext(context.Background(), q)
+}
+
+// QueryContext returns the definitions, synonyms, or antonyms of a word in
+// an optional language. Uncached words are not waited for once ctx is done.
+// Format: $word or $word.$lang. eg: happy, perro.es
+func (s *Service) QueryContext(ctx context.Context, q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	l := lookup{Lang: s.d.opt.Languages[0], Word: args["word"]}
+	if args["lang"] != "" {
+		lang, ok := s.d.langs[args["lang"]]
+		if !ok {
+			return nil, fmt.Errorf("unknown language. Try one of: %s", strings.Join(s.d.opt.Languages, ", "))
+		}
+		l.Lang = lang
+	}
+
+	// Entries are cached per language.
+	v, stale, err := s.d.up.GetContext(ctx, l.Lang+"/"+l.Word, l)
+	if err != nil {
+		if err == upstream.ErrQueued {
+			return []string{txt.Record(q,
+				fmt.Sprintf("%s is being looked up. Try again in a few seconds.", l.Word))}, nil
+		}
+		if err == upstream.ErrDown {
+			return nil, errs.Wrap(errs.Upstream, "dictionary is temporarily unavailable. Try again later.", err)
+		}
+		return nil, errs.Wrap(errs.Upstream, "dictionary is unavailable. Try again in a few seconds.", err)
+	}
+	e := v.(entry)
+
+	if e.NotFound {
+		return nil, fmt.Errorf("'%s' is not in the dictionary.", l.Word)
+	}
+
+	var out []string
+	switch s.mode {
+	case modeDefine:
+		if len(e.Definitions) == 0 {
+			return nil, fmt.Errorf("no definitions found for '%s'.", l.Word)
+		}
+
+		for i, d := range e.Definitions {
+			if i == s.d.opt.MaxWords {
+				break
+			}
+			out = append(out, txt.Record(q, l.Word, d.PartOfSpeech, d.Text))
+		}
+
+	default:
+		kind, words := "synonyms", e.Synonyms
+		if s.mode == modeAntonyms {
+			kind, words = "antonyms", e.Antonyms
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("no %s found for '%s'.", kind, l.Word)
+		}
+		if len(words) > s.d.opt.MaxWords {
+			words = words[:s.d.opt.MaxWords]
+		}
+
+		// Long lists continue in more records.
+		out = txt.Records(q, []string{l.Word}, strings.Join(words, ", "), 1)
+	}
+
+	// Mark responses that are being served past their cache TTL.
+	if stale {
+		for i := range out {
+			out[i] = txt.Append(out[i], "stale")
+		}
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the services.
+func (s *Service) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get definitions, synonyms, and antonyms of words, optionally in another language.",
+		Syntax:   "$word[.$lang].dict, $word[.$lang].synonym, or $word[.$lang].antonym",
+		Examples: []string{"dig happy.dict @%s", "dig perro.es.dict @%s", "dig happy.synonym @%s"},
+		Upstream: true,
+	}
+}
+
+// CacheStats returns the statistics of the lookup cache.
+func (d *Dictionary) CacheStats() cache.Stats {
+	return d.up.CacheStats()
+}
+
+// UpstreamStats returns the counts of fetches to the dictionary API.
+func (d *Dictionary) UpstreamStats() upstream.Stats {
+	return d.up.UpstreamStats()
+}
+
+// Dump produces a gob dump of the cached lookups.
+func (d *Dictionary) Dump() ([]byte, error) {
+	return d.up.Dump()
+}
+
+// Load loads a gob dump of cached lookups.
+func (d *Dictionary) Load(b []byte) error {
+	return d.up.Load(b)
+}
+
+// fetch looks up a word in the dictionary API. Words that aren't in the
+// dictionary are cached as NotFound.
+func (d *Dictionary) fetch(l lookup) (entry, error) {
+	resp, err := d.client.Get(fmt.Sprintf(d.opt.APIURL, neturl.PathEscape(l.Lang), neturl.PathEscape(l.Word)))
+	if err != nil {
+		return entry{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return entry{NotFound: true}, nil
+	case http.StatusTooManyRequests:
+		return entry{}, fmt.Errorf("error fetching dictionary data: %w", upstream.ErrRateLimited)
+	default:
+		return entry{}, fmt.Errorf("error fetching dictionary data: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return entry{}, err
+	}
+
+	var data apiData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return entry{}, err
+	}
+	if len(data) == 0 {
+		return entry{}, errors.New("empty dictionary response")
+	}
+
+	var (
+		out  entry
+		seen = map[string]bool{l.Word: true}
+	)
+	add := func(list *[]string, words []string) {
+		for _, w := range words {
+			if !seen[w] {
+				seen[w] = true
+				*list = append(*list, w)
+			}
+		}
+	}
+	for _, d := range data {
+		for _, m := range d.Meanings {
+			add(&out.Synonyms, m.Synonyms)
+			add(&out.Antonyms, m.Antonyms)
+			for _, def := range m.Definitions {
+				out.Definitions = append(out.Definitions, definition{
+					PartOfSpeech: m.PartOfSpeech,
+					Text:         strings.ReplaceAll(def.Definition, `"`, `'`),
+				})
+				add(&out.Synonyms, def.Synonyms)
+				add(&out.Antonyms, def.Antonyms)
+			}
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,84 @@
+// Package discount applies successive (stacked) discounts to a price.
+package discount
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const maxDiscounts = 10
+
+// Discount computes stacked discounts.
+type Discount struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "discount",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Discount.
+func New() *Discount {
+	return &Discount{}
+}
+
+// Query parses a discount query and returns the answer.
+// Format: $price-$discount[-$discount...]. Discounts are percentages
+// (20pc) or flat amounts (100) applied in order. eg: 2000-20pc-10pc
+func (d *Discount) Query(q string) ([]string, error) {
+	parts := strings.Split(strings.ToLower(q), "-")
+	if len(parts) < 2 || len(parts) > maxDiscounts+1 {
+		return nil, errors.New("invalid discount query. eg: 2000-20pc-10pc, 2000-20pc-100")
+	}
+
+	price, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || price <= 0 {
+		return nil, errors.New("invalid price.")
+	}
+
+	final := price
+	for _, p := range parts[1:] {
+		if strings.HasSuffix(p, "pc") {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(p, "pc"), 64)
+			if err != nil || v < 0 || v > 100 {
+				return nil, fmt.Errorf("invalid discount '%s'.", p)
+			}
+			final -= final * v / 100
+			continue
+		}
+
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid discount '%s'.", p)
+		}
+		final -= v
+	}
+
+	if final < 0 {
+		final = 0
+	}
+
+	r := txt.Record(q,
+		fmt.Sprintf("price %0.2f", price), fmt.Sprintf("final %0.2f", final),
+		fmt.Sprintf("you save %0.2f", price-final),
+		fmt.Sprintf("effective discount %0.2f%%", (price-final)/price*100))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Discount) Help() registry.Help {
+	return registry.Help{
+		Desc:     "apply successive discounts (percentage or flat) to a price.",
+		Syntax:   "$price-$discount[pc]-....discount",
+		Examples: []string{"dig 2000-20pc-10pc.discount @%s", "dig 2000-20pc-100.discount @%s"},
+	}
+}
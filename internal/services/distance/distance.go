@@ -0,0 +1,213 @@
+// Package distance returns great-circle distances between two geographic locations.
+package distance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/record"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Mean radius of the Earth in km.
+	earthRadiusKm = 6371.0
+
+	kmToMiles = 0.621371
+)
+
+var reCoords = regexp.MustCompile(`^(-?[0-9\.]+)-(-?[0-9\.]+)-(-?[0-9\.]+)-(-?[0-9\.]+)$`)
+
+// Distance computes distances between cities or coordinates.
+type Distance struct {
+	geo *geo.Geo
+}
+
+type point struct {
+	name     string
+	lat, lon float64
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "distance",
+		New: func(_ *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo()), nil
+		},
+	})
+}
+
+// New returns a new instance of Distance.
+func New(g *geo.Geo) *Distance {
+	return &Distance{
+		geo: g,
+	}
+}
+
+// Query parses a given query string and returns the answer.
+// For the distance package, the query is two city names (eg: mumbai-london)
+// or two lat-lon coordinate pairs (eg: 19.07-72.87-51.50--0.12).
+func (d *Distance) Query(q string) ([]string, error) {
+	from, to, err := d.parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		km      = haversine(from.lat, from.lon, to.lat, to.lon)
+		bearing = initialBearing(from.lat, from.lon, to.lat, to.lon)
+	)
+
+	r := txt.Record(q,
+		fmt.Sprintf("%s - %s", from.name, to.name), fmt.Sprintf("%0.2f km", km),
+		fmt.Sprintf("%0.2f mi", km*kmToMiles), fmt.Sprintf("bearing %0.2f deg", bearing))
+
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Distance) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get distance and bearing between two cities or coordinates.",
+		Syntax:   "$city-$city.distance or $lat-$lon-$lat-$lon.distance",
+		Examples: []string{"dig mumbai-london.distance @%s", "dig 19.07-72.87-51.50--0.12.distance @%s"},
+	}
+}
+
+// Fields returns the structured result for a distance query.
+func (d *Distance) Fields(q string) ([]record.Record, error) {
+	from, to, err := d.parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	km := haversine(from.lat, from.lon, to.lat, to.lon)
+	return []record.Record{{
+		{Key: "from", Val: from.name},
+		{Key: "to", Val: to.name},
+		{Key: "km", Val: round(km)},
+		{Key: "mi", Val: round(km * kmToMiles)},
+		{Key: "bearing", Val: round(initialBearing(from.lat, from.lon, to.lat, to.lon))},
+	}}, nil
+}
+
+// parse parses the query into two points.
+func (d *Distance) parse(q string) (point, point, error) {
+	// Raw coordinates.
+	if res := reCoords.FindStringSubmatch(q); len(res) == 5 {
+		var c [4]float64
+		for i, s := range res[1:] {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return point{}, point{}, errors.New("invalid coordinates.")
+			}
+			c[i] = v
+		}
+
+		if !validCoords(c[0], c[1]) || !validCoords(c[2], c[3]) {
+			return point{}, point{}, errors.New("invalid coordinates.")
+		}
+
+		return point{name: fmt.Sprintf("%0.4f,%0.4f", c[0], c[1]), lat: c[0], lon: c[1]},
+			point{name: fmt.Sprintf("%0.4f,%0.4f", c[2], c[3]), lat: c[2], lon: c[3]}, nil
+	}
+
+	// City names.
+	cities := strings.Split(q, "-")
+	if len(cities) != 2 {
+		return point{}, point{}, errors.New("invalid distance query. eg: mumbai-london or 19.07-72.87-51.50--0.12")
+	}
+
+	from, err := d.lookup(cities[0])
+	if err != nil {
+		return point{}, point{}, err
+	}
+
+	to, err := d.lookup(cities[1])
+	if err != nil {
+		return point{}, point{}, err
+	}
+
+	return from, to, nil
+}
+
+// lookup looks up a city name with an optional /2-letter-country-code
+// and returns the most populous match.
+func (d *Distance) lookup(q string) (point, error) {
+	var (
+		str     = strings.Split(q, "/")
+		country = ""
+	)
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		q = str[0]
+		country = strings.ToUpper(str[1])
+	}
+	q = strings.ToLower(q)
+
+	for _, l := range d.geo.Query(q) {
+		// Filter by country.
+		if country != "" && l.Country != country {
+			continue
+		}
+
+		return point{name: fmt.Sprintf("%s (%s)", l.Name, l.Country), lat: l.Lat, lon: l.Lon}, nil
+	}
+
+	return point{}, fmt.Errorf("unknown city: %s.", q)
+}
+
+func validCoords(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// haversine returns the great-circle distance in km between two points.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	var (
+		p1   = toRad(lat1)
+		p2   = toRad(lat2)
+		dLat = toRad(lat2 - lat1)
+		dLon = toRad(lon2 - lon1)
+	)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(p1)*math.Cos(p2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// initialBearing returns the initial bearing (forward azimuth) in degrees
+// from point 1 to point 2.
+func initialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	var (
+		p1   = toRad(lat1)
+		p2   = toRad(lat2)
+		dLon = toRad(lon2 - lon1)
+	)
+
+	y := math.Sin(dLon) * math.Cos(p2)
+	x := math.Cos(p1)*math.Sin(p2) - math.Sin(p1)*math.Cos(p2)*math.Cos(dLon)
+
+	return math.Mod(toDeg(math.Atan2(y, x))+360, 360)
+}
+
+// round rounds to two decimals.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func toRad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func toDeg(r float64) float64 {
+	return r * 180 / math.Pi
+}
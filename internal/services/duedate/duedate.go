@@ -0,0 +1,115 @@
+// Package duedate estimates the ovulation date, trimester milestones, and
+// due date of a pregnancy from the first day of the last menstrual period.
+package duedate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Naegele's rule: 280 days (40 weeks) from the last period for a
+	// 28 day cycle, with ovulation on day 14.
+	gestationDays = 280
+	ovulationDay  = 14
+	defaultCycle  = 28
+
+	minCycle = 21
+	maxCycle = 45
+
+	day = time.Hour * 24
+)
+
+var grammar = query.NewGrammar(`(?P<date>[0-9]{4}-[0-9]{2}-[0-9]{2})(-(?P<cycle>[0-9]{2})d)?`,
+	"invalid duedate query. eg: 2025-01-10, 2025-01-10-32d")
+
+// DueDate computes due dates.
+type DueDate struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "duedate",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of DueDate.
+func New() *DueDate {
+	return &DueDate{}
+}
+
+// Query returns the milestones of a pregnancy from the date of the last
+// period and an optional cycle length in days (default 28).
+// Format: $yyyy-$mm-$dd or $yyyy-$mm-$dd-$cycled. eg: 2025-01-10, 2025-01-10-32d
+func (d *DueDate) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	lmp, err := time.Parse("2006-01-02", args["date"])
+	if err != nil {
+		return nil, errors.New("invalid date. eg: 2025-01-10")
+	}
+
+	cycle := defaultCycle
+	if args["cycle"] != "" {
+		cycle, _ = args.Int("cycle")
+		if cycle < minCycle || cycle > maxCycle {
+			return nil, fmt.Errorf("invalid cycle length. Should be %d-%d days.", minCycle, maxCycle)
+		}
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if lmp.After(today) {
+		return nil, errors.New("the date is in the future.")
+	}
+
+	// Longer or shorter cycles shift ovulation and everything after it.
+	var (
+		shift = day * time.Duration(cycle-defaultCycle)
+		due   = lmp.Add(day*gestationDays + shift)
+	)
+
+	milestones := []struct {
+		name string
+		t    time.Time
+	}{
+		{"ovulation (est.)", lmp.Add(day*ovulationDay + shift)},
+		{"2nd trimester (week 14)", lmp.Add(day*7*13 + shift)},
+		{"3rd trimester (week 28)", lmp.Add(day*7*27 + shift)},
+		{"due date (week 40)", due},
+	}
+
+	out := make([]string, 0, len(milestones)+1)
+	for _, m := range milestones {
+		out = append(out, txt.Record(q, m.name, m.t.Format("Mon, 02 Jan 2006")))
+	}
+
+	// Gestational age today, counted from the (shifted) last period.
+	if days := int(today.Sub(lmp.Add(shift)) / day); days >= 0 && !today.After(due) {
+		out = append(out, txt.Record(q,
+			"today", fmt.Sprintf("%d weeks %d days", days/7, days%7),
+			fmt.Sprintf("%d days to go", int(due.Sub(today)/day))))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (d *DueDate) Help() registry.Help {
+	return registry.Help{
+		Desc:     "estimate the ovulation date, trimesters, and due date from the first day of the last period, with an optional cycle length.",
+		Syntax:   "$yyyy-$mm-$dd.duedate or $yyyy-$mm-$dd-$cycled.duedate",
+		Examples: []string{"dig 2025-01-10.duedate @%s", "dig 2025-01-10-32d.duedate @%s"},
+	}
+}
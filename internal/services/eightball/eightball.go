@@ -0,0 +1,94 @@
+// Package eightball is a magic 8-ball.
+package eightball
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// The 20 classic magic 8-ball answers.
+var answers = []string{
+	"It is certain.",
+	"It is decidedly so.",
+	"Without a doubt.",
+	"Yes definitely.",
+	"You may rely on it.",
+	"As I see it, yes.",
+	"Most likely.",
+	"Outlook good.",
+	"Yes.",
+	"Signs point to yes.",
+	"Reply hazy, try again.",
+	"Ask again later.",
+	"Better not tell you now.",
+	"Cannot predict now.",
+	"Concentrate and ask again.",
+	"Don't count on it.",
+	"My reply is no.",
+	"My sources say no.",
+	"Outlook not so good.",
+	"Very doubtful.",
+}
+
+// EightBall answers questions.
+type EightBall struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "8ball",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of EightBall.
+func New() *EightBall {
+	return &EightBall{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Query returns an answer to a question.
+// Format: $question or daily-$question. eg: will-it-work, daily-will-it-work
+// The daily variant always gives the same answer to a question on a given (UTC) day.
+func (e *EightBall) Query(q string) ([]string, error) {
+	question := strings.ToLower(q)
+	if question == "" {
+		return nil, errors.New("ask a question. eg: will-it-work")
+	}
+
+	var n int
+	if strings.HasPrefix(question, "daily-") {
+		h := fnv.New64a()
+		h.Write([]byte(time.Now().UTC().Format("2006-01-02") + strings.TrimPrefix(question, "daily-")))
+		n = int(h.Sum64() % uint64(len(answers)))
+	} else {
+		e.mu.Lock()
+		n = e.rnd.Intn(len(answers))
+		e.mu.Unlock()
+	}
+
+	r := txt.Record(q, answers[n])
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (e *EightBall) Help() registry.Help {
+	return registry.Help{
+		Desc:     "ask the magic 8-ball a question (daily- prefix for the answer of the day).",
+		Syntax:   "[daily-]$question.8ball",
+		Examples: []string{"dig will-it-work.8ball @%s", "dig daily-will-it-work.8ball @%s"},
+	}
+}
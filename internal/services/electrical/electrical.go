@@ -0,0 +1,211 @@
+// Package electrical implements Ohm's law and wire gauge calculators.
+package electrical
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Resistivity of annealed copper in ohm mm^2/m.
+const copperResistivity = 0.01724
+
+// gauge represents the ampacity (A) of a copper conductor at 60C, 75C, and 90C
+// insulation ratings (NEC table 310.16). 0 indicates that it's not rated.
+type gauge struct {
+	AWG      string
+	N        int
+	Ampacity [3]int
+}
+
+var gauges = []gauge{
+	{"18", 18, [3]int{0, 0, 14}},
+	{"16", 16, [3]int{0, 0, 18}},
+	{"14", 14, [3]int{15, 20, 25}},
+	{"12", 12, [3]int{20, 25, 30}},
+	{"10", 10, [3]int{30, 35, 40}},
+	{"8", 8, [3]int{40, 50, 55}},
+	{"6", 6, [3]int{55, 65, 75}},
+	{"4", 4, [3]int{70, 85, 95}},
+	{"3", 3, [3]int{85, 100, 115}},
+	{"2", 2, [3]int{95, 115, 130}},
+	{"1", 1, [3]int{110, 130, 145}},
+	{"1/0", 0, [3]int{125, 150, 170}},
+	{"2/0", -1, [3]int{145, 175, 195}},
+	{"3/0", -2, [3]int{165, 200, 225}},
+	{"4/0", -3, [3]int{195, 230, 260}},
+}
+
+var (
+	reQuantity = regexp.MustCompile(`^([0-9\.]+)(k|m)?(v|a|ohm|r|w)$`)
+	reGauge    = regexp.MustCompile(`^([0-9]+(?:/0)?)awg$`)
+)
+
+// Ohms computes voltage, current, resistance, and power from any two of them.
+type Ohms struct{}
+
+// Wire returns properties of copper wire gauges.
+type Wire struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "electrical",
+		Suffixes: []string{"ohms", "wire"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"ohms": NewOhms(),
+				"wire": NewWire(),
+			}, nil
+		},
+	})
+}
+
+// NewOhms returns a new instance of Ohms.
+func NewOhms() *Ohms {
+	return &Ohms{}
+}
+
+// NewWire returns a new instance of Wire.
+func NewWire() *Wire {
+	return &Wire{}
+}
+
+// Query parses two electrical quantities and returns the other two.
+// eg: 12v-0.5a, 230v-1kw, 5ma-2kohm
+func (o *Ohms) Query(q string) ([]string, error) {
+	parts := strings.Split(strings.ToLower(q), "-")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid ohms query. Pass two of v, a, ohm, w. eg: 12v-0.5a")
+	}
+
+	vals := map[string]float64{}
+	for _, p := range parts {
+		res := reQuantity.FindStringSubmatch(p)
+		if len(res) != 4 {
+			return nil, fmt.Errorf("invalid quantity: %s.", p)
+		}
+
+		v, err := strconv.ParseFloat(res[1], 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid number: %s.", res[1])
+		}
+
+		switch res[2] {
+		case "k":
+			v *= 1e3
+		case "m":
+			v *= 1e-3
+		}
+
+		unit := res[3]
+		if unit == "r" {
+			unit = "ohm"
+		}
+		if _, ok := vals[unit]; ok {
+			return nil, errors.New("pass two different quantities.")
+		}
+		vals[unit] = v
+	}
+
+	var (
+		v, hasV = vals["v"]
+		i, hasI = vals["a"]
+		r, hasR = vals["ohm"]
+		p, hasP = vals["w"]
+	)
+
+	switch {
+	case hasV && hasI:
+		r, p = v/i, v*i
+	case hasV && hasR:
+		i, p = v/r, v*v/r
+	case hasV && hasP:
+		i, r = p/v, v*v/p
+	case hasI && hasR:
+		v, p = i*r, i*i*r
+	case hasI && hasP:
+		v, r = p/i, p/(i*i)
+	case hasR && hasP:
+		v, i = math.Sqrt(p*r), math.Sqrt(p/r)
+	}
+
+	out := txt.Record(q,
+		fmt.Sprintf("%sV", formatNum(v)), fmt.Sprintf("%sA", formatNum(i)),
+		fmt.Sprintf("%s ohm", formatNum(r)), fmt.Sprintf("%sW", formatNum(p)))
+	return []string{out}, nil
+}
+
+// Help returns the help text of the service.
+func (o *Ohms) Help() registry.Help {
+	return registry.Help{
+		Desc:     "calculate voltage, current, resistance, and power from any two.",
+		Syntax:   "$v(v|a|ohm|w)-$v(v|a|ohm|w).ohms",
+		Examples: []string{"dig 12v-0.5a.ohms @%s", "dig 230v-100w.ohms @%s"},
+	}
+}
+
+// Query returns the dimensions, resistance, and ampacity of a copper
+// wire gauge. eg: 12awg, 1/0awg
+func (w *Wire) Query(q string) ([]string, error) {
+	res := reGauge.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 2 {
+		return nil, errors.New("invalid wire query. eg: 12awg, 2/0awg")
+	}
+
+	var g *gauge
+	for n := range gauges {
+		if gauges[n].AWG == res[1] {
+			g = &gauges[n]
+			break
+		}
+	}
+	if g == nil {
+		return nil, errors.New("unknown gauge. Supported gauges are 18 to 4/0 AWG.")
+	}
+
+	var (
+		dia  = 0.127 * math.Pow(92, float64(36-g.N)/39)
+		area = math.Pi / 4 * dia * dia
+	)
+
+	out := []string{
+		txt.Record(q,
+			fmt.Sprintf("%s AWG", g.AWG), fmt.Sprintf("%0.3f mm dia", dia),
+			fmt.Sprintf("%0.3f mm2", area),
+			fmt.Sprintf("%0.3f ohm/km copper", copperResistivity*1000/area)),
+		txt.Record(q,
+			"ampacity (copper)", fmt.Sprintf("60C: %s", formatAmps(g.Ampacity[0])),
+			fmt.Sprintf("75C: %s", formatAmps(g.Ampacity[1])),
+			fmt.Sprintf("90C: %s", formatAmps(g.Ampacity[2]))),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (w *Wire) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get copper wire gauge dimensions and ampacity.",
+		Syntax:   "$gaugeawg.wire",
+		Examples: []string{"dig 12awg.wire @%s", "dig 4/0awg.wire @%s"},
+	}
+}
+
+func formatAmps(a int) string {
+	if a == 0 {
+		return "n/a"
+	}
+
+	return strconv.Itoa(a) + "A"
+}
+
+func formatNum(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
@@ -0,0 +1,195 @@
+// Package feelslike computes "feels like" temperatures (wind chill and heat index).
+package feelslike
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var (
+	reWindChill = regexp.MustCompile(`^(-?[0-9\.]+)(c|f)-([0-9\.]+)(kmh|mph|ms|kn)$`)
+	reHeatIndex = regexp.MustCompile(`^(-?[0-9\.]+)(c|f)-([0-9\.]+)(pc)$`)
+)
+
+// Wind speed unit to km/h multipliers.
+var windUnits = map[string]float64{
+	"kmh": 1,
+	"mph": 1.609344,
+	"ms":  3.6,
+	"kn":  1.852,
+}
+
+// WindChill computes the wind chill temperature.
+type WindChill struct{}
+
+// HeatIndex computes the heat index temperature.
+type HeatIndex struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "feelslike",
+		Suffixes: []string{"windchill", "heatindex"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"windchill": NewWindChill(),
+				"heatindex": NewHeatIndex(),
+			}, nil
+		},
+	})
+}
+
+// NewWindChill returns a new instance of WindChill.
+func NewWindChill() *WindChill {
+	return &WindChill{}
+}
+
+// NewHeatIndex returns a new instance of HeatIndex.
+func NewHeatIndex() *HeatIndex {
+	return &HeatIndex{}
+}
+
+// Query parses a wind chill query and returns the answer.
+// Format: $temp(c|f)-$speed(kmh|mph|ms|kn). eg: 5c-30kmh
+func (w *WindChill) Query(q string) ([]string, error) {
+	res := reWindChill.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 5 {
+		return nil, errors.New("invalid windchill query. eg: 5c-30kmh, 20f-15mph")
+	}
+
+	t, err := parseTemp(res[1], res[2])
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := strconv.ParseFloat(res[3], 64)
+	if err != nil {
+		return nil, errors.New("invalid wind speed.")
+	}
+	v = v * windUnits[res[4]]
+
+	// The formula is only defined for temperatures at or below 10C
+	// and wind speeds above 4.8 km/h.
+	if t > 10 || v <= 4.8 {
+		r := txt.Record(q,
+			formatTemp(t),
+			"wind chill is only defined for <= 10C (50F) and wind > 4.8 km/h (3 mph)")
+		return []string{r}, nil
+	}
+
+	// Environment Canada / US NWS wind chill formula.
+	wc := 13.12 + 0.6215*t - 11.37*math.Pow(v, 0.16) + 0.3965*t*math.Pow(v, 0.16)
+
+	r := txt.Record(q,
+		fmt.Sprintf("%s at %0.1f km/h", formatTemp(t), v),
+		fmt.Sprintf("feels like %s", formatTemp(wc)))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (w *WindChill) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the wind chill temperature.",
+		Syntax:   "$temp(c|f)-$speed(kmh|mph).windchill",
+		Examples: []string{"dig 5c-30kmh.windchill @%s", "dig 20f-15mph.windchill @%s"},
+	}
+}
+
+// Query parses a heat index query and returns the answer.
+// Format: $temp(c|f)-$humidity(pc). eg: 34c-70pc
+func (h *HeatIndex) Query(q string) ([]string, error) {
+	res := reHeatIndex.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 5 {
+		return nil, errors.New("invalid heatindex query. eg: 34c-70pc, 95f-50pc")
+	}
+
+	t, err := parseTemp(res[1], res[2])
+	if err != nil {
+		return nil, err
+	}
+
+	rh, err := strconv.ParseFloat(res[3], 64)
+	if err != nil || rh < 0 || rh > 100 {
+		return nil, errors.New("invalid humidity. Should be 0-100pc.")
+	}
+
+	hi := heatIndex(t*1.8+32, rh)
+	hiC := (hi - 32) / 1.8
+
+	r := txt.Record(q,
+		fmt.Sprintf("%s at %0.0f%% humidity", formatTemp(t), rh),
+		fmt.Sprintf("feels like %s", formatTemp(hiC)), heatCaution(hi))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (h *HeatIndex) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the heat index temperature.",
+		Syntax:   "$temp(c|f)-$humiditypc.heatindex",
+		Examples: []string{"dig 34c-70pc.heatindex @%s", "dig 95f-50pc.heatindex @%s"},
+	}
+}
+
+// heatIndex computes the heat index in F using the US NWS Rothfusz
+// regression along with its adjustments.
+func heatIndex(t, rh float64) float64 {
+	// Steadman's simple formula is used below 80F.
+	hi := 0.5 * (t + 61.0 + ((t - 68.0) * 1.2) + (rh * 0.094))
+	if (hi+t)/2 < 80 {
+		return hi
+	}
+
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	if rh < 13 && t >= 80 && t <= 112 {
+		hi -= ((13 - rh) / 4) * math.Sqrt((17-math.Abs(t-95))/17)
+	} else if rh > 85 && t >= 80 && t <= 87 {
+		hi += ((rh - 85) / 10) * ((87 - t) / 5)
+	}
+
+	return hi
+}
+
+// heatCaution returns the NWS caution level for a heat index in F.
+func heatCaution(hi float64) string {
+	switch {
+	case hi >= 125:
+		return "extreme danger"
+	case hi >= 103:
+		return "danger"
+	case hi >= 90:
+		return "extreme caution"
+	case hi >= 80:
+		return "caution"
+	}
+
+	return "no risk"
+}
+
+// parseTemp parses a temperature value in C or F and returns it in C.
+func parseTemp(val, unit string) (float64, error) {
+	t, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, errors.New("invalid temperature.")
+	}
+
+	if unit == "f" {
+		t = (t - 32) / 1.8
+	}
+
+	return t, nil
+}
+
+func formatTemp(c float64) string {
+	return fmt.Sprintf("%0.1fC (%0.1fF)", c, c*1.8+32)
+}
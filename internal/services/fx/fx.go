@@ -8,62 +8,134 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/creds"
+	"github.com/knadh/dns.toys/internal/errs"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/upstream"
+	"github.com/knadh/koanf"
 )
 
-const apiURL = "https://api.exchangerate.host/latest"
+const defaultAPIURL = "https://api.exchangerate.host/latest"
 
 var reParse = regexp.MustCompile("([0-9\\.]+)([A-Z]{3})\\-([A-Z]{3})")
 
 // FX represents the currency coversion (Foreign Exchange) package.
 type FX struct {
-	opt  Opt
-	data data
-	mut  sync.RWMutex
+	opt   Opt
+	data  data
+	chain *upstream.Chain
+	mut   sync.RWMutex
 }
 
 type data struct {
 	Base  string             `json:"base"`
 	Date  string             `json:"date"`
 	Rates map[string]float64 `json:"rates"`
+
+	// Provider (API host) that the rates were fetched from and when.
+	Provider  string    `json:"-"`
+	FetchedAt time.Time `json:"-"`
 }
 
 // Opt represents the config options for the FX converter.
 type Opt struct {
 	RefreshInterval time.Duration `json:"refresh_interval"`
+
+	// Rates older than StaleAfter (eg: when the APIs are down) are still
+	// served but marked "as of" the time they were fetched.
+	StaleAfter time.Duration `json:"stale_after"`
+
+	// APIURL is the rates API endpoint. If Keys has keys, the current one
+	// is sent as the access_key param and rotated on HTTP 429.
+	APIURL     string        `json:"api_url"`
+	Keys       *creds.Pool   `json:"-"`
+	ReqTimeout time.Duration `json:"req_timeout"`
+
+	// FallbackURLs are rates APIs with the same response format that are
+	// failed over to, in order, when APIURL errors or is rate limited.
+	FallbackURLs []string `json:"fallback_urls"`
+
+	// Transport, if set, is used for API requests instead of the network.
+	// eg: fixtures in offline mode.
+	Transport http.RoundTripper `json:"-"`
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "fx",
+		New: func(ko *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(Opt{
+				RefreshInterval: ko.MustDuration("refresh_interval"),
+				StaleAfter:      ko.Duration("stale_after"),
+				APIURL:          ko.String("api_url"),
+				Keys:            env.Creds.Add("fx", registry.APIKeys(ko, "api_keys")...),
+				ReqTimeout:      ko.Duration("req_timeout"),
+				FallbackURLs:    ko.Strings("fallback_urls"),
+				Transport:       env.Transport,
+			}), nil
+		},
+	})
 }
 
 // New returns an instace of the FX converter.
 func New(o Opt) *FX {
+	if o.APIURL == "" {
+		o.APIURL = defaultAPIURL
+	}
+	if o.ReqTimeout == 0 {
+		o.ReqTimeout = 6 * time.Second
+	}
+	if o.StaleAfter == 0 {
+		o.StaleAfter = o.RefreshInterval * 4
+	}
+
 	fx := &FX{
 		opt: o,
 	}
 
+	// Rates APIs in the order of preference. Keys are only sent to the
+	// primary API.
+	provs := []upstream.Provider{{
+		Name:  apiHost(o.APIURL),
+		Fetch: func(interface{}) (interface{}, error) { return fx.load(o.APIURL, o.Keys) },
+	}}
+	for _, u := range o.FallbackURLs {
+		u := u
+		provs = append(provs, upstream.Provider{
+			Name:  apiHost(u),
+			Fetch: func(interface{}) (interface{}, error) { return fx.load(u, nil) },
+		})
+	}
+	fx.chain = upstream.NewChain("fx", 0, provs...)
+
 	// Periodically fetch and refresh the rates.
 	go func() {
 		for {
-			log.Println("loading fx API")
-			d, err := fx.load(apiURL)
+			slog.Info("loading fx API")
+			v, prov, err := fx.chain.Fetch(nil)
 			if err != nil {
-				log.Printf("error loading fx rates API: %v", err)
+				slog.Error("error loading fx rates API", "error", err)
 
 				// HTTP fetch failed. Retry again in a minute.
 				time.Sleep(time.Minute)
 				continue
 			}
 
-			if _, ok := d.Rates[d.Base]; !ok {
-				log.Printf("base currency %s not found in rates", d.Base)
-				time.Sleep(time.Minute * 5)
-				continue
-			}
-			log.Printf("%d fx currency pairs loaded", len(d.Rates))
+			d := v.(data)
+			d.Provider = prov
+			d.FetchedAt = time.Now()
+			slog.Info("fx currency pairs loaded", "pairs", len(d.Rates), "provider", prov)
 
 			fx.mut.Lock()
 			fx.data = d
@@ -76,11 +148,16 @@ func New(o Opt) *FX {
 	return fx
 }
 
-// Query handles a currency rate conversion query.
+// Query handles a currency rate conversion query. Stale rates are
+// marked with the time they were fetched.
 // Format: 100USD-INR.FX
 func (fx *FX) Query(q string) ([]string, error) {
-	if len(fx.data.Rates) == 0 {
-		return nil, errors.New("fx data unavailable. Please try later.")
+	fx.mut.RLock()
+	d := fx.data
+	fx.mut.RUnlock()
+
+	if len(d.Rates) == 0 {
+		return nil, errs.New(errs.Upstream, "fx data unavailable. Please try later.")
 	}
 
 	q = strings.ToUpper(q)
@@ -101,30 +178,73 @@ func (fx *FX) Query(q string) ([]string, error) {
 		to   = res[3]
 	)
 
-	// Validate the currency names.
-	fx.mut.RLock()
-	fromRate, ok := fx.data.Rates[from]
-	fx.mut.RUnlock()
-
+	// Validate the currency names. The rates map is replaced and not
+	// modified on refreshes and can be read without the lock.
+	fromRate, ok := d.Rates[from]
 	if !ok {
 		return nil, fmt.Errorf("unknown from currency '%s'.", from)
 	}
 
-	toRate, ok := fx.data.Rates[to]
+	toRate, ok := d.Rates[to]
 	if !ok {
 		return nil, fmt.Errorf("unknown to currency '%s'.", to)
 	}
 
-	baseRate := fx.data.Rates[fx.data.Base]
+	baseRate := d.Rates[d.Base]
 
 	// Convert.
 	conv := (baseRate / fromRate) / (baseRate / toRate) * val
 
-	r := fmt.Sprintf("%s TXT \"%0.2f %s = %0.2f %s\" \"%s\"", q, val, from, conv, to, fx.data.Date)
+	r := txt.RecordTTL(q, 3600, fmt.Sprintf("%0.2f %s = %0.2f %s", val, from, conv, to), d.Date)
+
+	// Mark rates from a fallback API.
+	if p := d.Provider; p != "" && p != apiHost(fx.opt.APIURL) {
+		r = txt.Append(r, "via "+p)
+	}
+
+	// Mark rates that couldn't be refreshed. Rates from older snapshots
+	// don't have the fetch time and are as of their date.
+	if time.Since(d.FetchedAt) > fx.opt.StaleAfter {
+		asOf := d.Date
+		if !d.FetchedAt.IsZero() {
+			asOf = d.FetchedAt.UTC().Format("2006-01-02 15:04 UTC")
+		}
+		r = txt.Append(r, "stale: as of "+asOf)
+	}
 
 	return []string{r}, nil
 }
 
+// Help returns the help text of the service.
+func (fx *FX) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert currency rates",
+		Syntax:   "$amount$FROM-$TO.fx",
+		Examples: []string{"dig 99USD-INR.fx @%s", "dig 50EUR-USD.fx @%s"},
+		Upstream: true,
+	}
+}
+
+// CacheStats returns the statistics of the rates cache. The rates are
+// refreshed in bulk and are not looked up individually, so there are no hits.
+func (fx *FX) CacheStats() cache.Stats {
+	fx.mut.RLock()
+	defer fx.mut.RUnlock()
+
+	// Currency code, rate, and map overhead per rate.
+	return cache.Stats{
+		Entries: len(fx.data.Rates),
+		Bytes:   int64(len(fx.data.Rates) * (3 + 8 + 16)),
+		Oldest:  fx.data.FetchedAt,
+		Newest:  fx.data.FetchedAt,
+	}
+}
+
+// UpstreamStats returns the counts of fetches to the rates APIs.
+func (fx *FX) UpstreamStats() upstream.Stats {
+	return fx.chain.UpstreamStats()
+}
+
 // Dump produces a gob dump of the cached data.
 func (fx *FX) Dump() ([]byte, error) {
 	buf := &bytes.Buffer{}
@@ -140,24 +260,59 @@ func (fx *FX) Dump() ([]byte, error) {
 
 // Load loads a gob dump of cached data.
 func (fx *FX) Load(b []byte) error {
-	buf := bytes.NewBuffer(b)
+	var d data
+	if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(&d); err != nil {
+		return err
+	}
 
-	fx.mut.RLock()
-	defer fx.mut.RUnlock()
+	fx.mut.Lock()
+	defer fx.mut.Unlock()
+
+	// Rates fetched before the snapshot was loaded are newer.
+	if len(fx.data.Rates) == 0 {
+		fx.data = d
+	}
 
-	err := gob.NewDecoder(buf).Decode(&fx.data)
-	return err
+	return nil
 }
 
-func (fx *FX) load(url string) (data, error) {
+// load fetches the rates from an API, sending the keys from the pool, if any.
+func (fx *FX) load(url string, keys *creds.Pool) (data, error) {
 	client := http.Client{
-		Timeout: 6 * time.Second,
+		Timeout:   fx.opt.ReqTimeout,
+		Transport: fx.opt.Transport,
 	}
 
-	req, _ := http.NewRequest("GET", url, nil)
-	resp, err := client.Do(req)
-	if err != nil {
-		return data{}, err
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i <= keys.Len(); i++ {
+		req, _ := http.NewRequest("GET", url, nil)
+
+		key := keys.Get()
+		if key != "" {
+			q := req.URL.Query()
+			q.Set("access_key", key)
+			req.URL.RawQuery = q.Encode()
+		}
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return data{}, err
+		}
+
+		// Rate limited. Rotate to the next key, if there's one.
+		if resp.StatusCode == http.StatusTooManyRequests && keys.Limited(key) {
+			resp.Body.Close()
+			continue
+		}
+		break
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return data{}, fmt.Errorf("request failed: %w", upstream.ErrRateLimited)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -174,5 +329,22 @@ func (fx *FX) load(url string) (data, error) {
 		return data{}, err
 	}
 
+	// Some APIs (eg: ECB based ones) don't include the base in the rates.
+	if out.Base == "" || len(out.Rates) == 0 {
+		return data{}, errors.New("base currency or rates not found")
+	}
+	if _, ok := out.Rates[out.Base]; !ok {
+		out.Rates[out.Base] = 1
+	}
+
 	return out, nil
 }
+
+// apiHost returns the host of an API URL to identify it.
+func apiHost(u string) string {
+	p, err := neturl.Parse(u)
+	if err != nil || p.Host == "" {
+		return u
+	}
+	return p.Host
+}
@@ -0,0 +1,93 @@
+// Package geocode returns geographic coordinates for city names.
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max number of matching locations to return.
+const maxResults = 3
+
+// Geocode looks up coordinates of cities from the geo locations database.
+type Geocode struct {
+	geo *geo.Geo
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "geo",
+		New: func(_ *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo()), nil
+		},
+	})
+}
+
+// New returns a new instance of Geocode.
+func New(g *geo.Geo) *Geocode {
+	return &Geocode{
+		geo: g,
+	}
+}
+
+// Query parses a given query string and returns the answer.
+// For the geocode package, the query is a location name.
+func (g *Geocode) Query(q string) ([]string, error) {
+	var (
+		str     = strings.Split(q, "/")
+		country = ""
+	)
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		q = str[0]
+		country = strings.ToUpper(str[1])
+	}
+	q = strings.ToLower(q)
+
+	locs := g.geo.Query(q)
+	if locs == nil {
+		return nil, errors.New("unknown city.")
+	}
+
+	out := make([]string, 0, maxResults)
+	for _, l := range locs {
+		// Filter by country.
+		if country != "" {
+			if l.Country != country {
+				continue
+			}
+		}
+
+		r := txt.Record(q,
+			fmt.Sprintf("%s (%s)", l.Name, l.Country),
+			fmt.Sprintf("%0.5f, %0.5f", l.Lat, l.Lon), l.Timezone,
+			fmt.Sprintf("population %d", l.Population))
+		out = append(out, r)
+
+		if len(out) >= maxResults {
+			break
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("unknown city.")
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (g *Geocode) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get coordinates, country, and population of a city.",
+		Syntax:   "$city[/$country].geo",
+		Examples: []string{"dig pune.geo @%s", "dig london/gb.geo @%s"},
+	}
+}
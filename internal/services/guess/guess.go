@@ -0,0 +1,129 @@
+// Package guess implements a stateful number guessing game.
+package guess
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/session"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	maxNum   = 100
+	maxTries = 10
+)
+
+// Guess is the number guessing game.
+type Guess struct {
+	sessions *session.Store
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+type game struct {
+	num   int
+	tries int
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "guess",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(session.New(ko.MustDuration("session_ttl"), ko.MustInt("max_sessions"))), nil
+		},
+	})
+}
+
+// New returns a new instance of Guess that uses the given session store
+// to keep track of games.
+func New(s *session.Store) *Guess {
+	return &Guess{
+		sessions: s,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Query starts a new game or plays a guess in an existing one.
+// Format: start (new game), $id-$number. eg: ab12cd-50
+func (g *Guess) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+	if q == "start" {
+		g.mu.Lock()
+		n := g.rnd.Intn(maxNum) + 1
+		g.mu.Unlock()
+
+		id, err := g.sessions.Create(&game{num: n})
+		if err != nil {
+			return nil, err
+		}
+
+		r := txt.Record(q,
+			fmt.Sprintf("new game %s", id),
+			fmt.Sprintf("guess a number between 1 and %d in %d tries", maxNum, maxTries),
+			fmt.Sprintf("dig %s-50.guess", id))
+		return []string{r}, nil
+	}
+
+	parts := strings.Split(q, "-")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid guess query. eg: start (start a game), ab12cd-50 (guess 50)")
+	}
+
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 1 || n > maxNum {
+		return nil, fmt.Errorf("invalid number. Should be 1-%d.", maxNum)
+	}
+
+	var out string
+	err = g.sessions.Update(parts[0], func(v interface{}) (bool, error) {
+		gm := v.(*game)
+		gm.tries++
+
+		if n == gm.num {
+			out = txt.Record(q, fmt.Sprintf("correct! %d", n), fmt.Sprintf("%d tries", gm.tries))
+			return true, nil
+		}
+
+		hint := "higher"
+		if n > gm.num {
+			hint = "lower"
+		}
+
+		if gm.tries >= maxTries {
+			out = txt.Record(q, hint, fmt.Sprintf("out of tries. the number was %d", gm.num))
+			return true, nil
+		}
+
+		out = txt.Record(q, hint, fmt.Sprintf("%d tries left", maxTries-gm.tries))
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{out}, nil
+}
+
+// Help returns the help text of the service.
+func (g *Guess) Help() registry.Help {
+	return registry.Help{
+		Desc:     "guess a number between 1 and 100. start a game and guess with the game ID.",
+		Syntax:   "start.guess or $id-$number.guess",
+		Examples: []string{"dig start.guess @%s", "dig ab12cd-50.guess @%s"},
+	}
+}
+
+// CacheStats returns the statistics of the sessions store.
+func (g *Guess) CacheStats() cache.Stats {
+	return g.sessions.CacheStats()
+}
@@ -0,0 +1,183 @@
+// Package hangman implements a stateful game of hangman.
+package hangman
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/session"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/words"
+	"github.com/knadh/koanf"
+)
+
+const (
+	minWordLen = 5
+	lives      = 6
+)
+
+// Hangman is the hangman game.
+type Hangman struct {
+	words    []string
+	sessions *session.Store
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+type game struct {
+	word    string
+	guessed map[byte]bool
+	wrong   []byte
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "hangman",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(session.New(ko.MustDuration("session_ttl"), ko.MustInt("max_sessions")))
+		},
+	})
+}
+
+// New returns a new instance of Hangman that uses the given session store
+// to keep track of games.
+func New(s *session.Store) (*Hangman, error) {
+	var list []string
+	for _, w := range words.All() {
+		if len(w) >= minWordLen {
+			list = append(list, w)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no words in the word list")
+	}
+
+	return &Hangman{
+		words:    list,
+		sessions: s,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Query starts a new game or plays a guess in an existing one.
+// Format: new (new game), $id-$letter or $id-$word. eg: ab12cd-e
+func (h *Hangman) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+	if q == "new" {
+		return h.newGame(q)
+	}
+
+	parts := strings.Split(q, "-")
+	if len(parts) != 2 || parts[1] == "" || strings.Trim(parts[1], "abcdefghijklmnopqrstuvwxyz") != "" {
+		return nil, errors.New("invalid hangman query. eg: new (start a game), ab12cd-e (guess e)")
+	}
+
+	var (
+		id    = parts[0]
+		guess = parts[1]
+		out   []string
+	)
+	err := h.sessions.Update(id, func(v interface{}) (bool, error) {
+		g := v.(*game)
+
+		if len(guess) == 1 {
+			c := guess[0]
+			if g.guessed[c] {
+				out = g.status(q, fmt.Sprintf("already guessed '%c'", c))
+				return false, nil
+			}
+
+			g.guessed[c] = true
+			if !strings.ContainsRune(g.word, rune(c)) {
+				g.wrong = append(g.wrong, c)
+			}
+		} else if guess != g.word {
+			// A wrong word guess costs a life.
+			g.wrong = append(g.wrong, '*')
+		} else {
+			for i := 0; i < len(g.word); i++ {
+				g.guessed[g.word[i]] = true
+			}
+		}
+
+		switch {
+		case g.solved():
+			out = g.status(q, "you win! the word is "+g.word)
+			return true, nil
+		case len(g.wrong) >= lives:
+			out = g.status(q, "game over. the word was "+g.word)
+			return true, nil
+		}
+
+		out = g.status(q, "keep guessing")
+		return false, nil
+	})
+
+	return out, err
+}
+
+// Help returns the help text of the service.
+func (h *Hangman) Help() registry.Help {
+	return registry.Help{
+		Desc:     "play hangman. start a game and guess letters with the game ID.",
+		Syntax:   "new.hangman or $id-$letter.hangman",
+		Examples: []string{"dig new.hangman @%s", "dig ab12cd-e.hangman @%s"},
+	}
+}
+
+// CacheStats returns the statistics of the sessions store.
+func (h *Hangman) CacheStats() cache.Stats {
+	return h.sessions.CacheStats()
+}
+
+func (h *Hangman) newGame(q string) ([]string, error) {
+	h.mu.Lock()
+	w := h.words[h.rnd.Intn(len(h.words))]
+	h.mu.Unlock()
+
+	g := &game{word: w, guessed: make(map[byte]bool)}
+	id, err := h.sessions.Create(g)
+	if err != nil {
+		return nil, err
+	}
+
+	out := g.status(q, fmt.Sprintf("new game %s. guess: dig %s-e.hangman", id, id))
+	return out, nil
+}
+
+func (g *game) solved() bool {
+	for i := 0; i < len(g.word); i++ {
+		if !g.guessed[g.word[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// status returns the TXT record describing the game's state.
+func (g *game) status(q, msg string) []string {
+	mask := make([]string, len(g.word))
+	for i := 0; i < len(g.word); i++ {
+		if g.guessed[g.word[i]] {
+			mask[i] = string(g.word[i])
+		} else {
+			mask[i] = "_"
+		}
+	}
+
+	wrong := "-"
+	if len(g.wrong) > 0 {
+		wrong = string(g.wrong)
+	}
+
+	return []string{txt.Record(q,
+		strings.Join(mask, " "), fmt.Sprintf("lives %d", lives-len(g.wrong)),
+		fmt.Sprintf("wrong %s", wrong), msg)}
+}
@@ -0,0 +1,227 @@
+// Package life steps Conway's Game of Life.
+package life
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	// Patterns are placed on a board of at least this size that wraps
+	// around at the edges.
+	minBoard = 12
+	maxBoard = 32
+
+	maxGens = 200
+
+	live = 'o'
+	dead = '.'
+)
+
+// Named starting patterns as rows of 0s and 1s.
+var patterns = map[string][]string{
+	"glider":     {"010", "001", "111"},
+	"blinker":    {"111"},
+	"toad":       {"0111", "1110"},
+	"beacon":     {"1100", "1100", "0011", "0011"},
+	"lwss":       {"01001", "10000", "10001", "11110"},
+	"rpentomino": {"011", "110", "010"},
+	"diehard":    {"00000010", "11000000", "01000111"},
+	"acorn":      {"0100000", "0001000", "1100111"},
+	"pulsar": {
+		"0011100011100",
+		"0000000000000",
+		"1000010100001",
+		"1000010100001",
+		"1000010100001",
+		"0011100011100",
+		"0000000000000",
+		"0011100011100",
+		"1000010100001",
+		"1000010100001",
+		"1000010100001",
+		"0000000000000",
+		"0011100011100",
+	},
+}
+
+var grammar = query.NewGrammar(`(?P<grid>[a-z]+|[01]+(\.[01]+)*)(-(?P<gens>[0-9]{1,3}))?`,
+	"invalid life query. eg: glider-5.life, 010.001.111-5.life")
+
+// Life is the Game of Life service.
+type Life struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "life",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Life.
+func New() *Life {
+	return &Life{}
+}
+
+// Query returns a grid after N generations (default 1) as numbered TXT rows.
+// A grid is a named pattern or rows of 0s (dead) and 1s (live) separated
+// by dots.
+// Format: $grid or $grid-$n. eg: glider-5, 010.001.111-5
+func (l *Life) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	gens := 1
+	if args["gens"] != "" {
+		gens, _ = strconv.Atoi(args["gens"])
+	}
+	if gens > maxGens {
+		return nil, fmt.Errorf("too many generations. Max %d.", maxGens)
+	}
+
+	rows, ok := patterns[args["grid"]]
+	if !ok {
+		if args["grid"][0] != '0' && args["grid"][0] != '1' {
+			return nil, fmt.Errorf("unknown pattern. Try: %s", strings.Join(names(), ", "))
+		}
+		rows = strings.Split(args["grid"], ".")
+	}
+
+	b, err := newBoard(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < gens; i++ {
+		b = b.step()
+	}
+
+	out := make([]string, 0, len(b)+1)
+	pop := 0
+	for i, row := range b {
+		var s strings.Builder
+		for _, c := range row {
+			if c {
+				s.WriteByte(live)
+				pop++
+			} else {
+				s.WriteByte(dead)
+			}
+		}
+		out = append(out, txt.Record(q, fmt.Sprintf("%02d", i+1), s.String()))
+	}
+	out = append(out, txt.Record(q,
+		fmt.Sprintf("generation %d", gens), fmt.Sprintf("population %d", pop)))
+
+	return out, nil
+}
+
+type board [][]bool
+
+// newBoard returns a board with the pattern in the middle.
+func newBoard(rows []string) (board, error) {
+	w := 0
+	for _, r := range rows {
+		if len(r) > w {
+			w = len(r)
+		}
+	}
+
+	var (
+		bw = size(w)
+		bh = size(len(rows))
+	)
+	if w+2 > maxBoard || len(rows)+2 > maxBoard {
+		return nil, fmt.Errorf("grid too big. Max %dx%d.", maxBoard-2, maxBoard-2)
+	}
+
+	b := make(board, bh)
+	for y := range b {
+		b[y] = make([]bool, bw)
+	}
+
+	var (
+		oy = (bh - len(rows)) / 2
+		ox = (bw - w) / 2
+	)
+	for y, r := range rows {
+		for x, c := range r {
+			switch c {
+			case '1':
+				b[oy+y][ox+x] = true
+			case '0':
+			default:
+				return nil, errors.New("invalid grid. Rows should be 0s and 1s.")
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// size returns the board size for a pattern dimension.
+func size(n int) int {
+	n += 4
+	if n < minBoard {
+		return minBoard
+	}
+	if n > maxBoard {
+		return maxBoard
+	}
+	return n
+}
+
+// step returns the next generation of the board.
+func (b board) step() board {
+	var (
+		h   = len(b)
+		w   = len(b[0])
+		out = make(board, h)
+	)
+	for y := range b {
+		out[y] = make([]bool, w)
+		for x := range b[y] {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && b[(y+dy+h)%h][(x+dx+w)%w] {
+						n++
+					}
+				}
+			}
+			out[y][x] = n == 3 || (n == 2 && b[y][x])
+		}
+	}
+
+	return out
+}
+
+// names returns the sorted names of the patterns.
+func names() []string {
+	out := make([]string, 0, len(patterns))
+	for n := range patterns {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Help returns the help text of the service.
+func (l *Life) Help() registry.Help {
+	return registry.Help{
+		Desc:     "step Conway's Game of Life N generations from a named pattern or a grid of 0/1 rows.",
+		Syntax:   "$pattern-$n.life or $row.$row...-$n.life",
+		Examples: []string{"dig glider-5.life @%s", "dig 010.001.111-4.life @%s"},
+	}
+}
@@ -0,0 +1,146 @@
+// Package maze generates random ASCII mazes.
+package maze
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	minSize = 2
+	maxSize = 25
+
+	wall = '#'
+	path = ' '
+)
+
+var grammar = query.NewGrammar(`(seed-(?P<seed>[0-9]{1,18})-)?(?P<w>[0-9]{1,2})x(?P<h>[0-9]{1,2})`,
+	"invalid maze query. eg: 15x15.maze, seed-42-15x15.maze")
+
+// Maze is the maze generator.
+type Maze struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "maze",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Maze.
+func New() *Maze {
+	return &Maze{}
+}
+
+// Query returns a maze of width x height cells with one TXT record per row
+// and a record with the seed to generate it again. The entrance is at the
+// top left and the exit at the bottom right.
+// Format: $wx$h or seed-$seed-$wx$h. eg: 15x15, seed-42-15x15
+func (m *Maze) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	w, _ := args.Int("w")
+	h, _ := args.Int("h")
+	if w < minSize || w > maxSize || h < minSize || h > maxSize {
+		return nil, fmt.Errorf("invalid size. Should be %d-%d. eg: 15x15", minSize, maxSize)
+	}
+
+	seed := time.Now().UnixNano() % 1e9
+	if s := args["seed"]; s != "" {
+		seed, _ = strconv.ParseInt(s, 10, 64)
+	}
+
+	grid := generate(w, h, rand.New(rand.NewSource(seed)))
+
+	// Rows are numbered as resolvers may reorder records.
+	out := make([]string, 0, len(grid)+1)
+	for i, row := range grid {
+		out = append(out, txt.Record(q, fmt.Sprintf("%02d", i+1), row))
+	}
+	out = append(out, txt.Record(q,
+		fmt.Sprintf("seed %d", seed),
+		fmt.Sprintf("again: dig seed-%d-%dx%d.maze", seed, w, h)))
+
+	return out, nil
+}
+
+// generate carves a w x h cell maze with a randomized depth-first search
+// and returns its rows of 2w+1 characters.
+func generate(w, h int, rnd *rand.Rand) []string {
+	var (
+		rows = 2*h + 1
+		cols = 2*w + 1
+		g    = make([][]byte, rows)
+	)
+	for r := range g {
+		g[r] = make([]byte, cols)
+		for c := range g[r] {
+			g[r][c] = wall
+		}
+	}
+
+	type cell struct{ x, y int }
+	var (
+		dirs    = []cell{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+		visited = make([]bool, w*h)
+		stack   = []cell{{0, 0}}
+	)
+	visited[0] = true
+	g[1][1] = path
+
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+
+		// Unvisited neighbours.
+		next := make([]cell, 0, 4)
+		for _, d := range dirs {
+			n := cell{cur.x + d.x, cur.y + d.y}
+			if n.x >= 0 && n.x < w && n.y >= 0 && n.y < h && !visited[n.y*w+n.x] {
+				next = append(next, n)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// Knock down the wall to a random neighbour and move there.
+		n := next[rnd.Intn(len(next))]
+		visited[n.y*w+n.x] = true
+		g[cur.y+n.y+1][cur.x+n.x+1] = path
+		g[2*n.y+1][2*n.x+1] = path
+		stack = append(stack, n)
+	}
+
+	// Entrance and exit.
+	g[0][1] = path
+	g[rows-1][cols-2] = path
+
+	out := make([]string, rows)
+	for r := range g {
+		out[r] = string(g[r])
+	}
+
+	return out
+}
+
+// Help returns the help text of the service.
+func (m *Maze) Help() registry.Help {
+	return registry.Help{
+		Desc:     "generate a random ASCII maze (up to 25x25), reproducible with a seed.",
+		Syntax:   "$wx$h.maze or seed-$seed-$wx$h.maze",
+		Examples: []string{"dig 15x15.maze +tcp @%s", "dig seed-42-10x10.maze @%s"},
+	}
+}
@@ -0,0 +1,281 @@
+// Package molar computes molar masses of chemical formulae.
+package molar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max length of a formula to parse.
+const maxLen = 60
+
+type element struct {
+	Number int     `json:"number"`
+	Symbol string  `json:"symbol"`
+	Name   string  `json:"name"`
+	Mass   float64 `json:"mass"`
+}
+
+// Molar computes molar masses from the periodic table.
+type Molar struct {
+	// symbol -> element map.
+	elements map[string]element
+}
+
+// count represents the number of atoms of an element in a formula.
+type count struct {
+	symbol string
+	n      int
+}
+
+//go:embed elements.json
+var dataB []byte
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "molar",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Molar.
+func New() (*Molar, error) {
+	var els []element
+	if err := json.Unmarshal(dataB, &els); err != nil {
+		return nil, err
+	}
+
+	m := &Molar{
+		elements: make(map[string]element, len(els)),
+	}
+	for _, e := range els {
+		m.elements[e.Symbol] = e
+	}
+
+	return m, nil
+}
+
+// Query parses a chemical formula and returns its molar mass. Parentheses
+// aren't valid in hostnames, so groups are written between hyphens.
+// eg: H2SO4, h2so4, Ca-OH-2 for Ca(OH)2
+func (m *Molar) Query(q string) ([]string, error) {
+	if len(q) == 0 || len(q) > maxLen {
+		return nil, errors.New("invalid formula.")
+	}
+
+	formula := q
+
+	// All lowercase formulae (DNS is case insensitive) are ambiguous. Infer
+	// the case of the element symbols.
+	if strings.ToLower(q) == q {
+		f, ok := m.inferCase(q, 0, map[int]string{})
+		if !ok {
+			return nil, errors.New("invalid formula. eg: NaCl, Ca-OH-2")
+		}
+		formula = f
+	}
+
+	counts, err := m.parse(formula)
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	for _, c := range counts {
+		total += m.elements[c.symbol].Mass * float64(c.n)
+	}
+
+	out := make([]string, 0, len(counts)+1)
+	out = append(out, txt.Record(q, display(formula), fmt.Sprintf("%0.3f g/mol", total)))
+	for _, c := range counts {
+		var (
+			e    = m.elements[c.symbol]
+			mass = e.Mass * float64(c.n)
+		)
+
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("%s (%s)", e.Symbol, e.Name),
+			fmt.Sprintf("%d x %0.3f = %0.3f", c.n, e.Mass, mass),
+			fmt.Sprintf("%0.2f%%", mass/total*100)))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (m *Molar) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the molar mass of a chemical formula (groups go between hyphens, Ca-OH-2 for Ca(OH)2).",
+		Syntax:   "$formula.molar",
+		Examples: []string{"dig H2SO4.molar @%s", "dig C6H12O6.molar @%s", "dig Ca-OH-2.molar @%s"},
+	}
+}
+
+// Strict returns true as formulae with characters removed (eg: the
+// parentheses in Ca(OH)2) are different formulae.
+func (m *Molar) Strict() bool {
+	return true
+}
+
+// parse parses a properly cased chemical formula into element counts
+// in the order of appearance. Groups are written between hyphens and
+// can't be nested. eg: Ca-OH-2
+func (m *Molar) parse(f string) ([]count, error) {
+	var (
+		counts = map[string]int{}
+		order  = []string{}
+	)
+
+	add := func(sym string, n int) {
+		if _, ok := counts[sym]; !ok {
+			order = append(order, sym)
+		}
+		counts[sym] += n
+	}
+
+	for i := 0; i < len(f); {
+		// A group and its optional count.
+		if f[i] == '-' {
+			end := strings.IndexByte(f[i+1:], '-')
+			if end < 1 {
+				return nil, errors.New("invalid group in formula. Write groups between hyphens. eg: Ca-OH-2")
+			}
+
+			group, err := m.parse(f[i+1 : i+1+end])
+			if err != nil {
+				return nil, err
+			}
+			i += end + 2
+
+			n, ln := readNum(f[i:])
+			i += ln
+
+			for _, c := range group {
+				add(c.symbol, c.n*n)
+			}
+			continue
+		}
+
+		c := rune(f[i])
+		if !unicode.IsUpper(c) {
+			return nil, fmt.Errorf("invalid character in formula: %c.", c)
+		}
+
+		sym := f[i : i+1]
+		if i+1 < len(f) && unicode.IsLower(rune(f[i+1])) {
+			sym = f[i : i+2]
+		}
+		if _, ok := m.elements[sym]; !ok {
+			return nil, fmt.Errorf("unknown element: %s.", sym)
+		}
+		i += len(sym)
+
+		n, ln := readNum(f[i:])
+		i += ln
+
+		add(sym, n)
+	}
+
+	out := make([]count, 0, len(order))
+	for _, sym := range order {
+		out = append(out, count{symbol: sym, n: counts[sym]})
+	}
+
+	return out, nil
+}
+
+// inferCase converts an all lowercase formula to a properly cased formula
+// by backtracking, preferring single letter element symbols.
+func (m *Molar) inferCase(f string, i int, memo map[int]string) (string, bool) {
+	if i == len(f) {
+		return "", true
+	}
+	if s, ok := memo[i]; ok {
+		return s, s != ""
+	}
+
+	var (
+		out string
+		ok  bool
+		c   = rune(f[i])
+	)
+
+	if !unicode.IsLetter(c) {
+		if rest, o := m.inferCase(f, i+1, memo); o {
+			out, ok = string(c)+rest, true
+		}
+	} else {
+		for _, ln := range []int{1, 2} {
+			if i+ln > len(f) {
+				break
+			}
+
+			sym := strings.ToUpper(f[i:i+1]) + f[i+1:i+ln]
+			if _, exists := m.elements[sym]; !exists {
+				continue
+			}
+
+			if rest, o := m.inferCase(f, i+ln, memo); o {
+				out, ok = sym+rest, true
+				break
+			}
+		}
+	}
+
+	memo[i] = out
+	return out, ok
+}
+
+// display returns a formula with its groups in parentheses.
+// eg: Ca-OH-2 -> Ca(OH)2
+func display(f string) string {
+	var (
+		b    strings.Builder
+		open bool
+	)
+	for _, c := range f {
+		if c != '-' {
+			b.WriteRune(c)
+			continue
+		}
+
+		if open {
+			b.WriteByte(')')
+		} else {
+			b.WriteByte('(')
+		}
+		open = !open
+	}
+
+	return b.String()
+}
+
+// readNum reads an optional number at the beginning of the string and
+// returns it (1 if there's none) along with the number of chars read.
+func readNum(s string) (int, int) {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return 1, 0
+	}
+
+	v, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return 1, n
+	}
+
+	return v, n
+}
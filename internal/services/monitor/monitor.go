@@ -0,0 +1,425 @@
+// Package monitor is a lite uptime monitor. Clients register websites that
+// are checked periodically over HTTPS and query the results of the last
+// few checks.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/errs"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var grammar = query.NewGrammar(`(?P<op>watch|status)-(?P<host>[a-z0-9\-\.]+)`,
+	"invalid monitor query. eg: watch-example-com.monitor, status-example-com.monitor")
+
+var errNotPublic = errors.New("address is not public")
+
+// reserved are the special-purpose ranges in the IANA registries that aren't
+// private, loopback, link local, or multicast, which IsPrivate and
+// IsGlobalUnicast already cover.
+var reserved = func() []*net.IPNet {
+	var out []*net.IPNet
+	for _, c := range []string{
+		"0.0.0.0/8",       // This network.
+		"100.64.0.0/10",   // Shared address space (CGNAT).
+		"192.0.0.0/24",    // IETF protocol assignments.
+		"192.0.2.0/24",    // Documentation (TEST-NET-1).
+		"192.88.99.0/24",  // 6to4 relay anycast.
+		"198.18.0.0/15",   // Benchmarking.
+		"198.51.100.0/24", // Documentation (TEST-NET-2).
+		"203.0.113.0/24",  // Documentation (TEST-NET-3).
+		"240.0.0.0/4",     // Reserved.
+		"64:ff9b::/96",    // IPv4/IPv6 translation.
+		"64:ff9b:1::/48",  // Local-use IPv4/IPv6 translation.
+		"100::/64",        // Discard-only.
+		"2001::/23",       // IETF protocol assignments (incl. Teredo).
+		"2001:db8::/32",   // Documentation.
+		"2002::/16",       // 6to4.
+		"3fff::/20",       // Documentation.
+		"5f00::/16",       // Segment routing SIDs.
+	} {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+
+	return out
+}()
+
+// Opt are the options for the monitor service.
+type Opt struct {
+	// Interval between checks of a target and the timeout of a check.
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// Targets whose status hasn't been queried for this long are removed.
+	Expiry time.Duration
+
+	// Number of check results kept per target.
+	History int
+
+	// Maximum number of targets in total and per client IP.
+	MaxTargets   int
+	MaxPerClient int
+
+	// If set, only these domains and their subdomains can be watched.
+	Allow []string
+}
+
+// Monitor is the uptime monitor service.
+type Monitor struct {
+	opt Opt
+	hc  *http.Client
+
+	mu      sync.Mutex
+	targets map[string]*target
+	owners  map[string]int
+}
+
+type target struct {
+	owner    string
+	lastSeen time.Time
+	results  []result
+}
+
+type result struct {
+	at      time.Time
+	status  int
+	latency time.Duration
+	err     string
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "monitor",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(Opt{
+				Interval:     ko.MustDuration("interval"),
+				Timeout:      ko.MustDuration("timeout"),
+				Expiry:       ko.MustDuration("expiry"),
+				History:      ko.MustInt("history"),
+				MaxTargets:   ko.MustInt("max_targets"),
+				MaxPerClient: ko.MustInt("max_per_client"),
+				Allow:        ko.Strings("allow"),
+			}), nil
+		},
+	})
+}
+
+// New returns a new instance of Monitor and starts checking targets.
+func New(o Opt) *Monitor {
+	// Only connect to public addresses so that the monitor can't be used to
+	// probe the node's network.
+	d := &net.Dialer{
+		Timeout: o.Timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(net.ParseIP(host)) {
+				return errNotPublic
+			}
+			return nil
+		},
+	}
+
+	m := &Monitor{
+		opt: o,
+		hc: &http.Client{
+			Timeout: o.Timeout,
+			Transport: &http.Transport{
+				DialContext:         d.DialContext,
+				TLSHandshakeTimeout: o.Timeout,
+				MaxIdleConnsPerHost: 1,
+				DisableKeepAlives:   true,
+			},
+			// Report redirects as they are instead of following them.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		targets: make(map[string]*target),
+		owners:  make(map[string]int),
+	}
+
+	go func() {
+		for range time.Tick(o.Interval) {
+			m.checkAll()
+		}
+	}()
+
+	return m
+}
+
+// Query is not supported as targets are limited per client.
+func (m *Monitor) Query(q string) ([]string, error) {
+	return nil, errors.New("unable to detect IP.")
+}
+
+// QueryClient registers a target to watch or returns its last check results.
+// Dots in the domain can be written as hyphens, and then hyphens as two
+// hyphens. eg: my--site-com for my-site.com
+// Format: watch-$domain or status-$domain. eg: watch-example-com
+func (m *Monitor) QueryClient(q string, client net.IP) ([]string, error) {
+	if client == nil {
+		return nil, errors.New("unable to detect IP.")
+	}
+
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	host := args["host"]
+	if !strings.Contains(host, ".") {
+		host = unhyphenate(host)
+	}
+	if !strings.Contains(host, ".") || strings.HasPrefix(host, ".") || strings.HasSuffix(host, ".") {
+		return nil, errors.New("invalid domain. eg: watch-example-com.monitor")
+	}
+
+	if args["op"] == "status" {
+		return m.status(q, host)
+	}
+
+	if !m.allowed(host) {
+		return nil, errors.New("domain is not allowed.")
+	}
+
+	owner := client.String()
+
+	m.mu.Lock()
+	t, ok := m.targets[host]
+	if !ok {
+		if len(m.targets) >= m.opt.MaxTargets {
+			m.mu.Unlock()
+			return nil, errs.New(errs.RateLimited, "too many monitored sites. Try again later.")
+		}
+		if m.owners[owner] >= m.opt.MaxPerClient {
+			m.mu.Unlock()
+			return nil, errs.Newf(errs.RateLimited, "too many monitored sites. Max %d.", m.opt.MaxPerClient)
+		}
+
+		t = &target{owner: owner}
+		m.targets[host] = t
+		m.owners[owner]++
+	}
+	t.lastSeen = time.Now()
+	m.mu.Unlock()
+
+	if !ok {
+		go m.check(host)
+	}
+
+	statusQ := "status-" + hyphenate(host) + ".monitor"
+	return []string{txt.Record(q,
+		fmt.Sprintf("watching %s", host),
+		fmt.Sprintf("checked every %s", m.opt.Interval),
+		fmt.Sprintf("results with %s", statusQ))}, nil
+}
+
+// status returns the last check results of a target, latest first.
+func (m *Monitor) status(q, host string) ([]string, error) {
+	m.mu.Lock()
+	t, ok := m.targets[host]
+	if !ok {
+		m.mu.Unlock()
+		return nil, errors.New("domain is not being watched. eg: watch-example-com.monitor")
+	}
+	t.lastSeen = time.Now()
+	res := append([]result(nil), t.results...)
+	m.mu.Unlock()
+
+	if len(res) == 0 {
+		return []string{txt.Record(q, host, "not checked yet. Try again in a few seconds.")}, nil
+	}
+
+	out := make([]string, 0, len(res))
+	for i := len(res) - 1; i >= 0; i-- {
+		r := res[i]
+
+		state := "up"
+		if r.err != "" || r.status >= 500 {
+			state = "down"
+		}
+
+		detail := fmt.Sprintf("%d", r.status)
+		if r.err != "" {
+			detail = r.err
+		}
+
+		out = append(out, txt.Record(q,
+			host, state, detail, r.latency.Round(time.Millisecond).String(),
+			fmt.Sprintf("%s UTC", r.at.UTC().Format("15:04, Mon"))))
+	}
+
+	return out, nil
+}
+
+// checkAll removes expired targets and checks the rest.
+func (m *Monitor) checkAll() {
+	now := time.Now()
+
+	m.mu.Lock()
+	hosts := make([]string, 0, len(m.targets))
+	for host, t := range m.targets {
+		if now.Sub(t.lastSeen) > m.opt.Expiry {
+			delete(m.targets, host)
+			if m.owners[t.owner]--; m.owners[t.owner] <= 0 {
+				delete(m.owners, t.owner)
+			}
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	m.mu.Unlock()
+
+	// Check a few targets at a time.
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, 10)
+	)
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			m.check(host)
+		}(host)
+	}
+	wg.Wait()
+}
+
+// check makes a request to a target and records the result.
+func (m *Monitor) check(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opt.Timeout)
+	defer cancel()
+
+	r := result{at: time.Now()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/", nil)
+	if err != nil {
+		r.err = "invalid request"
+	} else {
+		req.Header.Set("User-Agent", "dns.toys uptime monitor")
+
+		resp, err := m.hc.Do(req)
+		if err != nil {
+			r.err = checkErr(err)
+		} else {
+			io.CopyN(ioutil.Discard, resp.Body, 1024*64)
+			resp.Body.Close()
+			r.status = resp.StatusCode
+		}
+	}
+	r.latency = time.Since(r.at)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.targets[host]
+	if !ok {
+		return
+	}
+	t.results = append(t.results, r)
+	if len(t.results) > m.opt.History {
+		t.results = t.results[len(t.results)-m.opt.History:]
+	}
+}
+
+// allowed checks whether a domain is in the allow list, if there is one.
+func (m *Monitor) allowed(host string) bool {
+	if len(m.opt.Allow) == 0 {
+		return true
+	}
+
+	for _, a := range m.opt.Allow {
+		a = strings.ToLower(strings.Trim(a, "."))
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkErr returns a short description of a failed check.
+func checkErr(err error) string {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	switch {
+	case errors.Is(err, errNotPublic):
+		return "address not public"
+	case errors.As(err, &dnsErr):
+		return "dns lookup failed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case strings.Contains(err.Error(), "certificate"):
+		return "tls error"
+	}
+
+	return "connection failed"
+}
+
+// hyphenate writes a domain with hyphens for dots and two hyphens for
+// hyphens. eg: my-site.com = my--site-com
+func hyphenate(host string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(host, "-", "--"), ".", "-")
+}
+
+// unhyphenate reverses hyphenate.
+func unhyphenate(s string) string {
+	parts := strings.Split(s, "--")
+	for i, p := range parts {
+		parts[i] = strings.ReplaceAll(p, "-", ".")
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// isPublic checks whether an IP is a public unicast address.
+func isPublic(ip net.IP) bool {
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Help returns the help text of the service.
+func (m *Monitor) Help() registry.Help {
+	return registry.Help{
+		Desc:     "watch a website's uptime (checked every few minutes) and get the last few check results.",
+		Syntax:   "watch-$domain.monitor or status-$domain.monitor (-- for a - in the domain)",
+		Examples: []string{"dig watch-example-com.monitor @%s", "dig status-my--site-com.monitor @%s"},
+		Upstream: true,
+	}
+}
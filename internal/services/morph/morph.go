@@ -0,0 +1,320 @@
+// Package morph implements English noun pluralization, singularization,
+// and verb conjugation.
+package morph
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+type fileData struct {
+	Plurals     map[string][]string `json:"plurals"`
+	Uncountable []string            `json:"uncountable"`
+	Verbs       map[string][]string `json:"verbs"`
+}
+
+// Morph holds the English morphology rules and irregular forms.
+type Morph struct {
+	plurals   map[string][]string
+	singulars map[string]string
+	uncount   map[string]bool
+
+	// base: {past, past participle}
+	verbs map[string][]string
+}
+
+// Plural pluralizes nouns.
+type Plural struct{ m *Morph }
+
+// Singular singularizes nouns.
+type Singular struct{ m *Morph }
+
+// Past conjugates verbs.
+type Past struct{ m *Morph }
+
+//go:embed irregular.json
+var dataB []byte
+
+var reWord = regexp.MustCompile(`^[a-z][a-z\-]*$`)
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "morph",
+		Suffixes: []string{"plural", "singular", "past"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Morph.
+func New() (*Morph, error) {
+	var d fileData
+	if err := json.Unmarshal(dataB, &d); err != nil {
+		return nil, err
+	}
+
+	m := &Morph{
+		plurals:   d.Plurals,
+		singulars: make(map[string]string),
+		uncount:   make(map[string]bool),
+		verbs:     d.Verbs,
+	}
+
+	for s, pl := range d.Plurals {
+		for _, p := range pl {
+			m.singulars[p] = s
+		}
+	}
+	for _, w := range d.Uncountable {
+		m.uncount[w] = true
+	}
+
+	return m, nil
+}
+
+// Plural returns the pluralization Service.
+func (m *Morph) Plural() *Plural {
+	return &Plural{m: m}
+}
+
+// Singular returns the singularization Service.
+func (m *Morph) Singular() *Singular {
+	return &Singular{m: m}
+}
+
+// Past returns the verb conjugation Service.
+func (m *Morph) Past() *Past {
+	return &Past{m: m}
+}
+
+// Service returns the service for a suffix (plural, singular, or past).
+func (m *Morph) Service(suffix string) registry.Service {
+	switch suffix {
+	case "plural":
+		return m.Plural()
+	case "singular":
+		return m.Singular()
+	}
+
+	return m.Past()
+}
+
+// Query returns the plural form(s) of a noun.
+func (p *Plural) Query(q string) ([]string, error) {
+	w, err := parseWord(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var forms []string
+	if p.m.uncount[w] {
+		forms = []string{w}
+	} else if f, ok := p.m.plurals[w]; ok {
+		forms = f
+	} else {
+		forms = []string{pluralize(w)}
+	}
+
+	return makeResp(q, w, forms), nil
+}
+
+// Help returns the help text of the service.
+func (p *Plural) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the plural of a noun.",
+		Syntax:   "$word.plural",
+		Examples: []string{"dig octopus.plural @%s", "dig child.plural @%s"},
+	}
+}
+
+// Query returns the singular form of a noun.
+func (s *Singular) Query(q string) ([]string, error) {
+	w, err := parseWord(q)
+	if err != nil {
+		return nil, err
+	}
+
+	form := w
+	if s.m.uncount[w] {
+		form = w
+	} else if f, ok := s.m.singulars[w]; ok {
+		form = f
+	} else if _, ok := s.m.plurals[w]; !ok {
+		form = singularize(w)
+	}
+
+	return makeResp(q, w, []string{form}), nil
+}
+
+// Help returns the help text of the service.
+func (s *Singular) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the singular of a noun.",
+		Syntax:   "$word.singular",
+		Examples: []string{"dig mice.singular @%s", "dig cities.singular @%s"},
+	}
+}
+
+// Query returns the past tense, past participle, present participle,
+// and third person singular forms of a verb.
+func (p *Past) Query(q string) ([]string, error) {
+	w, err := parseWord(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var past, pp string
+	if f, ok := p.m.verbs[w]; ok && len(f) == 2 {
+		past, pp = f[0], f[1]
+	} else {
+		past = pastTense(w)
+		pp = past
+	}
+
+	third := pluralize(w)
+	switch w {
+	case "be":
+		third = "is"
+	case "have":
+		third = "has"
+	}
+
+	r := txt.Record(q,
+		w, fmt.Sprintf("past: %s", past), fmt.Sprintf("past participle: %s", pp),
+		fmt.Sprintf("present participle: %s", presentParticiple(w)),
+		fmt.Sprintf("third person: %s", third))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (p *Past) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the past tense and other forms of a verb.",
+		Syntax:   "$verb.past",
+		Examples: []string{"dig run.past @%s", "dig go.past @%s"},
+	}
+}
+
+// pluralize applies the regular English pluralization rules.
+func pluralize(w string) string {
+	switch {
+	case hasSuffix(w, "s", "x", "z", "ch", "sh"):
+		return w + "es"
+	case strings.HasSuffix(w, "y") && !isVowel(w, len(w)-2):
+		return w[:len(w)-1] + "ies"
+	case strings.HasSuffix(w, "o") && !isVowel(w, len(w)-2):
+		return w + "es"
+	}
+
+	return w + "s"
+}
+
+// singularize applies the regular English singularization rules.
+func singularize(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case hasSuffix(w, "sses", "xes", "zes", "ches", "shes", "oes"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ss", "us", "is"):
+		return w
+	case strings.HasSuffix(w, "s") && len(w) > 1:
+		return w[:len(w)-1]
+	}
+
+	return w
+}
+
+// pastTense applies the regular English past tense rules.
+func pastTense(w string) string {
+	switch {
+	case strings.HasSuffix(w, "e"):
+		return w + "d"
+	case strings.HasSuffix(w, "y") && !isVowel(w, len(w)-2):
+		return w[:len(w)-1] + "ied"
+	case doubleFinal(w):
+		return w + w[len(w)-1:] + "ed"
+	}
+
+	return w + "ed"
+}
+
+// presentParticiple applies the regular English -ing rules.
+func presentParticiple(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ie"):
+		return w[:len(w)-2] + "ying"
+	case strings.HasSuffix(w, "ee"), strings.HasSuffix(w, "ye"), w == "be":
+		return w + "ing"
+	case strings.HasSuffix(w, "e") && len(w) > 2:
+		return w[:len(w)-1] + "ing"
+	case doubleFinal(w):
+		return w + w[len(w)-1:] + "ing"
+	}
+
+	return w + "ing"
+}
+
+// doubleFinal checks if the final consonant of a short word should be
+// doubled before a suffix (consonant-vowel-consonant). eg: stop, run.
+func doubleFinal(w string) bool {
+	n := len(w)
+	if n < 3 || n > 4 || strings.ContainsAny(w[n-1:], "wxy") {
+		return false
+	}
+
+	// Only one vowel group (single syllable).
+	vowels := 0
+	for i := range w {
+		if isVowel(w, i) && (i == 0 || !isVowel(w, i-1)) {
+			vowels++
+		}
+	}
+
+	return vowels == 1 && !isVowel(w, n-1) && isVowel(w, n-2) && !isVowel(w, n-3)
+}
+
+func isVowel(w string, i int) bool {
+	if i < 0 || i >= len(w) {
+		return false
+	}
+
+	return strings.IndexByte("aeiou", w[i]) >= 0
+}
+
+func hasSuffix(w string, suffixes ...string) bool {
+	for _, s := range suffixes {
+		if strings.HasSuffix(w, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseWord(q string) (string, error) {
+	w := strings.ToLower(q)
+	if !reWord.MatchString(w) || len(w) > 40 {
+		return "", errors.New("invalid word.")
+	}
+
+	return w, nil
+}
+
+func makeResp(q, w string, forms []string) []string {
+	out := make([]string, 0, len(forms))
+	for _, f := range forms {
+		out = append(out, txt.Record(q, w, f))
+	}
+
+	return out
+}
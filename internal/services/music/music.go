@@ -0,0 +1,207 @@
+// Package music implements musical note, frequency, and scale lookups.
+package music
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Frequency of the reference pitch A4 and its MIDI note number.
+const (
+	refFreq = 440.0
+	refNote = 69
+)
+
+var (
+	sharps = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flats  = []string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+
+	// Pitch classes of natural notes.
+	naturals = map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+
+	// Scale intervals in semitones from the root.
+	scales = map[string][]int{
+		"major":           {0, 2, 4, 5, 7, 9, 11},
+		"minor":           {0, 2, 3, 5, 7, 8, 10},
+		"harmonicminor":   {0, 2, 3, 5, 7, 8, 11},
+		"melodicminor":    {0, 2, 3, 5, 7, 9, 11},
+		"dorian":          {0, 2, 3, 5, 7, 9, 10},
+		"phrygian":        {0, 1, 3, 5, 7, 8, 10},
+		"lydian":          {0, 2, 4, 6, 7, 9, 11},
+		"mixolydian":      {0, 2, 4, 5, 7, 9, 10},
+		"locrian":         {0, 1, 3, 5, 6, 8, 10},
+		"pentatonic":      {0, 2, 4, 7, 9},
+		"minorpentatonic": {0, 3, 5, 7, 10},
+		"blues":           {0, 3, 5, 6, 7, 10},
+		"chromatic":       {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	}
+
+	// Notes names: letter, optional (s)harp or (b)flat, and an optional octave.
+	reNote = regexp.MustCompile(`^([a-g])(s|b)?(-?[0-9])?$`)
+	reFreq = regexp.MustCompile(`^([0-9\.]+)hz$`)
+)
+
+// Note converts between notes and frequencies.
+type Note struct{}
+
+// Scale lists notes in musical scales.
+type Scale struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "music",
+		Suffixes: []string{"note", "scale"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"note":  NewNote(),
+				"scale": NewScale(),
+			}, nil
+		},
+	})
+}
+
+// NewNote returns a new instance of Note.
+func NewNote() *Note {
+	return &Note{}
+}
+
+// NewScale returns a new instance of Scale.
+func NewScale() *Scale {
+	return &Scale{}
+}
+
+// Query returns the frequency of a note (eg: a4, cs5, bb3) or the nearest
+// note for a frequency (eg: 456hz).
+func (n *Note) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	// Frequency to note.
+	if res := reFreq.FindStringSubmatch(q); len(res) == 2 {
+		f, err := strconv.ParseFloat(res[1], 64)
+		if err != nil || f < 8 || f > 20000 {
+			return nil, errors.New("invalid frequency. Should be 8 - 20000 Hz.")
+		}
+
+		var (
+			exact = refNote + 12*math.Log2(f/refFreq)
+			num   = int(math.Round(exact))
+			cents = (exact - float64(num)) * 100
+		)
+
+		r := txt.Record(q,
+			fmt.Sprintf("%s Hz", formatNum(f)),
+			fmt.Sprintf("%s (%0.2f Hz)", noteName(num, sharps), freq(num)),
+			fmt.Sprintf("%+0.1f cents", cents))
+		return []string{r}, nil
+	}
+
+	// Note to frequency.
+	res := reNote.FindStringSubmatch(q)
+	if len(res) != 4 || res[3] == "" {
+		return nil, errors.New("invalid note query. eg: a4, cs5 (C#5), bb3 (Bb3), 456hz")
+	}
+
+	// Cb and B# cross octave boundaries, hence the unwrapped semitone.
+	oct, _ := strconv.Atoi(res[3])
+	num := 12*(oct+1) + semitone(res[1], res[2])
+
+	names := flats
+	if res[2] == "s" {
+		names = sharps
+	}
+
+	r := txt.Record(q,
+		noteName(num, names), fmt.Sprintf("%0.2f Hz", freq(num)), fmt.Sprintf("MIDI %d", num))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (n *Note) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert between musical notes and frequencies.",
+		Syntax:   "$note[s|b]$octave.note",
+		Examples: []string{"dig a4.note @%s", "dig cs5.note @%s"},
+	}
+}
+
+// Query returns the notes of a scale. eg: c-major, fs-minor, bb-blues
+func (s *Scale) Query(q string) ([]string, error) {
+	parts := strings.Split(strings.ToLower(q), "-")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid scale query. eg: c-major, fs-minor, bb-blues")
+	}
+
+	res := reNote.FindStringSubmatch(parts[0])
+	if len(res) != 4 || res[3] != "" {
+		return nil, fmt.Errorf("invalid root note: %s.", parts[0])
+	}
+
+	intervals, ok := scales[parts[1]]
+	if !ok {
+		return nil, errors.New("unknown scale. Should be one of major, minor, harmonicminor, melodicminor, dorian, phrygian, lydian, mixolydian, locrian, pentatonic, minorpentatonic, blues, chromatic.")
+	}
+
+	// Use sharps for sharp keys and flats for flat keys and F.
+	root := pitchClass(res[1], res[2])
+	names := sharps
+	if res[2] == "b" || (res[1] == "f" && res[2] == "") {
+		names = flats
+	}
+
+	notes := make([]string, 0, len(intervals))
+	for _, i := range intervals {
+		notes = append(notes, names[(root+i)%12])
+	}
+
+	r := txt.Record(q, fmt.Sprintf("%s %s", names[root], parts[1]), strings.Join(notes, " "))
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (s *Scale) Help() registry.Help {
+	return registry.Help{
+		Desc:     "list the notes in a musical scale.",
+		Syntax:   "$note-$scale.scale",
+		Examples: []string{"dig c-major.scale @%s", "dig a-minor.scale @%s"},
+	}
+}
+
+// semitone returns the semitone offset of a note letter and accidental from C.
+func semitone(letter, acc string) int {
+	s := naturals[letter[0]]
+	switch acc {
+	case "s":
+		s++
+	case "b":
+		s--
+	}
+
+	return s
+}
+
+// pitchClass returns the pitch class (0-11) of a note letter and accidental.
+func pitchClass(letter, acc string) int {
+	return (semitone(letter, acc) + 12) % 12
+}
+
+// noteName returns the name of a MIDI note number with its octave.
+func noteName(n int, names []string) string {
+	return fmt.Sprintf("%s%d", names[((n%12)+12)%12], int(math.Floor(float64(n)/12))-1)
+}
+
+// freq returns the equal temperament frequency of a MIDI note number.
+func freq(n int) float64 {
+	return refFreq * math.Pow(2, float64(n-refNote)/12)
+}
+
+func formatNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,159 @@
+// Package namegen generates random, plausible names for startups,
+// fantasy characters, and servers.
+package namegen
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const maxCount = 10
+
+//go:embed parts.json
+var partsJSON []byte
+
+type parts struct {
+	Startup struct {
+		Roots    []string `json:"roots"`
+		Suffixes []string `json:"suffixes"`
+	} `json:"startup"`
+
+	Fantasy struct {
+		Onsets   []string `json:"onsets"`
+		Vowels   []string `json:"vowels"`
+		Codas    []string `json:"codas"`
+		Epithets []string `json:"epithets"`
+	} `json:"fantasy"`
+
+	Server struct {
+		Adjectives []string `json:"adjectives"`
+		Nouns      []string `json:"nouns"`
+	} `json:"server"`
+}
+
+// NameGen generates names.
+type NameGen struct {
+	p parts
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "name",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of NameGen.
+func New() (*NameGen, error) {
+	var p parts
+	if err := json.Unmarshal(partsJSON, &p); err != nil {
+		return nil, err
+	}
+
+	return &NameGen{
+		p:   p,
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Query returns one or more random names of a kind.
+// Format: [$count.]$kind. eg: startup, 5.server
+func (n *NameGen) Query(q string) ([]string, error) {
+	var (
+		count = 1
+		kind  = strings.ToLower(q)
+	)
+	if p := strings.SplitN(kind, ".", 2); len(p) == 2 {
+		c, err := strconv.Atoi(p[0])
+		if err != nil || c < 1 || c > maxCount {
+			return nil, fmt.Errorf("invalid count. Should be 1-%d.", maxCount)
+		}
+		count, kind = c, p[1]
+	}
+
+	var fn func() string
+	switch kind {
+	case "startup":
+		fn = n.startup
+	case "fantasy":
+		fn = n.fantasy
+	case "server":
+		fn = n.server
+	default:
+		return nil, fmt.Errorf("unknown kind '%s'. Use startup, fantasy, or server. eg: 5.server", kind)
+	}
+
+	n.mu.Lock()
+	out := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		out = append(out, txt.Record(q, fn()))
+	}
+	n.mu.Unlock()
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (n *NameGen) Help() registry.Help {
+	return registry.Help{
+		Desc:     "generate random startup, fantasy, or server names.",
+		Syntax:   "[$count.](startup|fantasy|server).name",
+		Examples: []string{"dig 5.server.name @%s", "dig fantasy.name @%s"},
+	}
+}
+
+// startup returns a name such as "Pixelverse". The lock should be held.
+func (n *NameGen) startup() string {
+	s := n.pick(n.p.Startup.Roots) + n.pick(n.p.Startup.Suffixes)
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// fantasy returns a name such as "Thaelor the wise". The lock should be held.
+func (n *NameGen) fantasy() string {
+	var (
+		f = n.p.Fantasy
+		b strings.Builder
+	)
+
+	// Two or three syllables.
+	syl := 2 + n.rnd.Intn(2)
+	for i := 0; i < syl; i++ {
+		b.WriteString(n.pick(f.Onsets))
+		b.WriteString(n.pick(f.Vowels))
+	}
+	b.WriteString(n.pick(f.Codas))
+
+	s := b.String()
+	s = strings.ToUpper(s[:1]) + s[1:]
+
+	// Occasionally, an epithet.
+	if n.rnd.Intn(3) == 0 {
+		s += " " + n.pick(f.Epithets)
+	}
+
+	return s
+}
+
+// server returns a hostname such as "brave-falcon-42". The lock should be held.
+func (n *NameGen) server() string {
+	return fmt.Sprintf("%s-%s-%02d", n.pick(n.p.Server.Adjectives), n.pick(n.p.Server.Nouns), n.rnd.Intn(100))
+}
+
+func (n *NameGen) pick(l []string) string {
+	return l[n.rnd.Intn(len(l))]
+}
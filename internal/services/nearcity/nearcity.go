@@ -0,0 +1,102 @@
+// Package nearcity returns the cities nearest to a given coordinate pair.
+package nearcity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Number of nearest cities to return.
+const maxResults = 5
+
+var grammar = query.NewGrammar(`(?P<lat>-?[0-9\.]+)-(?P<lon>-?[0-9\.]+)`, "invalid coordinates. eg: 19.07-72.87 or 40.71--74.00")
+
+// NearCity does reverse geocoding of coordinates to known cities.
+type NearCity struct {
+	geo *geo.Geo
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "nearcity",
+		New: func(_ *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo()), nil
+		},
+	})
+}
+
+// New returns a new instance of NearCity.
+func New(g *geo.Geo) *NearCity {
+	return &NearCity{
+		geo: g,
+	}
+}
+
+// Query parses a given query string and returns the answer.
+// For the nearcity package, the query is a lat-lon pair. eg: 19.07-72.87
+func (n *NearCity) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	lat, err := args.Float("lat")
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, errors.New("invalid latitude.")
+	}
+
+	lon, err := args.Float("lon")
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, errors.New("invalid longitude.")
+	}
+
+	locs := n.geo.Nearest(lat, lon, maxResults)
+	if len(locs) == 0 {
+		return nil, errors.New("no cities found.")
+	}
+
+	out := make([]string, 0, len(locs))
+	for _, l := range locs {
+		r := txt.Record(q,
+			fmt.Sprintf("%s (%s)", l.Name, l.Country),
+			fmt.Sprintf("%0.5f, %0.5f", l.Lat, l.Lon),
+			fmt.Sprintf("%0.2f km", haversine(lat, lon, l.Lat, l.Lon)))
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (n *NearCity) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get cities nearest to a lat-lon pair.",
+		Syntax:   "$lat-$lon.nearcity",
+		Examples: []string{"dig 19.07-72.87.nearcity @%s", "dig 51.50--0.12.nearcity @%s"},
+	}
+}
+
+// haversine returns the great-circle distance in km between two points.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const (
+		earthRadiusKm = 6371.0
+		rad           = math.Pi / 180
+	)
+
+	var (
+		dLat = (lat2 - lat1) * rad
+		dLon = (lon2 - lon1) * rad
+	)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
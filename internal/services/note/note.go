@@ -0,0 +1,123 @@
+// Package note is a dead drop for one-time notes. A note is stored under
+// a random token and can be read exactly once, after which it's deleted.
+// It shares the note suffix with the music service and answers the queries
+// that start with put- or get-.
+package note
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/ephemeral"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	maxVal   = 200
+	tokenLen = 10
+)
+
+var grammar = query.NewGrammar(`put-(?P<val>.+)|get-(?P<token>[a-z0-9]{10})`,
+	"invalid query. eg: put-meet.at.noon.note, get-$token.note")
+
+// Note is the dead drop service.
+type Note struct {
+	store *ephemeral.Store
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "note",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(ephemeral.New(ko.MustDuration("ttl"), ko.MustInt("max_notes"), ko.MustInt("max_per_client"))), nil
+		},
+	})
+}
+
+// New returns a new instance of Note that keeps notes in the given store.
+func New(s *ephemeral.Store) *Note {
+	return &Note{store: s}
+}
+
+// Query is not supported as notes are subject to per-client quotas.
+func (n *Note) Query(q string) ([]string, error) {
+	return nil, errors.New("unable to detect IP.")
+}
+
+// Help returns the help text of the service.
+func (n *Note) Help() registry.Help {
+	return registry.Help{
+		Desc:     "leave a note that can be read exactly once with the returned token.",
+		Syntax:   "put-$note.note or get-$token.note",
+		Examples: []string{"dig put-meet.at.noon.note @%s", "dig get-abcd123456.note @%s"},
+	}
+}
+
+// Prefixes returns the prefixes of the queries to the note suffix that the
+// service answers.
+func (n *Note) Prefixes() []string {
+	return []string{"put-", "get-"}
+}
+
+// Sensitive keeps the notes and tokens in queries out of the logs.
+func (n *Note) Sensitive() bool {
+	return true
+}
+
+// CacheStats returns the statistics of the notes store.
+func (n *Note) CacheStats() cache.Stats {
+	return n.store.CacheStats()
+}
+
+// QueryClient stores a note and returns its token, or returns and deletes
+// the note for a token.
+// Format: put-$note or get-$token. eg: put-meet.at.noon
+func (n *Note) QueryClient(q string, client net.IP) ([]string, error) {
+	if client == nil {
+		return nil, errors.New("unable to detect IP.")
+	}
+
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := args["token"]; tok != "" {
+		it, ok := n.store.Take(tok)
+		if !ok {
+			return nil, errors.New("note not found. It may have been read or expired.")
+		}
+
+		return []string{txt.Record(q, it.Val, "this note has now been deleted")}, nil
+	}
+
+	val := args["val"]
+	if len(val) > maxVal {
+		return nil, fmt.Errorf("note too long. Max %d characters.", maxVal)
+	}
+
+	tok, it, err := n.store.Add(val, client.String(), tokenLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{txt.Record(q,
+		fmt.Sprintf("token %s", tok), fmt.Sprintf("read once with get-%s.note", tok),
+		fmt.Sprintf("expires in %s", time.Until(it.Expires).Round(time.Second)))}, nil
+}
+
+// Dump produces a gob dump of the notes.
+func (n *Note) Dump() ([]byte, error) {
+	return n.store.Dump()
+}
+
+// Load loads a gob dump of notes.
+func (n *Note) Load(b []byte) error {
+	return n.store.Load(b)
+}
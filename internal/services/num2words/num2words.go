@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
 )
 
 var (
@@ -24,6 +28,16 @@ var (
 
 type Num2Words struct{}
 
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "num2words",
+		Suffixes: []string{"words"},
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
 // New returns a new instance of Num2Words.
 func New() *Num2Words {
 	return &Num2Words{}
@@ -37,13 +51,17 @@ func (n *Num2Words) Query(q string) ([]string, error) {
 	}
 
 	w := num2words(num)
-	r := fmt.Sprintf("%s 1 TXT \"%d = %s\"", q, num, w)
+	r := txt.Record(q, fmt.Sprintf("%d = %s", num, w))
 	return []string{r}, nil
 }
 
-// Dump is not implemented in this package.
-func (n *Num2Words) Dump() ([]byte, error) {
-	return nil, nil
+// Help returns the help text of the service.
+func (n *Num2Words) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert numbers to words.",
+		Syntax:   "$number.words",
+		Examples: []string{"dig 123456.words @%s", "dig 42.words @%s"},
+	}
 }
 
 func num2words(number int) string {
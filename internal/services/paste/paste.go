@@ -0,0 +1,135 @@
+// Package paste is a pastebin for short values that expire after a while,
+// to pass small snippets between machines using only DNS.
+package paste
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/ephemeral"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const maxVal = 200
+
+var grammar = query.NewGrammar(`(?P<op>set|get|del)-(?P<key>[a-z0-9_]{1,32})(-(?P<val>.+))?`,
+	"invalid query. eg: set-mykey-hello.paste, get-mykey.paste")
+
+// Paste is the pastebin service.
+type Paste struct {
+	store *ephemeral.Store
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "paste",
+		Suffixes: []string{"paste"},
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(ephemeral.New(ko.MustDuration("ttl"), ko.MustInt("max_keys"), ko.MustInt("max_per_client"))), nil
+		},
+	})
+}
+
+// New returns a new instance of Paste that keeps values in the given store.
+func New(s *ephemeral.Store) *Paste {
+	return &Paste{store: s}
+}
+
+// Query is not supported as writes are subject to per-client quotas.
+func (p *Paste) Query(q string) ([]string, error) {
+	return nil, errors.New("unable to detect IP.")
+}
+
+// Help returns the help text of the service.
+func (p *Paste) Help() registry.Help {
+	return registry.Help{
+		Desc:     "store a short value for a while and get it from another machine (set-, get-, del-).",
+		Syntax:   "set-$key-$value.paste or get-$key.paste or del-$key.paste",
+		Examples: []string{"dig set-mykey-hello.paste @%s", "dig get-mykey.paste @%s"},
+	}
+}
+
+// Sensitive keeps the stored values in queries out of the logs.
+func (p *Paste) Sensitive() bool {
+	return true
+}
+
+// CacheStats returns the statistics of the values store.
+func (p *Paste) CacheStats() cache.Stats {
+	return p.store.CacheStats()
+}
+
+// QueryClient sets, gets, or deletes a value. Keys can only be overwritten
+// or deleted by the client (IP) that set them.
+// Format: set-$key-$value, get-$key, del-$key. eg: set-mykey-hello
+func (p *Paste) QueryClient(q string, client net.IP) ([]string, error) {
+	if client == nil {
+		return nil, errors.New("unable to detect IP.")
+	}
+
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		key = args["key"]
+		val = args["val"]
+	)
+
+	switch args["op"] {
+	case "set":
+		if val == "" {
+			return nil, errors.New("empty value. eg: set-mykey-hello.paste")
+		}
+		if len(val) > maxVal {
+			return nil, fmt.Errorf("value too long. Max %d characters.", maxVal)
+		}
+
+		it, err := p.store.Set(key, val, client.String())
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{txt.Record(q,
+			key, it.Val,
+			fmt.Sprintf("expires in %s", time.Until(it.Expires).Round(time.Second)))}, nil
+
+	case "get":
+		if val != "" {
+			return nil, errors.New("invalid query. eg: get-mykey.paste")
+		}
+
+		it, ok := p.store.Get(key)
+		if !ok {
+			return nil, errors.New("key not found or expired.")
+		}
+
+		return []string{txt.Record(q,
+			key, it.Val,
+			fmt.Sprintf("expires in %s", time.Until(it.Expires).Round(time.Second)))}, nil
+
+	default:
+		if err := p.store.Delete(key, client.String()); err != nil {
+			return nil, err
+		}
+
+		return []string{txt.Record(q, key, "deleted")}, nil
+	}
+}
+
+// Dump produces a gob dump of the stored values.
+func (p *Paste) Dump() ([]byte, error) {
+	return p.store.Dump()
+}
+
+// Load loads a gob dump of stored values.
+func (p *Paste) Load(b []byte) error {
+	return p.store.Load(b)
+}
@@ -0,0 +1,121 @@
+// Package petyears converts the ages of dogs and cats to human years.
+package petyears
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const maxAge = 40
+
+var grammar = query.NewGrammar(`(?P<age>[0-9]{1,2}(\.[0-9]{1,2})?)`, "invalid age. eg: 7, 2.5")
+
+// Dog converts dog years.
+type Dog struct{}
+
+// Cat converts cat years.
+type Cat struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "dogyears",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return &Dog{}, nil
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "catyears",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return &Cat{}, nil
+		},
+	})
+}
+
+// Query returns the human age of a dog with the logarithmic formula
+// (Wang et al. 2020, from DNA methylation) and the classic 7x rule.
+// Format: $age. eg: 7, 2.5
+func (d *Dog) Query(q string) ([]string, error) {
+	age, err := parseAge(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// The formula only holds for adult dogs.
+	modern := "n/a for dogs under 1 year"
+	if age >= 1 {
+		modern = fmt.Sprintf("%0.f human years", 16*math.Log(age)+31)
+	}
+
+	return []string{
+		txt.Record(q, fmt.Sprintf("%g dog years", age), modern, "16 ln(age) + 31"),
+		txt.Record(q,
+			fmt.Sprintf("%g dog years", age), fmt.Sprintf("%0.f human years", age*7), "classic 7x"),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (d *Dog) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert a dog's age to human years with the logarithmic formula and the classic 7x rule.",
+		Syntax:   "$age.dogyears",
+		Examples: []string{"dig 7.dogyears @%s", "dig 2.5.dogyears @%s"},
+	}
+}
+
+// Query returns the human age of a cat with the AAHA/iCatCare scale
+// (15 at 1, 24 at 2, and 4 per year after) and the classic 7x rule.
+// Format: $age. eg: 7, 2.5
+func (c *Cat) Query(q string) ([]string, error) {
+	age, err := parseAge(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var h float64
+	switch {
+	case age <= 1:
+		h = age * 15
+	case age <= 2:
+		h = 15 + (age-1)*9
+	default:
+		h = 24 + (age-2)*4
+	}
+
+	return []string{
+		txt.Record(q,
+			fmt.Sprintf("%g cat years", age), fmt.Sprintf("%0.f human years", h),
+			"15, 24, then +4/year"),
+		txt.Record(q,
+			fmt.Sprintf("%g cat years", age), fmt.Sprintf("%0.f human years", age*7), "classic 7x"),
+	}, nil
+}
+
+// Help returns the help text of the service.
+func (c *Cat) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert a cat's age to human years with the veterinary scale and the classic 7x rule.",
+		Syntax:   "$age.catyears",
+		Examples: []string{"dig 7.catyears @%s", "dig 2.5.catyears @%s"},
+	}
+}
+
+// parseAge parses an age in years.
+func parseAge(q string) (float64, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return 0, err
+	}
+
+	age, err := args.Float("age")
+	if err != nil || age <= 0 || age > maxAge {
+		return 0, fmt.Errorf("invalid age. Should be 0-%d years.", maxAge)
+	}
+
+	return age, nil
+}
@@ -0,0 +1,219 @@
+// Package prayer computes the day's Islamic prayer times for a city from
+// its coordinates with the standard calculation methods.
+package prayer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/astro"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Elevation of the sun's upper limb at sunrise and sunset with
+// atmospheric refraction.
+const horizon = -0.833
+
+// Method is a calculation convention with the sun's depression angles
+// (degrees below the horizon) for Fajr and Isha. Some methods have Isha
+// at a fixed interval after Maghrib instead.
+type Method struct {
+	Name      string
+	Fajr      float64
+	Isha      float64
+	IshaAfter time.Duration
+}
+
+// Methods are the supported calculation methods by their query names.
+var Methods = map[string]Method{
+	"mwl":     {Name: "Muslim World League", Fajr: 18, Isha: 17},
+	"isna":    {Name: "ISNA (North America)", Fajr: 15, Isha: 15},
+	"egypt":   {Name: "Egyptian General Authority of Survey", Fajr: 19.5, Isha: 17.5},
+	"makkah":  {Name: "Umm al-Qura, Makkah", Fajr: 18.5, IshaAfter: time.Minute * 90},
+	"karachi": {Name: "University of Islamic Sciences, Karachi", Fajr: 18, Isha: 18},
+	"tehran":  {Name: "Institute of Geophysics, Tehran", Fajr: 17.7, Isha: 14},
+	"jakim":   {Name: "JAKIM, Malaysia", Fajr: 20, Isha: 18},
+}
+
+// Prayer computes prayer times for cities.
+type Prayer struct {
+	geo    *geo.Geo
+	method string
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "prayer",
+		New: func(ko *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo(), ko.MustString("method"))
+		},
+	})
+}
+
+// New returns a new instance of Prayer that uses the given method for
+// queries that don't specify one.
+func New(g *geo.Geo, method string) (*Prayer, error) {
+	if _, ok := Methods[method]; !ok {
+		return nil, fmt.Errorf("unknown prayer method: %s", method)
+	}
+
+	return &Prayer{
+		geo:    g,
+		method: method,
+	}, nil
+}
+
+// Query returns the day's prayer times for a city in its timezone. The
+// city can be followed by a method and hanafi for the Hanafi Asr time.
+// Format: $city[/$country][-$method][-hanafi]. eg: mumbai, cairo-egypt,
+// karachi-karachi-hanafi
+func (p *Prayer) Query(q string) ([]string, error) {
+	var (
+		city   = strings.ToLower(q)
+		method = p.method
+		hanafi = false
+	)
+
+	// Optional trailing -$method and -hanafi.
+	if c := strings.TrimSuffix(city, "-hanafi"); c != city {
+		city, hanafi = c, true
+	}
+	if i := strings.LastIndexByte(city, '-'); i > 0 {
+		if _, ok := Methods[city[i+1:]]; ok {
+			city, method = city[:i], city[i+1:]
+		}
+	}
+
+	var (
+		str     = strings.Split(city, "/")
+		country = ""
+	)
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		city = str[0]
+		country = strings.ToUpper(str[1])
+	}
+
+	var loc *geo.Location
+	for _, l := range p.geo.Query(city) {
+		// Filter by country.
+		if country != "" && l.Country != country {
+			continue
+		}
+		loc = &l
+		break
+	}
+	if loc == nil {
+		return nil, fmt.Errorf("unknown city or method. Methods: %s", strings.Join(methodNames(), ", "))
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		zone = time.UTC
+	}
+
+	var (
+		m     = Methods[method]
+		now   = time.Now().In(zone)
+		times = compute(now, loc.Lat, loc.Lon, m, hanafi)
+	)
+
+	asr := "standard asr"
+	if hanafi {
+		asr = "hanafi asr"
+	}
+
+	out := []string{txt.Record(q,
+		fmt.Sprintf("%s (%s)", loc.Name, loc.Country),
+		now.Format("Mon, 02 Jan 2006"), m.Name, asr)}
+
+	for _, t := range times {
+		v := "n/a at this latitude"
+		if !t.time.IsZero() {
+			v = t.time.In(zone).Format("15:04 MST")
+		}
+		out = append(out, txt.Record(q, t.name, v))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (p *Prayer) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the day's Islamic prayer times for a city, with an optional calculation method (mwl, isna, egypt, makkah, karachi, tehran, jakim) and Hanafi Asr.",
+		Syntax:   "$city[/$country][-$method][-hanafi].prayer",
+		Examples: []string{"dig mumbai.prayer @%s", "dig cairo-egypt.prayer @%s", "dig karachi-karachi-hanafi.prayer @%s"},
+	}
+}
+
+type prayerTime struct {
+	name string
+
+	// Zero if the sun doesn't reach the angle for it.
+	time time.Time
+}
+
+// compute returns the prayer times on the date of t (in t's location).
+func compute(t time.Time, lat, lon float64, m Method, hanafi bool) []prayerTime {
+	var (
+		noon = astro.SolarNoon(t, lon)
+		decl = astro.SunPosition(noon, lat, lon).Declination
+	)
+
+	fajr, _, _ := astro.ElevationTimes(t, lat, lon, -m.Fajr)
+	sunrise, sunset, ok := astro.ElevationTimes(t, lat, lon, horizon)
+
+	// Asr is when an object's shadow is its noon shadow plus its
+	// length (twice its length for Hanafi).
+	factor := 1.0
+	if hanafi {
+		factor = 2
+	}
+	_, asr, _ := astro.ElevationTimes(t, lat, lon, deg(math.Atan(1/(factor+math.Tan(rad(math.Abs(lat-decl)))))))
+
+	var isha time.Time
+	if m.IshaAfter > 0 {
+		if ok {
+			isha = sunset.Add(m.IshaAfter)
+		}
+	} else {
+		_, isha, _ = astro.ElevationTimes(t, lat, lon, -m.Isha)
+	}
+
+	return []prayerTime{
+		{"fajr", fajr},
+		{"sunrise", sunrise},
+
+		// Dhuhr is just after the sun crosses the meridian.
+		{"dhuhr", noon.Add(time.Minute)},
+		{"asr", asr},
+		{"maghrib", sunset},
+		{"isha", isha},
+	}
+}
+
+// methodNames returns the sorted query names of the methods.
+func methodNames() []string {
+	out := make([]string, 0, len(Methods))
+	for n := range Methods {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,109 @@
+// Package probe returns padded responses of specific sizes to test what
+// DNS response sizes survive the network path to a client.
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	minSize = 128
+	maxSize = 4096
+
+	// Fixed bytes in an uncompressed response: the header (12), the
+	// question's QTYPE and QCLASS (4), and the answer's TYPE, CLASS, TTL,
+	// and RDLENGTH (10).
+	overhead = 12 + 4 + 10
+
+	prefix = "probe-"
+)
+
+var sizes = []string{"512", "1232", "1400", "1472", "4096"}
+
+// Probe returns padded responses.
+type Probe struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "probe",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Probe.
+func New() *Probe {
+	return &Probe{}
+}
+
+// Query returns a TXT response padded to the requested total message size in bytes.
+// Format: probe-$size, or $size for $size.probe. eg: probe-512, probe-1232
+func (p *Probe) Query(q string) ([]string, error) {
+	if q == "probe." {
+		r := txt.Record(q,
+			fmt.Sprintf("probe a response size (%d-%d bytes)", minSize, maxSize),
+			fmt.Sprintf("eg: %s%s", prefix, strings.Join(sizes, ", "+prefix)))
+		return []string{r}, nil
+	}
+
+	// probe-$size names have no suffix label to trim and keep the root.
+	name := strings.ToLower(strings.TrimSuffix(q, "."))
+	s, dashed := strings.CutPrefix(name, prefix)
+	size, err := strconv.Atoi(s)
+	if err != nil || size < minSize || size > maxSize {
+		return nil, fmt.Errorf("invalid size. Should be %d-%d. eg: probe-512, probe-1232", minSize, maxSize)
+	}
+
+	// The answer name is the name with the label length and root bytes,
+	// and so is the question name for probe-$size. For $size.probe, the
+	// question name also has the probe label.
+	var (
+		head  = fmt.Sprintf("probe %d bytes", size)
+		names = 2 * (len(name) + 2)
+	)
+	if !dashed {
+		names += len(".probe")
+	}
+
+	// Bytes left for the RDATA after the fixed overhead, the names, and the
+	// head string.
+	left := size - overhead - names - (1 + len(head))
+	if left < 0 {
+		return nil, errors.New("size too small.")
+	}
+
+	// Pad with character strings of up to 255 bytes, each with a length byte.
+	strs := []string{head}
+	for left > 0 {
+		n := left - 1
+		if n > txt.MaxLen {
+			n = txt.MaxLen
+		}
+		strs = append(strs, strings.Repeat("x", n))
+		left -= n + 1
+	}
+
+	return []string{txt.Record(q, strs...)}, nil
+}
+
+// Help returns the help text of the service.
+func (p *Probe) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get a response padded to N bytes to test what sizes survive your network path.",
+		Syntax:   "probe-$size",
+		Examples: []string{"dig probe-1232 +bufsize=4096 +ignore @%s", "dig probe-512 @%s"},
+	}
+}
+
+// Dashed routes probe-$size names to the service.
+func (p *Probe) Dashed() bool {
+	return true
+}
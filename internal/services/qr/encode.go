@@ -0,0 +1,401 @@
+package qr
+
+import "errors"
+
+// version represents the parameters of a QR code version at the
+// error correction level L. Only versions 1-4 are supported as they use
+// a single error correction block and produce small codes.
+type version struct {
+	num       int
+	dataCW    int
+	ecCW      int
+	alignment int
+}
+
+var versions = []version{
+	{1, 19, 7, 0},
+	{2, 34, 10, 18},
+	{3, 55, 15, 22},
+	{4, 80, 20, 26},
+}
+
+// Format bits of the error correction level L.
+const eclL = 1
+
+var errTooLong = errors.New("text is too long for a small QR code.")
+
+// code is a QR code module matrix. true is a dark module.
+type code struct {
+	size    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+// encode encodes the bytes into a QR code in the byte mode.
+func encode(data []byte) (*code, error) {
+	// Pick the smallest version that fits the data with the
+	// 4 bit mode and 8 bit count headers.
+	var ver *version
+	for i := range versions {
+		if len(data)+2 <= versions[i].dataCW {
+			ver = &versions[i]
+			break
+		}
+	}
+	if ver == nil {
+		return nil, errTooLong
+	}
+
+	cw := makeCodewords(data, ver)
+
+	size := 17 + ver.num*4
+	c := &code{
+		size:    size,
+		modules: makeMatrix(size),
+		isFunc:  makeMatrix(size),
+	}
+	c.drawFunctionPatterns(ver)
+	c.drawCodewords(cw)
+
+	// Apply the mask with the lowest penalty.
+	best, bestPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		c.applyMask(m)
+		c.drawFormatBits(m)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+
+		// XOR again to undo.
+		c.applyMask(m)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+
+	return c, nil
+}
+
+// makeCodewords returns the data and error correction codewords.
+func makeCodewords(data []byte, ver *version) []byte {
+	var (
+		bits = make([]bool, 0, ver.dataCW*8)
+		add  = func(v, n int) {
+			for i := n - 1; i >= 0; i-- {
+				bits = append(bits, (v>>uint(i))&1 == 1)
+			}
+		}
+	)
+
+	// Byte mode, character count, and the data.
+	add(0x4, 4)
+	add(len(data), 8)
+	for _, b := range data {
+		add(int(b), 8)
+	}
+
+	// Terminator and padding to a byte boundary.
+	capBits := ver.dataCW * 8
+	for i := 0; i < 4 && len(bits) < capBits; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	out := make([]byte, 0, ver.dataCW+ver.ecCW)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << uint(7-j)
+			}
+		}
+		out = append(out, b)
+	}
+
+	// Alternating pad bytes.
+	for pad := byte(0xec); len(out) < ver.dataCW; pad ^= 0xec ^ 0x11 {
+		out = append(out, pad)
+	}
+
+	return append(out, reedSolomon(out, ver.ecCW)...)
+}
+
+// reedSolomon computes n Reed-Solomon error correction codewords over GF(256).
+func reedSolomon(data []byte, n int) []byte {
+	// Generator polynomial: product of (x - a^i) for i in [0, n).
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+
+	res := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[n-1] = 0
+		for i := range res {
+			res[i] ^= gfMul(gen[i], factor)
+		}
+	}
+
+	return res
+}
+
+// gfMul multiplies two numbers in GF(256) with the polynomial 0x11d.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+
+	return byte(z)
+}
+
+func (c *code) drawFunctionPatterns(ver *version) {
+	// Timing patterns.
+	for i := 0; i < c.size; i++ {
+		c.setFunc(6, i, i%2 == 0)
+		c.setFunc(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with separators.
+	c.drawFinder(3, 3)
+	c.drawFinder(c.size-4, 3)
+	c.drawFinder(3, c.size-4)
+
+	// Alignment pattern.
+	if a := ver.alignment; a > 0 {
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				c.setFunc(a+dx, a+dy, max(abs(dx), abs(dy)) != 1)
+			}
+		}
+	}
+
+	// Reserve the format bits area.
+	c.drawFormatBits(0)
+}
+
+func (c *code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+
+			d := max(abs(dx), abs(dy))
+			c.setFunc(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormatBits draws the two copies of the format bits for the mask.
+func (c *code) drawFormatBits(mask int) {
+	data := eclL<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool {
+		return (bits>>uint(i))&1 == 1
+	}
+
+	// First copy around the top left finder.
+	for i := 0; i <= 5; i++ {
+		c.setFunc(8, i, bit(i))
+	}
+	c.setFunc(8, 7, bit(6))
+	c.setFunc(8, 8, bit(7))
+	c.setFunc(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunc(14-i, 8, bit(i))
+	}
+
+	// Second copy split across the other two finders.
+	for i := 0; i < 8; i++ {
+		c.setFunc(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunc(8, c.size-15+i, bit(i))
+	}
+
+	// Dark module.
+	c.setFunc(8, c.size-8, true)
+}
+
+// drawCodewords places the codeword bits in the zigzag order.
+func (c *code) drawCodewords(cw []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+
+				if c.isFunc[y][x] || i >= len(cw)*8 {
+					continue
+				}
+				c.modules[y][x] = (cw[i>>3]>>uint(7-(i&7)))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask XORs the data modules with a mask pattern.
+func (c *code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.isFunc[y][x] {
+				continue
+			}
+
+			var inv bool
+			switch mask {
+			case 0:
+				inv = (x+y)%2 == 0
+			case 1:
+				inv = y%2 == 0
+			case 2:
+				inv = x%3 == 0
+			case 3:
+				inv = (x+y)%3 == 0
+			case 4:
+				inv = (x/3+y/2)%2 == 0
+			case 5:
+				inv = x*y%2+x*y%3 == 0
+			case 6:
+				inv = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				inv = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			c.modules[y][x] = c.modules[y][x] != inv
+		}
+	}
+}
+
+// penalty computes the mask penalty score of the code.
+func (c *code) penalty() int {
+	var (
+		p    = 0
+		dark = 0
+		at   = func(x, y int, horiz bool) bool {
+			if horiz {
+				return c.modules[y][x]
+			}
+			return c.modules[x][y]
+		}
+	)
+
+	for _, horiz := range []bool{true, false} {
+		for y := 0; y < c.size; y++ {
+			// Runs of 5 or more modules of the same color.
+			run := 1
+			for x := 1; x < c.size; x++ {
+				if at(x, y, horiz) == at(x-1, y, horiz) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+
+			// Finder-like patterns 1:1:3:1:1 with 4 light modules on a side.
+			for x := 0; x+10 < c.size; x++ {
+				var a, b = true, true
+				for i, v := range []bool{true, false, true, true, true, false, true, false, false, false, false} {
+					if at(x+i, y, horiz) != v {
+						a = false
+					}
+					if at(x+10-i, y, horiz) != v {
+						b = false
+					}
+				}
+				if a {
+					p += 40
+				}
+				if b {
+					p += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+
+			// 2x2 blocks of the same color.
+			if x > 0 && y > 0 {
+				v := c.modules[y][x]
+				if v == c.modules[y-1][x] && v == c.modules[y][x-1] && v == c.modules[y-1][x-1] {
+					p += 3
+				}
+			}
+		}
+	}
+
+	// Deviation of the dark module proportion from 50%.
+	total := c.size * c.size
+	p += abs(dark*100/total-50) / 5 * 10
+
+	return p
+}
+
+func (c *code) setFunc(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunc[y][x] = true
+}
+
+func makeMatrix(size int) [][]bool {
+	m := make([][]bool, size)
+	for i := range m {
+		m[i] = make([]bool, size)
+	}
+
+	return m
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,122 @@
+// Package qr renders QR codes as text with Unicode block characters.
+package qr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Number of light modules around the code.
+const quietZone = 2
+
+// QR generates QR codes.
+type QR struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "qr",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of QR.
+func New() *QR {
+	return &QR{}
+}
+
+// Query renders the given text as a QR code. http(s) URLs can be written
+// with - instead of :// and . if the domain has no dots.
+// eg: https-example-com, https-dns.toys
+func (q *QR) Query(s string) ([]string, error) {
+	text := parseText(s)
+	if text == "" {
+		return nil, errors.New("invalid text.")
+	}
+
+	c, err := encode([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := c.render()
+	out := make([]string, 0, len(lines)+1)
+	out = append(out, txt.Record(s, text))
+	for _, l := range lines {
+		out = append(out, txt.Record(s, l))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (q *QR) Help() registry.Help {
+	return registry.Help{
+		Desc:     "render a QR code for a link or text.",
+		Syntax:   "$text.qr",
+		Examples: []string{"dig https-example-com.qr @%s", "dig hello.qr @%s"},
+	}
+}
+
+// parseText converts a query to the text to encode.
+func parseText(s string) string {
+	for _, scheme := range []string{"https", "http"} {
+		if !strings.HasPrefix(strings.ToLower(s), scheme+"-") {
+			continue
+		}
+
+		host := s[len(scheme)+1:]
+
+		// Domains without dots use - as the separator.
+		if !strings.Contains(host, ".") {
+			host = strings.ReplaceAll(host, "-", ".")
+		}
+
+		return scheme + "://" + host
+	}
+
+	return s
+}
+
+// render renders the code with half block characters, two rows of modules
+// per line. Light modules are drawn as blocks so that the code is readable
+// on terminals with dark backgrounds.
+func (c *code) render() []string {
+	var (
+		size  = c.size + quietZone*2
+		light = func(x, y int) bool {
+			x, y = x-quietZone, y-quietZone
+			if x < 0 || y < 0 || x >= c.size || y >= c.size {
+				return true
+			}
+			return !c.modules[y][x]
+		}
+	)
+
+	out := make([]string, 0, size/2+1)
+	for y := 0; y < size; y += 2 {
+		var b strings.Builder
+		for x := 0; x < size; x++ {
+			top, bottom := light(x, y), y+1 < size && light(x, y+1)
+
+			switch {
+			case top && bottom:
+				b.WriteRune('█')
+			case top:
+				b.WriteRune('▀')
+			case bottom:
+				b.WriteRune('▄')
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		out = append(out, b.String())
+	}
+
+	return out
+}
@@ -0,0 +1,267 @@
+// Package remind stores short reminders for clients (by IP) that are
+// returned when the client checks for them later.
+package remind
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	maxText = 64
+
+	// Due reminders are kept for this long if they're not checked.
+	keepDue = time.Hour * 24
+)
+
+var grammar = query.NewGrammar(`(remind-)?in-(?P<dur>[0-9]+[smhd]([0-9]+[smh])*)-(?P<text>[a-z0-9\-]+)`,
+	"invalid reminder. eg: remind-in-25m-standup.remind, check.remind")
+
+// Opt are the options for the remind service.
+type Opt struct {
+	// Maximum time into the future a reminder can be set for.
+	MaxDuration time.Duration
+
+	// Maximum number of pending reminders per client and in total.
+	MaxPerClient int
+	MaxClients   int
+}
+
+// Remind is the reminder service.
+type Remind struct {
+	opt Opt
+
+	mu    sync.Mutex
+	items map[string][]Reminder
+}
+
+// Reminder is a single reminder for a client.
+type Reminder struct {
+	Text    string
+	Created time.Time
+	Due     time.Time
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "remind",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(Opt{
+				MaxDuration:  ko.MustDuration("max_duration"),
+				MaxPerClient: ko.MustInt("max_per_client"),
+				MaxClients:   ko.MustInt("max_clients"),
+			}), nil
+		},
+	})
+}
+
+// New returns a new instance of Remind.
+func New(o Opt) *Remind {
+	r := &Remind{
+		opt:   o,
+		items: make(map[string][]Reminder),
+	}
+
+	go func() {
+		for range time.Tick(time.Minute * 10) {
+			r.sweep()
+		}
+	}()
+
+	return r
+}
+
+// Query is not supported as reminders are keyed to the client.
+func (r *Remind) Query(q string) ([]string, error) {
+	return nil, errors.New("unable to detect IP.")
+}
+
+// QueryClient sets a reminder for a client or returns its due and upcoming
+// reminders.
+// Format: remind-in-$duration-$text or check. eg: remind-in-25m-standup
+func (r *Remind) QueryClient(q string, client net.IP) ([]string, error) {
+	if client == nil {
+		return nil, errors.New("unable to detect IP.")
+	}
+	key := client.String()
+
+	if strings.ToLower(q) == "check" {
+		return r.check(q, key), nil
+	}
+
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := parseDuration(args["dur"])
+	if err != nil || d <= 0 {
+		return nil, errors.New("invalid duration. eg: 90s, 25m, 1h30m, 2d")
+	}
+	if d > r.opt.MaxDuration {
+		return nil, fmt.Errorf("reminders can be set up to %s ahead.", r.opt.MaxDuration)
+	}
+
+	text := strings.ReplaceAll(args["text"], "-", " ")
+	if len(text) > maxText {
+		return nil, fmt.Errorf("reminder text too long. Max %d characters.", maxText)
+	}
+
+	now := time.Now()
+	rem := Reminder{Text: text, Created: now, Due: now.Add(d)}
+
+	r.mu.Lock()
+	list, ok := r.items[key]
+	if !ok && len(r.items) >= r.opt.MaxClients {
+		r.mu.Unlock()
+		return nil, errors.New("too many reminders. Try again later.")
+	}
+	if len(list) >= r.opt.MaxPerClient {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("too many pending reminders. Max %d.", r.opt.MaxPerClient)
+	}
+	r.items[key] = append(list, rem)
+	r.mu.Unlock()
+
+	return []string{txt.Record(q,
+		fmt.Sprintf("reminder set for %s UTC (in %s)", rem.Due.UTC().Format("15:04, Mon"), d),
+		text)}, nil
+}
+
+// check returns the due and upcoming reminders of a client. Due reminders
+// are removed once returned.
+func (r *Remind) check(q, key string) []string {
+	now := time.Now()
+
+	r.mu.Lock()
+	list := r.items[key]
+	pending := make([]Reminder, 0, len(list))
+	for _, rem := range list {
+		if rem.Due.After(now) {
+			pending = append(pending, rem)
+		}
+	}
+	if len(pending) == 0 {
+		delete(r.items, key)
+	} else {
+		r.items[key] = pending
+	}
+	r.mu.Unlock()
+
+	if len(list) == 0 {
+		return []string{txt.Record(q, "no reminders.")}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Due.Before(list[j].Due)
+	})
+
+	out := make([]string, 0, len(list))
+	for _, rem := range list {
+		status := "due " + now.Sub(rem.Due).Round(time.Second).String() + " ago"
+		if rem.Due.After(now) {
+			status = "in " + rem.Due.Sub(now).Round(time.Second).String()
+		}
+
+		out = append(out, txt.Record(q,
+			rem.Text, status,
+			fmt.Sprintf("%s UTC", rem.Due.UTC().Format("15:04, Mon"))))
+	}
+
+	return out
+}
+
+// sweep removes reminders that have been due for long without being checked.
+func (r *Remind) sweep() {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, list := range r.items {
+		keep := list[:0]
+		for _, rem := range list {
+			if now.Sub(rem.Due) < keepDue {
+				keep = append(keep, rem)
+			}
+		}
+
+		if len(keep) == 0 {
+			delete(r.items, key)
+		} else {
+			r.items[key] = keep
+		}
+	}
+}
+
+// Help returns the help text of the service.
+func (r *Remind) Help() registry.Help {
+	return registry.Help{
+		Desc:     "set a reminder and check for due and upcoming ones later (per resolver IP).",
+		Syntax:   "remind-in-$duration-$text.remind or check.remind",
+		Examples: []string{"dig remind-in-25m-standup.remind @%s", "dig check.remind @%s"},
+	}
+}
+
+// Dump produces a gob dump of the reminders.
+func (r *Remind) Dump() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(r.items); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load loads a gob dump of reminders.
+func (r *Remind) Load(b []byte) error {
+	items := make(map[string][]Reminder)
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&items); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.items = items
+	r.mu.Unlock()
+
+	r.sweep()
+	return nil
+}
+
+// parseDuration parses a duration such as 25m or 1h30m, with d for days.
+func parseDuration(s string) (time.Duration, error) {
+	if i := strings.IndexByte(s, 'd'); i > 0 {
+		days, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, err
+		}
+
+		d := time.Duration(days) * time.Hour * 24
+		if rest := s[i+1:]; rest != "" {
+			r, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, err
+			}
+			d += r
+		}
+		return d, nil
+	}
+
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,294 @@
+// Package resistor decodes and encodes resistor color codes.
+package resistor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Colors in the order of their digit values.
+var digits = []string{"black", "brown", "red", "orange", "yellow", "green", "blue", "violet", "grey", "white"}
+
+var (
+	// Multiplier exponents for the colors that aren't digits.
+	multipliers = map[string]int{
+		"gold":   -1,
+		"silver": -2,
+	}
+
+	// Tolerance percentages.
+	tolerances = map[string]float64{
+		"brown":  1,
+		"red":    2,
+		"green":  0.5,
+		"blue":   0.25,
+		"violet": 0.1,
+		"grey":   0.05,
+		"gold":   5,
+		"silver": 10,
+	}
+
+	// Common alternate spellings.
+	aliases = map[string]string{
+		"gray":   "grey",
+		"purple": "violet",
+	}
+
+	reColors = regexp.MustCompile(`^([0-9\.]+)(k|m|g)?(ohm|r)(?:-([0-9\.]+)pc)?$`)
+)
+
+// Resistor decodes color bands to resistance values.
+type Resistor struct{}
+
+// Colors encodes resistance values to color bands.
+type Colors struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "resistor",
+		Suffixes: []string{"resistor", "resistorcolors"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"resistor":       New(),
+				"resistorcolors": NewColors(),
+			}, nil
+		},
+	})
+}
+
+// New returns a new instance of Resistor.
+func New() *Resistor {
+	return &Resistor{}
+}
+
+// NewColors returns a new instance of Colors.
+func NewColors() *Colors {
+	return &Colors{}
+}
+
+// Query decodes a list of 3, 4, or 5 color bands into a resistance value.
+// eg: red-red-brown-gold
+func (r *Resistor) Query(q string) ([]string, error) {
+	bands := strings.Split(strings.ToLower(q), "-")
+	if len(bands) < 3 || len(bands) > 5 {
+		return nil, errors.New("invalid resistor query. Pass 3 to 5 color bands. eg: red-red-brown-gold")
+	}
+
+	for i, b := range bands {
+		if a, ok := aliases[b]; ok {
+			bands[i] = a
+		}
+	}
+
+	// Number of significant digit bands.
+	nDigits := 2
+	if len(bands) == 5 {
+		nDigits = 3
+	}
+
+	val := 0
+	for _, b := range bands[:nDigits] {
+		d := digitVal(b)
+		if d < 0 {
+			return nil, fmt.Errorf("invalid digit band color: %s.", b)
+		}
+		val = val*10 + d
+	}
+
+	// Multiplier.
+	m := bands[nDigits]
+	exp := digitVal(m)
+	if exp < 0 {
+		e, ok := multipliers[m]
+		if !ok {
+			return nil, fmt.Errorf("invalid multiplier band color: %s.", m)
+		}
+		exp = e
+	}
+
+	// Tolerance. 3 band resistors have no tolerance band (20%).
+	tol := 20.0
+	if len(bands) > nDigits+1 {
+		t, ok := tolerances[bands[nDigits+1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid tolerance band color: %s.", bands[nDigits+1])
+		}
+		tol = t
+	}
+
+	ohms := float64(val) * math.Pow10(exp)
+
+	// The exact value in ohms is only added when it's shown with a prefix.
+	strs := []string{formatOhms(ohms)}
+	if ohms >= 1e3 {
+		strs = append(strs, fmt.Sprintf("%s ohms", strconv.FormatFloat(ohms, 'f', -1, 64)))
+	}
+	strs = append(strs,
+		fmt.Sprintf("+/- %s%%", formatNum(tol)),
+		fmt.Sprintf("%s - %s", formatOhms(ohms*(1-tol/100)), formatOhms(ohms*(1+tol/100))))
+
+	return []string{txt.Record(q, strs...)}, nil
+}
+
+// Help returns the help text of the service.
+func (r *Resistor) Help() registry.Help {
+	return registry.Help{
+		Desc:     "decode resistor color bands.",
+		Syntax:   "$color-$color-$color[-$color].resistor",
+		Examples: []string{"dig red-red-brown-gold.resistor @%s", "dig brown-black-black-red-brown.resistor @%s"},
+	}
+}
+
+// Query encodes a resistance value into color bands.
+// eg: 220ohm-5pc, 4.7kohm, 10kohm-1pc
+func (c *Colors) Query(q string) ([]string, error) {
+	res := reColors.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 5 {
+		return nil, errors.New("invalid resistorcolors query. eg: 220ohm-5pc, 4.7kohm")
+	}
+
+	val, err := strconv.ParseFloat(res[1], 64)
+	if err != nil || val <= 0 {
+		return nil, errors.New("invalid resistance.")
+	}
+
+	switch res[2] {
+	case "k":
+		val *= 1e3
+	case "m":
+		val *= 1e6
+	case "g":
+		val *= 1e9
+	}
+
+	// Tolerance band, defaulting to gold (5%).
+	tol := "gold"
+	if res[4] != "" {
+		t, err := strconv.ParseFloat(res[4], 64)
+		if err != nil {
+			return nil, errors.New("invalid tolerance.")
+		}
+
+		tol = ""
+		for color, v := range tolerances {
+			if v == t {
+				tol = color
+				break
+			}
+		}
+		if tol == "" {
+			return nil, errors.New("invalid tolerance. Should be one of 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10pc.")
+		}
+	}
+
+	out := []string{}
+	for _, n := range []int{2, 3} {
+		bands, ok := encode(val, n)
+		if !ok {
+			continue
+		}
+
+		out = append(out, txt.Record(q,
+			formatOhms(val), fmt.Sprintf("%d-band", n+2),
+			strings.Join(append(bands, tol), " ")))
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("resistance cannot be represented with standard color bands.")
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (c *Colors) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get color bands for a resistance.",
+		Syntax:   "$value(ohm|k|m)[-$tolerancepc].resistorcolors",
+		Examples: []string{"dig 220ohm-5pc.resistorcolors @%s", "dig 4.7kohm.resistorcolors @%s"},
+	}
+}
+
+// encode returns the significant digit and multiplier bands for the given
+// value using n significant digits.
+func encode(val float64, n int) ([]string, bool) {
+	// Work with integer hundredths of an ohm to avoid float errors
+	// as the smallest multiplier is 0.01 (silver).
+	v := int64(math.Round(val * 100))
+	if v <= 0 || float64(v) != math.Round(val*100) {
+		return nil, false
+	}
+
+	exp := -2
+	for v%10 == 0 && v >= int64(math.Pow10(n)) {
+		v /= 10
+		exp++
+	}
+
+	s := strconv.FormatInt(v, 10)
+	if len(s) > n {
+		return nil, false
+	}
+
+	// Pad the digits. eg: 1 ohm = brown black (10) x 0.1.
+	for len(s) < n {
+		s += "0"
+		exp--
+	}
+	if exp < -2 || exp > 9 {
+		return nil, false
+	}
+
+	out := make([]string, 0, n+1)
+	for _, d := range s {
+		out = append(out, digits[d-'0'])
+	}
+
+	switch {
+	case exp >= 0:
+		out = append(out, digits[exp])
+	case exp == -1:
+		out = append(out, "gold")
+	default:
+		out = append(out, "silver")
+	}
+
+	return out, true
+}
+
+// digitVal returns the digit value of a color or -1 if it isn't a digit color.
+func digitVal(c string) int {
+	for i, d := range digits {
+		if d == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// formatOhms formats a resistance value with SI prefixes.
+func formatOhms(v float64) string {
+	switch {
+	case v >= 1e9:
+		return formatNum(v/1e9) + "G ohm"
+	case v >= 1e6:
+		return formatNum(v/1e6) + "M ohm"
+	case v >= 1e3:
+		return formatNum(v/1e3) + "k ohm"
+	}
+
+	return formatNum(v) + " ohm"
+}
+
+func formatNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,136 @@
+// Package richter compares the energy released by earthquakes of different
+// magnitudes.
+package richter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Joules in a ton of TNT.
+const tntJoules = 4.184e9
+
+const maxMag = 10
+
+var grammar = query.NewGrammar(`(?P<a>[0-9]{1,2}(\.[0-9]{1,2})?)(-vs-(?P<b>[0-9]{1,2}(\.[0-9]{1,2})?))?`,
+	"invalid richter query. eg: 6.5-vs-7.2, 5.8")
+
+// TNT equivalent units.
+var tntUnits = []struct {
+	tons float64
+	name string
+}{
+	{1e9, "Gt"},
+	{1e6, "Mt"},
+	{1e3, "kt"},
+	{1, "t"},
+}
+
+// Richter compares earthquake magnitudes.
+type Richter struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "richter",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Richter.
+func New() *Richter {
+	return &Richter{}
+}
+
+// Query returns the energy released by a quake of a magnitude, or by two
+// quakes and how many times more the larger one releases.
+// Format: $mag or $mag-vs-$mag. eg: 6.5-vs-7.2
+func (r *Richter) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	a, _ := args.Float("a")
+	mags := []float64{a}
+	if args["b"] != "" {
+		b, _ := args.Float("b")
+		mags = append(mags, b)
+	}
+
+	out := make([]string, 0, len(mags)+1)
+	for _, m := range mags {
+		if m > maxMag {
+			return nil, fmt.Errorf("invalid magnitude. Should be 0-%d.", maxMag)
+		}
+
+		e := energy(m)
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("M%g", m), fmt.Sprintf("%0.3g J", e),
+			fmt.Sprintf("%s TNT", tnt(e))))
+	}
+
+	if len(mags) == 1 {
+		return out, nil
+	}
+
+	small, large := mags[0], mags[1]
+	if small > large {
+		small, large = large, small
+	}
+	if small == large {
+		return nil, errors.New("the magnitudes are the same.")
+	}
+
+	// Energy grows 10^1.5 (~31.6) times and the ground motion amplitude
+	// 10 times per unit of magnitude.
+	d := large - small
+	out = append(out, txt.Record(q,
+		fmt.Sprintf("M%g releases %s times the energy of M%g", large, times(math.Pow(10, 1.5*d)), small),
+		fmt.Sprintf("%s times the amplitude", times(math.Pow(10, d)))))
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (r *Richter) Help() registry.Help {
+	return registry.Help{
+		Desc:     "compare the energy released by earthquakes of two magnitudes, with TNT equivalents.",
+		Syntax:   "$magnitude-vs-$magnitude.richter or $magnitude.richter",
+		Examples: []string{"dig 6.5-vs-7.2.richter @%s", "dig 9.1.richter @%s"},
+	}
+}
+
+// energy returns the energy (joules) released by a quake of magnitude m
+// with the Gutenberg-Richter relation: log10(E) = 1.5M + 4.8.
+func energy(m float64) float64 {
+	return math.Pow(10, 1.5*m+4.8)
+}
+
+// tnt formats joules as tons of TNT in the largest unit that fits.
+func tnt(j float64) string {
+	t := j / tntJoules
+	for _, u := range tntUnits {
+		if t >= u.tons {
+			return fmt.Sprintf("%0.3g %s", t/u.tons, u.name)
+		}
+	}
+
+	return fmt.Sprintf("%0.3g kg", t*1000)
+}
+
+// times formats a ratio with fewer decimals as it grows.
+func times(v float64) string {
+	if v >= 100 {
+		return fmt.Sprintf("%0.f", v)
+	}
+
+	return fmt.Sprintf("%0.1f", v)
+}
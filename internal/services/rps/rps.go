@@ -0,0 +1,151 @@
+// Package rps implements rock-paper-scissors against the server.
+package rps
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/session"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Wins needed to take a best-of-five match.
+const matchWins = 3
+
+var moves = []string{"rock", "paper", "scissors"}
+
+// RPS is the rock-paper-scissors game.
+type RPS struct {
+	sessions *session.Store
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+type match struct {
+	you, server, draws int
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "rps",
+		New: func(ko *koanf.Koanf, _ *registry.Env) (registry.Service, error) {
+			return New(session.New(ko.MustDuration("session_ttl"), ko.MustInt("max_sessions"))), nil
+		},
+	})
+}
+
+// New returns a new instance of RPS that uses the given session store
+// to keep track of best-of-five matches.
+func New(s *session.Store) *RPS {
+	return &RPS{
+		sessions: s,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Query plays a move, or starts or plays a best-of-five match.
+// Format: $move, new (new match), $id-$move. eg: rock, ab12cd-paper
+func (r *RPS) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+	if q == "new" {
+		id, err := r.sessions.Create(&match{})
+		if err != nil {
+			return nil, err
+		}
+
+		out := txt.Record(q,
+			fmt.Sprintf("new best-of-five match %s", id), fmt.Sprintf("dig %s-rock.rps", id))
+		return []string{out}, nil
+	}
+
+	var (
+		id   string
+		move = q
+	)
+	if p := strings.Split(q, "-"); len(p) == 2 {
+		id, move = p[0], p[1]
+	}
+
+	you := index(move)
+	if you < 0 {
+		return nil, errors.New("invalid move. Use rock, paper, or scissors. eg: rock, new, ab12cd-rock")
+	}
+
+	r.mu.Lock()
+	srv := r.rnd.Intn(len(moves))
+	r.mu.Unlock()
+
+	// (you - server) mod 3: 0 = draw, 1 = you win, 2 = server wins.
+	res := (you - srv + 3) % 3
+	result := [...]string{"draw", "you win", "you lose"}[res]
+
+	// A single round.
+	if id == "" {
+		out := txt.Record(q,
+			fmt.Sprintf("you %s", moves[you]), fmt.Sprintf("server %s", moves[srv]), result)
+		return []string{out}, nil
+	}
+
+	var out string
+	err := r.sessions.Update(id, func(v interface{}) (bool, error) {
+		m := v.(*match)
+		switch res {
+		case 0:
+			m.draws++
+		case 1:
+			m.you++
+		case 2:
+			m.server++
+		}
+
+		score := fmt.Sprintf("score you %d - %d server", m.you, m.server)
+		status := "first to 3 wins"
+		done := false
+		if m.you >= matchWins {
+			status, done = "you won the match!", true
+		} else if m.server >= matchWins {
+			status, done = "the server won the match", true
+		}
+
+		out = txt.Record(q,
+			fmt.Sprintf("you %s", moves[you]), fmt.Sprintf("server %s", moves[srv]), result,
+			score, status)
+		return done, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{out}, nil
+}
+
+// Help returns the help text of the service.
+func (r *RPS) Help() registry.Help {
+	return registry.Help{
+		Desc:     "play rock-paper-scissors (new for a best-of-five match).",
+		Syntax:   "$move.rps or new.rps or $id-$move.rps",
+		Examples: []string{"dig rock.rps @%s", "dig new.rps @%s"},
+	}
+}
+
+// CacheStats returns the statistics of the sessions store.
+func (r *RPS) CacheStats() cache.Stats {
+	return r.sessions.CacheStats()
+}
+
+func index(move string) int {
+	for i, m := range moves {
+		if m == move {
+			return i
+		}
+	}
+	return -1
+}
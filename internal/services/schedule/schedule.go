@@ -0,0 +1,138 @@
+// Package schedule previews the next occurrences of simple interval schedules.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	numOccurrences = 5
+	minInterval    = time.Minute
+	maxInterval    = 366 * 24 * time.Hour
+)
+
+var reParse = regexp.MustCompile(`^every-([0-9]+)(m|h|d)(-from-([0-9]{4}))?(-([a-z/]+))?$`)
+
+// Schedule previews interval schedules.
+type Schedule struct {
+	geo *geo.Geo
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "schedule",
+		New: func(_ *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo()), nil
+		},
+	})
+}
+
+// New returns a new instance of Schedule.
+func New(g *geo.Geo) *Schedule {
+	return &Schedule{
+		geo: g,
+	}
+}
+
+// Query parses an interval schedule and returns its next few occurrences.
+// Format: every-$n(m|h|d)[-from-$HHMM][-$city]. eg: every-90m-from-0800-mumbai
+// The schedule starts at HHMM today (midnight by default) in the city's
+// timezone (UTC by default) and repeats at the interval without resetting daily.
+func (s *Schedule) Query(q string) ([]string, error) {
+	res := reParse.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 7 {
+		return nil, errors.New("invalid schedule. eg: every-90m, every-2h-from-0930, every-90m-from-0800-mumbai")
+	}
+
+	n, _ := strconv.Atoi(res[1])
+	every := time.Duration(n) * map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[res[2]]
+	if every < minInterval || every > maxInterval {
+		return nil, errors.New("invalid interval. Should be between 1m and 366d.")
+	}
+
+	var hh, mm int
+	if res[4] != "" {
+		hh, _ = strconv.Atoi(res[4][:2])
+		mm, _ = strconv.Atoi(res[4][2:])
+		if hh > 23 || mm > 59 {
+			return nil, errors.New("invalid start time. Should be HHMM. eg: 0800")
+		}
+	}
+
+	zone := time.UTC
+	if res[6] != "" {
+		z, err := s.zone(res[6])
+		if err != nil {
+			return nil, err
+		}
+		zone = z
+	}
+
+	var (
+		now     = time.Now().In(zone)
+		y, m, d = now.Date()
+		start   = time.Date(y, m, d, hh, mm, 0, 0, zone)
+	)
+
+	// Skip to the first occurrence after now.
+	next := start
+	if now.After(start) {
+		k := now.Sub(start)/every + 1
+		next = start.Add(k * every)
+	}
+
+	out := make([]string, 0, numOccurrences)
+	for i := 0; i < numOccurrences; i++ {
+		t := next.Add(time.Duration(i) * every)
+		out = append(out, txt.Record(q,
+			t.Format("Mon, 02 Jan 2006 15:04 MST"),
+			fmt.Sprintf("in %s", strings.TrimSuffix(t.Sub(now).Round(time.Minute).String(), "0s"))))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (s *Schedule) Help() registry.Help {
+	return registry.Help{
+		Desc:     "preview the next occurrences of an interval schedule in a city's timezone.",
+		Syntax:   "every-$n(m|h|d)[-from-$HHMM][-$city].schedule",
+		Examples: []string{"dig every-90m-from-0800-mumbai.schedule @%s", "dig every-2h-from-0930.schedule @%s"},
+	}
+}
+
+// zone returns the timezone of a city with an optional /country code.
+func (s *Schedule) zone(city string) (*time.Location, error) {
+	var (
+		str     = strings.Split(city, "/")
+		country = ""
+	)
+	if len(str) == 2 && len(str[1]) == 2 {
+		city = str[0]
+		country = strings.ToUpper(str[1])
+	}
+
+	for _, l := range s.geo.Query(city) {
+		if country != "" && l.Country != country {
+			continue
+		}
+
+		z, err := time.LoadLocation(l.Timezone)
+		if err != nil {
+			return nil, errors.New("unknown timezone for city.")
+		}
+		return z, nil
+	}
+
+	return nil, errors.New("unknown city.")
+}
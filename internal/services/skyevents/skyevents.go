@@ -0,0 +1,271 @@
+// Package skyevents lists upcoming eclipses and meteor shower peaks from
+// embedded tables of astronomical events.
+package skyevents
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max number of events in a response.
+const maxEvents = 5
+
+//go:embed eclipses.tsv
+var eclipsesB []byte
+
+//go:embed meteors.tsv
+var meteorsB []byte
+
+type eclipse struct {
+	Time    time.Time
+	Body    string
+	Kind    string
+	Visible string
+}
+
+type shower struct {
+	ID     string
+	Month  time.Month
+	Day    int
+	Name   string
+	ZHR    int
+	Parent string
+}
+
+// Eclipses is the eclipses service.
+type Eclipses struct {
+	list []eclipse
+}
+
+// Meteors is the meteor showers service.
+type Meteors struct {
+	list []shower
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "eclipses",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewEclipses()
+		},
+	})
+
+	registry.Register(registry.Entry{
+		Name: "meteors",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return NewMeteors()
+		},
+	})
+}
+
+// NewEclipses returns a new instance of Eclipses.
+func NewEclipses() (*Eclipses, error) {
+	e := &Eclipses{}
+	err := readTSV(eclipsesB, 4, func(l []string) error {
+		t, err := time.Parse("2006-01-02T15:04", l[0])
+		if err != nil {
+			return err
+		}
+		e.list = append(e.list, eclipse{Time: t, Body: l[1], Kind: l[2], Visible: l[3]})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(e.list, func(i, j int) bool {
+		return e.list[i].Time.Before(e.list[j].Time)
+	})
+
+	return e, nil
+}
+
+// Query returns the next eclipses, optionally only solar or lunar ones,
+// or the eclipses in a year.
+// Format: next, solar, lunar, or $year. eg: next, solar, 2027
+func (e *Eclipses) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	var (
+		now   = time.Now().UTC()
+		match func(ec eclipse) bool
+	)
+	switch q {
+	case "next":
+		match = func(ec eclipse) bool { return ec.Time.After(now) }
+	case "solar", "lunar":
+		match = func(ec eclipse) bool { return ec.Time.After(now) && ec.Body == q }
+	default:
+		y, err := strconv.Atoi(q)
+		if err != nil || len(q) != 4 {
+			return nil, errors.New("invalid eclipses query. eg: next.eclipses, solar.eclipses, lunar.eclipses, 2027.eclipses")
+		}
+		match = func(ec eclipse) bool { return ec.Time.Year() == y }
+	}
+
+	out := make([]string, 0, maxEvents)
+	for _, ec := range e.list {
+		if !match(ec) {
+			continue
+		}
+
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("%s UTC", ec.Time.Format("2006-01-02 15:04")),
+			fmt.Sprintf("%s %s eclipse", ec.Kind, ec.Body), ec.Visible,
+			countdown(ec.Time, now)))
+		if len(out) == maxEvents {
+			break
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("no eclipses found. The table covers 2026-2030.")
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (e *Eclipses) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get upcoming solar and lunar eclipses with where they're visible and a countdown.",
+		Syntax:   "next.eclipses, solar.eclipses, lunar.eclipses, or $year.eclipses",
+		Examples: []string{"dig next.eclipses @%s", "dig solar.eclipses @%s", "dig 2027.eclipses @%s"},
+	}
+}
+
+// NewMeteors returns a new instance of Meteors.
+func NewMeteors() (*Meteors, error) {
+	m := &Meteors{}
+	err := readTSV(meteorsB, 5, func(l []string) error {
+		d, err := time.Parse("01-02", l[1])
+		if err != nil {
+			return err
+		}
+		zhr, err := strconv.Atoi(l[3])
+		if err != nil {
+			return err
+		}
+		m.list = append(m.list, shower{ID: l[0], Month: d.Month(), Day: d.Day(), Name: l[2], ZHR: zhr, Parent: l[4]})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Query returns the next meteor shower peaks or the next peak of a shower.
+// Format: next or $shower. eg: next, perseids
+func (m *Meteors) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+	now := time.Now().UTC()
+
+	type peak struct {
+		s shower
+		t time.Time
+	}
+	peaks := make([]peak, 0, len(m.list))
+	for _, s := range m.list {
+		if q != "next" && s.ID != q {
+			continue
+		}
+		peaks = append(peaks, peak{s, nextPeak(s, now)})
+	}
+
+	if len(peaks) == 0 {
+		names := make([]string, len(m.list))
+		for i, s := range m.list {
+			names[i] = s.ID
+		}
+		return nil, fmt.Errorf("unknown meteor shower. Try next or one of: %s", strings.Join(names, ", "))
+	}
+
+	sort.Slice(peaks, func(i, j int) bool {
+		return peaks[i].t.Before(peaks[j].t)
+	})
+	if len(peaks) > maxEvents {
+		peaks = peaks[:maxEvents]
+	}
+
+	out := make([]string, 0, len(peaks))
+	for _, p := range peaks {
+		out = append(out, txt.Record(q,
+			p.s.Name, fmt.Sprintf("peak %s", p.t.Format("Jan 02 2006")),
+			fmt.Sprintf("up to %d/hour", p.s.ZHR),
+			fmt.Sprintf("from %s", p.s.Parent), countdown(p.t, now)))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (m *Meteors) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the upcoming peaks of major meteor showers with their hourly rates and a countdown.",
+		Syntax:   "next.meteors or $shower.meteors",
+		Examples: []string{"dig next.meteors @%s", "dig perseids.meteors @%s"},
+	}
+}
+
+// nextPeak returns the next peak of a shower. A peak counts as upcoming
+// until the end of its day.
+func nextPeak(s shower, now time.Time) time.Time {
+	t := time.Date(now.Year(), s.Month, s.Day, 0, 0, 0, 0, time.UTC)
+	if now.Sub(t) >= time.Hour*24 {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t
+}
+
+// countdown returns the time left until t as days and hours.
+func countdown(t, now time.Time) string {
+	d := t.Sub(now)
+	if d < 0 {
+		if d > -time.Hour*24 {
+			return "now"
+		}
+		return "past"
+	}
+
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days == 0 {
+		return fmt.Sprintf("in %dh", hours)
+	}
+	return fmt.Sprintf("in %dd %dh", days, hours)
+}
+
+// readTSV calls fn for every line of a tab separated table with n fields,
+// skipping blank lines and # comments.
+func readTSV(b []byte, n int, fn func([]string) error) error {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		l := strings.Split(line, "\t")
+		if len(l) != n {
+			return fmt.Errorf("invalid line in events table: %s", line)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,124 @@
+// Package split divides an amount between parties by weighted shares.
+package split
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const maxParties = 20
+
+// Split splits bills.
+type Split struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "split",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Split.
+func New() *Split {
+	return &Split{}
+}
+
+// Query parses a split query and returns the answer.
+// Format: $amount-$share-$share... eg: 4500-3-2-1
+// A single share count splits the amount equally. eg: 4500-3
+func (s *Split) Query(q string) ([]string, error) {
+	parts := strings.Split(q, "-")
+	if len(parts) < 2 || len(parts) > maxParties+1 {
+		return nil, errors.New("invalid split query. eg: 4500-3 (3 equal parts), 4500-3-2-1 (weighted shares)")
+	}
+
+	amt, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || amt <= 0 || amt > 1e12 {
+		return nil, errors.New("invalid amount.")
+	}
+
+	var shares []float64
+	for _, p := range parts[1:] {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid share '%s'.", p)
+		}
+		shares = append(shares, v)
+	}
+
+	// A single number is the number of equal parts.
+	if len(shares) == 1 {
+		n := shares[0]
+		if n != math.Trunc(n) || n > maxParties {
+			return nil, fmt.Errorf("number of parts should be 1-%d.", maxParties)
+		}
+		shares = make([]float64, int(n))
+		for i := range shares {
+			shares[i] = 1
+		}
+	}
+
+	portions := allocate(int64(math.Round(amt*100)), shares)
+
+	out := make([]string, 0, len(portions))
+	for i, p := range portions {
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("%d: %d.%02d", i+1, p/100, p%100),
+			fmt.Sprintf("%s share(s)", strconv.FormatFloat(shares[i], 'f', -1, 64))))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (s *Split) Help() registry.Help {
+	return registry.Help{
+		Desc:     "split an amount equally or by weighted shares.",
+		Syntax:   "$amount-$parts.split or $amount-$share-$share....split",
+		Examples: []string{"dig 4500-3-2-1.split @%s", "dig 100-3.split @%s"},
+	}
+}
+
+// allocate splits cents by shares using the largest remainder method
+// so that the portions always add up to the exact total.
+func allocate(cents int64, shares []float64) []int64 {
+	var total float64
+	for _, s := range shares {
+		total += s
+	}
+
+	var (
+		out  = make([]int64, len(shares))
+		rems = make([]int, len(shares))
+		frac = make([]float64, len(shares))
+		sum  int64
+	)
+	for i, s := range shares {
+		exact := float64(cents) * s / total
+		out[i] = int64(math.Floor(exact))
+		frac[i] = exact - float64(out[i])
+		rems[i] = i
+		sum += out[i]
+	}
+
+	// Hand out the leftover cents to the largest fractional parts.
+	sort.SliceStable(rems, func(a, b int) bool {
+		return frac[rems[a]] > frac[rems[b]]
+	})
+	for i := 0; sum < cents; i++ {
+		out[rems[i%len(rems)]]++
+		sum++
+	}
+
+	return out
+}
@@ -0,0 +1,407 @@
+// Package sudoku generates graded Sudoku puzzles and solves them.
+package sudoku
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Attempts at generating a puzzle of the requested difficulty.
+const maxAttempts = 30
+
+// Puzzles that need more guesses than this to solve are hard.
+const hardGuesses = 5
+
+// Minimum number of clues left in a puzzle for each difficulty.
+var levels = map[string]int{
+	"easy":   36,
+	"medium": 30,
+	"hard":   24,
+}
+
+type grid [81]int8
+
+// Sudoku is the Sudoku service.
+type Sudoku struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "sudoku",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Sudoku.
+func New() *Sudoku {
+	return &Sudoku{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Query generates a puzzle or solves one. A grid is 81 digits, row by row,
+// with 0 for blanks. Dots and hyphens in it are ignored so that it can be
+// split into labels of up to 63 characters.
+// Format: $difficulty or solve-$grid. eg: easy, solve-530070000.600195000...
+func (s *Sudoku) Query(q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	if _, ok := levels[q]; ok {
+		return s.generate(q), nil
+	}
+
+	if !strings.HasPrefix(q, "solve-") {
+		return nil, errors.New("invalid sudoku query. eg: easy.sudoku, medium.sudoku, hard.sudoku, solve-$row1.$row2...$row9.sudoku")
+	}
+
+	g, err := parse(strings.TrimPrefix(q, "solve-"))
+	if err != nil {
+		return nil, err
+	}
+
+	sol, n, _ := solve(g, 2)
+	switch n {
+	case 0:
+		return nil, errors.New("puzzle has no solution.")
+	case 2:
+		return nil, errors.New("puzzle has more than one solution.")
+	}
+
+	return rows(q, sol), nil
+}
+
+// generate returns a puzzle of the given difficulty as rows followed by
+// a record with its grade and the query to solve it.
+func (s *Sudoku) generate(level string) []string {
+	var (
+		g     grid
+		grade string
+	)
+	for i := 0; i < maxAttempts; i++ {
+		g = s.puzzle(levels[level])
+		if grade = rate(g); grade == level {
+			break
+		}
+	}
+
+	clues := 0
+	for _, v := range g {
+		if v != 0 {
+			clues++
+		}
+	}
+
+	q := level + "."
+	return append(rows(q, g), txt.Record(q,
+		fmt.Sprintf("%s (%d clues)", grade, clues),
+		fmt.Sprintf("solve: dig solve-%s.sudoku", g.labels())))
+}
+
+// puzzle returns a random puzzle with a unique solution and at least
+// minClues clues.
+func (s *Sudoku) puzzle(minClues int) grid {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Fill an empty grid with random digit order for a random solution.
+	var g grid
+	fill(&g, s.rnd)
+
+	// Remove clues in random order as long as the solution stays unique.
+	clues := 81
+	for _, i := range s.rnd.Perm(81) {
+		if clues <= minClues {
+			break
+		}
+
+		v := g[i]
+		g[i] = 0
+		if _, n, _ := solve(g, 2); n != 1 {
+			g[i] = v
+			continue
+		}
+		clues--
+	}
+
+	return g
+}
+
+// rate grades a puzzle by how it can be solved: easy ones with naked and
+// hidden singles alone, medium ones with a few guesses, hard ones with more.
+func rate(g grid) string {
+	if solveSingles(&g) {
+		return "easy"
+	}
+
+	if _, _, guesses := solve(g, 1); guesses > hardGuesses {
+		return "hard"
+	}
+	return "medium"
+}
+
+// solveSingles fills cells that have only one candidate or are the only
+// place for a digit in a unit, until stuck. It returns true if the grid
+// was solved.
+func solveSingles(g *grid) bool {
+	for {
+		progress := false
+		for i := range g {
+			if g[i] != 0 {
+				continue
+			}
+
+			c := candidates(g, i)
+			if bits(c) == 1 {
+				g[i] = digit(c)
+				progress = true
+				continue
+			}
+
+			// Hidden single: a candidate no other cell in a unit can take.
+			for _, unit := range units(i) {
+				rest := uint16(0)
+				for _, j := range unit {
+					if j != i && g[j] == 0 {
+						rest |= candidates(g, j)
+					}
+				}
+				if only := c &^ rest; bits(only) == 1 {
+					g[i] = digit(only)
+					progress = true
+					break
+				}
+			}
+		}
+
+		if !progress {
+			break
+		}
+	}
+
+	for _, v := range g {
+		if v == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// solve solves a grid by backtracking on the cell with the fewest
+// candidates. It stops after finding max solutions and returns the first
+// solution, the number of solutions found, and the number of guesses made.
+func solve(g grid, max int) (grid, int, int) {
+	var (
+		sol     grid
+		n       int
+		guesses int
+	)
+
+	var rec func() bool
+	rec = func() bool {
+		best, bestC := -1, uint16(0)
+		for i := range g {
+			if g[i] != 0 {
+				continue
+			}
+			c := candidates(&g, i)
+			if c == 0 {
+				return false
+			}
+			if best == -1 || bits(c) < bits(bestC) {
+				best, bestC = i, c
+			}
+		}
+
+		if best == -1 {
+			if n == 0 {
+				sol = g
+			}
+			n++
+			return n >= max
+		}
+
+		if bits(bestC) > 1 {
+			guesses++
+		}
+		for d := int8(1); d <= 9; d++ {
+			if bestC&(1<<uint(d)) == 0 {
+				continue
+			}
+			g[best] = d
+			if rec() {
+				return true
+			}
+		}
+		g[best] = 0
+		return false
+	}
+	rec()
+
+	return sol, n, guesses
+}
+
+// fill fills an empty grid with a random valid solution.
+func fill(g *grid, rnd *rand.Rand) bool {
+	i := -1
+	for j, v := range g {
+		if v == 0 {
+			i = j
+			break
+		}
+	}
+	if i == -1 {
+		return true
+	}
+
+	c := candidates(g, i)
+	for _, d := range rnd.Perm(9) {
+		d := int8(d + 1)
+		if c&(1<<uint(d)) == 0 {
+			continue
+		}
+		g[i] = d
+		if fill(g, rnd) {
+			return true
+		}
+	}
+	g[i] = 0
+
+	return false
+}
+
+// candidates returns a bitmask (bits 1-9) of the digits a cell can take.
+func candidates(g *grid, i int) uint16 {
+	used := uint16(0)
+	for _, unit := range units(i) {
+		for _, j := range unit {
+			used |= 1 << uint(g[j])
+		}
+	}
+
+	return 0x3fe &^ used
+}
+
+// units returns the cell indexes of the row, column, and box of a cell.
+func units(i int) [3][9]int {
+	var (
+		u      [3][9]int
+		r, c   = i / 9, i % 9
+		br, bc = r / 3 * 3, c / 3 * 3
+	)
+	for k := 0; k < 9; k++ {
+		u[0][k] = r*9 + k
+		u[1][k] = k*9 + c
+		u[2][k] = (br+k/3)*9 + bc + k%3
+	}
+
+	return u
+}
+
+func bits(c uint16) int {
+	n := 0
+	for ; c != 0; c &= c - 1 {
+		n++
+	}
+	return n
+}
+
+func digit(c uint16) int8 {
+	for d := int8(1); d <= 9; d++ {
+		if c&(1<<uint(d)) != 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parse parses a grid of 81 digits.
+func parse(s string) (grid, error) {
+	var (
+		g grid
+		n int
+	)
+	for _, c := range s {
+		if c == '.' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' || n >= 81 {
+			return g, errors.New("invalid grid. Should be 81 digits with 0 for blanks.")
+		}
+		g[n] = int8(c - '0')
+		n++
+	}
+	if n != 81 {
+		return g, errors.New("invalid grid. Should be 81 digits with 0 for blanks.")
+	}
+
+	// Check for clashing clues.
+	for i, v := range g {
+		if v == 0 {
+			continue
+		}
+		g[i] = 0
+		ok := candidates(&g, i)&(1<<uint(v)) != 0
+		g[i] = v
+		if !ok {
+			return g, errors.New("invalid grid. It has repeated digits.")
+		}
+	}
+
+	return g, nil
+}
+
+// rows returns a grid as 9 numbered TXT records with . for blanks, as
+// resolvers may reorder records.
+func rows(q string, g grid) []string {
+	out := make([]string, 0, 10)
+	for r := 0; r < 9; r++ {
+		var b strings.Builder
+		for c := 0; c < 9; c++ {
+			if c > 0 && c%3 == 0 {
+				b.WriteByte(' ')
+			}
+			if v := g[r*9+c]; v != 0 {
+				b.WriteByte(byte('0' + v))
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		out = append(out, txt.Record(q, fmt.Sprintf("%d", r+1), b.String()))
+	}
+
+	return out
+}
+
+// labels returns a grid as digits in DNS labels of one row each.
+func (g grid) labels() string {
+	var b strings.Builder
+	for i, v := range g {
+		if i > 0 && i%9 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteByte(byte('0' + v))
+	}
+	return b.String()
+}
+
+// Help returns the help text of the service.
+func (s *Sudoku) Help() registry.Help {
+	return registry.Help{
+		Desc:   "get a graded sudoku puzzle (easy, medium, hard) or solve one (9 rows of digits separated by dots, 0 for blanks).",
+		Syntax: "$difficulty.sudoku or solve-$row1.$row2...$row9.sudoku",
+		Examples: []string{"dig easy.sudoku @%s", "dig hard.sudoku @%s",
+			"dig solve-530070000.600195000.098000060.800060003.400803001.700020006.060000280.000419005.000080079.sudoku @%s"},
+	}
+}
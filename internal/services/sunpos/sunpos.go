@@ -0,0 +1,142 @@
+// Package sunpos returns the current position of the sun for a geographic location.
+package sunpos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/astro"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Default height of the object (in metres) for shadow length estimates.
+const defaultHeight = 1.0
+
+var reHeight = regexp.MustCompile(`^(.+)-([0-9\.]+)m$`)
+
+// SunPos computes the position of the sun for cities.
+type SunPos struct {
+	geo *geo.Geo
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "sunpos",
+		New: func(_ *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(env.Geo()), nil
+		},
+	})
+}
+
+// New returns a new instance of SunPos.
+func New(g *geo.Geo) *SunPos {
+	return &SunPos{
+		geo: g,
+	}
+}
+
+// Query parses a given query string and returns the answer.
+// For the sunpos package, the query is a location name with an optional
+// object height in metres for shadow estimates. eg: mumbai, mumbai-2m
+func (s *SunPos) Query(q string) ([]string, error) {
+	var (
+		city   = q
+		height = defaultHeight
+	)
+
+	// Is there an object height?
+	if res := reHeight.FindStringSubmatch(q); len(res) == 3 {
+		h, err := strconv.ParseFloat(res[2], 64)
+		if err != nil || h <= 0 {
+			return nil, errors.New("invalid height.")
+		}
+		city = res[1]
+		height = h
+	}
+
+	var (
+		str     = strings.Split(city, "/")
+		country = ""
+	)
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		city = str[0]
+		country = strings.ToUpper(str[1])
+	}
+	city = strings.ToLower(city)
+
+	var loc *geo.Location
+	for _, l := range s.geo.Query(city) {
+		// Filter by country.
+		if country != "" && l.Country != country {
+			continue
+		}
+		loc = &l
+		break
+	}
+	if loc == nil {
+		return nil, errors.New("unknown city.")
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		zone = time.UTC
+	}
+
+	var (
+		now  = time.Now().In(zone)
+		pos  = astro.SunPosition(now, loc.Lat, loc.Lon)
+		noon = astro.SolarNoon(now, loc.Lon)
+		nPos = astro.SunPosition(noon, loc.Lat, loc.Lon)
+	)
+
+	out := []string{
+		txt.Record(q,
+			fmt.Sprintf("%s (%s)", loc.Name, loc.Country),
+			fmt.Sprintf("elevation %0.2f deg", pos.Elevation),
+			fmt.Sprintf("azimuth %0.2f deg", pos.Azimuth), now.Format("15:04 MST")),
+	}
+
+	// Current shadow length, if the sun is up.
+	if pos.Elevation > 0 {
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("shadow of %0.2fm object now", height),
+			shadow(height, pos.Elevation)))
+	}
+
+	out = append(out, txt.Record(q,
+		fmt.Sprintf("solar noon %s", noon.In(zone).Format("15:04 MST")),
+		fmt.Sprintf("noon elevation %0.2f deg", nPos.Elevation),
+		fmt.Sprintf("noon shadow of %0.2fm object", height),
+		shadow(height, nPos.Elevation)))
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (s *SunPos) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the sun's position and shadow lengths for a city.",
+		Syntax:   "$city[/$country][-$heightm].sunpos",
+		Examples: []string{"dig mumbai.sunpos @%s", "dig sydney-2m.sunpos @%s"},
+	}
+}
+
+// shadow returns the length of the shadow cast by an object of height h
+// when the sun is at the given elevation.
+func shadow(h, elevation float64) string {
+	if elevation <= 0 {
+		return "sun is below the horizon"
+	}
+
+	return fmt.Sprintf("%0.2fm", h/math.Tan(elevation*math.Pi/180))
+}
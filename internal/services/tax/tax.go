@@ -0,0 +1,107 @@
+// Package tax computes GST and generic sales tax on amounts.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var grammar = query.NewGrammar(`(?P<amount>[0-9\.]+)-(?P<rate>[0-9\.]+)pc(-(?P<mode>incl|excl))?`, "invalid tax query. eg: 1000-18pc, 1180-18pc-incl")
+
+// Tax computes tax on amounts.
+type Tax struct {
+	gst bool
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "tax",
+		Suffixes: []string{"gst", "tax"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"gst": NewGST(),
+				"tax": NewTax(),
+			}, nil
+		},
+	})
+}
+
+// NewGST returns a new instance of Tax that splits the tax
+// into Indian CGST and SGST.
+func NewGST() *Tax {
+	return &Tax{gst: true}
+}
+
+// NewTax returns a new instance of a generic sales tax calculator.
+func NewTax() *Tax {
+	return &Tax{}
+}
+
+// Query parses a tax query and returns the answer.
+// Format: $amount-$rate(pc)[-incl|-excl]. eg: 1000-18pc, 1180-18pc-incl
+// Amounts are tax exclusive unless -incl is specified.
+func (t *Tax) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	amt, err := args.Float("amount")
+	if err != nil {
+		return nil, errors.New("invalid amount.")
+	}
+
+	rate, err := args.Float("rate")
+	if err != nil || rate > 100 {
+		return nil, errors.New("invalid tax rate.")
+	}
+
+	// Compute the base amount and the tax.
+	var base, tax float64
+	if args["mode"] == "incl" {
+		base = amt / (1 + rate/100)
+		tax = amt - base
+	} else {
+		base = amt
+		tax = amt * rate / 100
+	}
+
+	out := []string{
+		txt.Record(q,
+			fmt.Sprintf("net %0.2f", base), fmt.Sprintf("tax %0.2f (%s%%)", tax, args["rate"]),
+			fmt.Sprintf("total %0.2f", base+tax)),
+	}
+
+	if t.gst {
+		half := strconv.FormatFloat(rate/2, 'f', -1, 64)
+		out = append(out, txt.Record(q,
+			fmt.Sprintf("CGST %0.2f (%s%%)", tax/2, half),
+			fmt.Sprintf("SGST %0.2f (%s%%)", tax/2, half),
+			fmt.Sprintf("IGST %0.2f (%s%%) for inter-state", tax, args["rate"])))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (t *Tax) Help() registry.Help {
+	if t.gst {
+		return registry.Help{
+			Desc:     "compute GST with the CGST/SGST split (add -incl for tax inclusive amounts).",
+			Syntax:   "$amount-$ratepc[-incl].gst",
+			Examples: []string{"dig 1000-18pc.gst @%s", "dig 1180-18pc-incl.gst @%s"},
+		}
+	}
+
+	return registry.Help{
+		Desc:     "compute sales tax on an amount (add -incl for tax inclusive amounts).",
+		Syntax:   "$amount-$ratepc[-incl].tax",
+		Examples: []string{"dig 1080-8pc-incl.tax @%s", "dig 1000-8pc.tax @%s"},
+	}
+}
@@ -0,0 +1,100 @@
+// Package tempo computes note delay times for a tempo and vice versa.
+package tempo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+var (
+	grammar = query.NewGrammar(`(?P<val>[0-9\.]+)(?P<unit>bpm|ms)`, "invalid delay query. eg: 120bpm, 500ms")
+
+	// Note lengths relative to a quarter note (beat).
+	notes = []struct {
+		name string
+		val  float64
+	}{
+		{"1/1", 4},
+		{"1/2", 2},
+		{"1/4", 1},
+		{"1/8", 0.5},
+		{"1/16", 0.25},
+		{"1/32", 0.125},
+	}
+)
+
+// Tempo computes delay times.
+type Tempo struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "tempo",
+		Suffixes: []string{"delay"},
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of Tempo.
+func New() *Tempo {
+	return &Tempo{}
+}
+
+// Query returns note delay times in ms for a tempo (eg: 120bpm) or the
+// tempo for a quarter note delay time (eg: 500ms).
+func (t *Tempo) Query(q string) ([]string, error) {
+	args, err := grammar.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := args.Float("val")
+	if err != nil || v <= 0 {
+		return nil, errors.New("invalid number.")
+	}
+
+	// Quarter note delay time to BPM.
+	if args["unit"] == "ms" {
+		if v > 60000 {
+			return nil, errors.New("delay time should be <= 60000 ms.")
+		}
+
+		r := txt.Record(q,
+			fmt.Sprintf("%s ms quarter note", args["val"]), fmt.Sprintf("%0.2f bpm", 60000/v))
+		return []string{r}, nil
+	}
+
+	if v > 1000 {
+		return nil, errors.New("tempo should be <= 1000 bpm.")
+	}
+
+	// Delay time of a quarter note.
+	beat := 60000 / v
+
+	out := make([]string, 0, len(notes))
+	for _, n := range notes {
+		ms := beat * n.val
+		out = append(out, txt.Record(q,
+			n.name, fmt.Sprintf("%0.2f ms", ms),
+			fmt.Sprintf("dotted %0.2f ms", ms*1.5),
+			fmt.Sprintf("triplet %0.2f ms", ms*2/3),
+			fmt.Sprintf("%0.2f Hz", 1000/ms)))
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (t *Tempo) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get note delay times for a tempo, or the tempo for a delay.",
+		Syntax:   "$tempo(bpm|ms).delay",
+		Examples: []string{"dig 120bpm.delay @%s", "dig 500ms.delay @%s"},
+	}
+}
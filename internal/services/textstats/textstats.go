@@ -0,0 +1,130 @@
+// Package textstats returns statistics and readability scores for text.
+package textstats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// TextStats computes text statistics.
+type TextStats struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "textstats",
+		Suffixes: []string{"count"},
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of TextStats.
+func New() *TextStats {
+	return &TextStats{}
+}
+
+// Query returns the character, word, and syllable counts of the given
+// text where words are separated by - or . eg: some-text-here
+func (t *TextStats) Query(q string) ([]string, error) {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return r == '-' || r == '.'
+	})
+	if len(words) == 0 {
+		return nil, errors.New("invalid text. eg: some-text-here")
+	}
+
+	var chars, letters, syllables int
+	for _, w := range words {
+		chars += len(w)
+		for _, c := range w {
+			if c >= 'a' && c <= 'z' {
+				letters++
+			}
+		}
+		syllables += countSyllables(w)
+	}
+
+	// Flesch reading ease, assuming the text is a single sentence.
+	var (
+		wpc  = float64(len(words))
+		ease = 206.835 - 1.015*wpc - 84.6*(float64(syllables)/wpc)
+	)
+
+	out := []string{
+		txt.Record(q,
+			fmt.Sprintf("%d chars (%d with spaces)", chars, chars+len(words)-1),
+			fmt.Sprintf("%d letters", letters), fmt.Sprintf("%d words", len(words)),
+			fmt.Sprintf("%d syllables", syllables)),
+		txt.Record(q, fmt.Sprintf("flesch reading ease %0.1f", ease), readability(ease)),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (t *TextStats) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get character, word, and syllable counts and readability of text.",
+		Syntax:   "$text.count",
+		Examples: []string{"dig some-text-here.count @%s", "dig hello-world.count @%s"},
+	}
+}
+
+// countSyllables estimates the number of syllables in an English word
+// by counting vowel groups.
+func countSyllables(w string) int {
+	var (
+		n        = 0
+		hasVowel = false
+		prev     = false
+	)
+
+	for _, c := range w {
+		v := strings.ContainsRune("aeiouy", c)
+		if v && !prev {
+			n++
+		}
+		if v {
+			hasVowel = true
+		}
+		prev = v
+	}
+
+	// Silent e, except for words ending in -le (eg: table).
+	if n > 1 && strings.HasSuffix(w, "e") && !strings.HasSuffix(w, "le") {
+		n--
+	}
+
+	if n == 0 && !hasVowel {
+		// Numbers and abbreviations.
+		return 1
+	}
+
+	return n
+}
+
+// readability returns the grade description of a Flesch reading ease score.
+func readability(s float64) string {
+	switch {
+	case s >= 90:
+		return "very easy"
+	case s >= 80:
+		return "easy"
+	case s >= 70:
+		return "fairly easy"
+	case s >= 60:
+		return "standard"
+	case s >= 50:
+		return "fairly difficult"
+	case s >= 30:
+		return "difficult"
+	}
+
+	return "very difficult"
+}
@@ -0,0 +1,247 @@
+// Package texttransform implements novelty text transformations and encodings.
+package texttransform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// Max length of the text to transform.
+const maxLen = 100
+
+var (
+	leet = map[rune]string{
+		'a': "4", 'b': "8", 'e': "3", 'g': "6", 'i': "1",
+		'l': "1", 'o': "0", 's': "5", 't': "7", 'z': "2",
+	}
+
+	smallCaps = map[rune]string{
+		'a': "ᴀ", 'b': "ʙ", 'c': "ᴄ", 'd': "ᴅ", 'e': "ᴇ", 'f': "ꜰ", 'g': "ɢ",
+		'h': "ʜ", 'i': "ɪ", 'j': "ᴊ", 'k': "ᴋ", 'l': "ʟ", 'm': "ᴍ", 'n': "ɴ",
+		'o': "ᴏ", 'p': "ᴘ", 'q': "ǫ", 'r': "ʀ", 's': "ꜱ", 't': "ᴛ", 'u': "ᴜ",
+		'v': "ᴠ", 'w': "ᴡ", 'x': "x", 'y': "ʏ", 'z': "ᴢ",
+	}
+
+	// Grade 1 braille dot patterns of letters.
+	brailleDots = map[rune]string{
+		'a': "1", 'b': "12", 'c': "14", 'd': "145", 'e': "15", 'f': "124", 'g': "1245",
+		'h': "125", 'i': "24", 'j': "245", 'k': "13", 'l': "123", 'm': "134", 'n': "1345",
+		'o': "135", 'p': "1234", 'q': "12345", 'r': "1235", 's': "234", 't': "2345", 'u': "136",
+		'v': "1236", 'w': "2456", 'x': "1346", 'y': "13456", 'z': "1356",
+	}
+
+	// Flag semaphore arm positions of letters as seen by the observer.
+	semaphore = map[rune][2]string{
+		'a': {"S", "SW"}, 'b': {"S", "W"}, 'c': {"S", "NW"}, 'd': {"S", "N"},
+		'e': {"S", "NE"}, 'f': {"S", "E"}, 'g': {"S", "SE"}, 'h': {"SW", "W"},
+		'i': {"SW", "NW"}, 'j': {"N", "E"}, 'k': {"SW", "N"}, 'l': {"SW", "NE"},
+		'm': {"SW", "E"}, 'n': {"SW", "SE"}, 'o': {"W", "NW"}, 'p': {"W", "N"},
+		'q': {"W", "NE"}, 'r': {"W", "E"}, 's': {"W", "SE"}, 't': {"NW", "N"},
+		'u': {"NW", "NE"}, 'v': {"N", "SE"}, 'w': {"NE", "E"}, 'x': {"NE", "SE"},
+		'y': {"NW", "E"}, 'z': {"SE", "E"},
+	}
+)
+
+// Braille numeral indicator (dots 3456) and blank cell.
+const (
+	brailleNum   = '⠼'
+	brailleBlank = '⠀'
+)
+
+// Transform transforms text.
+type Transform struct {
+	fn   func(words []string) ([]string, error)
+	help registry.Help
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "texttransform",
+		Suffixes: []string{"leet", "smallcaps", "braille", "semaphore"},
+		NewGroup: func(*koanf.Koanf, *registry.Env) (registry.Group, error) {
+			return registry.Services{
+				"leet":      NewLeet(),
+				"smallcaps": NewSmallCaps(),
+				"braille":   NewBraille(),
+				"semaphore": NewSemaphore(),
+			}, nil
+		},
+	})
+}
+
+// NewLeet returns a leetspeak Transform.
+func NewLeet() *Transform {
+	return &Transform{fn: mapChars(leet), help: registry.Help{
+		Desc:     "convert text to leetspeak.",
+		Syntax:   "$text.leet",
+		Examples: []string{"dig hello.leet @%s", "dig hacker-news.leet @%s"},
+	}}
+}
+
+// NewSmallCaps returns a Unicode small capitals Transform.
+func NewSmallCaps() *Transform {
+	return &Transform{fn: mapChars(smallCaps), help: registry.Help{
+		Desc:     "convert text to Unicode small caps.",
+		Syntax:   "$text.smallcaps",
+		Examples: []string{"dig hello.smallcaps @%s", "dig dns-toys.smallcaps @%s"},
+	}}
+}
+
+// NewBraille returns a Unicode grade 1 braille Transform.
+func NewBraille() *Transform {
+	return &Transform{fn: toBraille, help: registry.Help{
+		Desc:     "convert text to Unicode braille.",
+		Syntax:   "$text.braille",
+		Examples: []string{"dig hello.braille @%s", "dig dns.braille @%s"},
+	}}
+}
+
+// NewSemaphore returns a flag semaphore Transform.
+func NewSemaphore() *Transform {
+	return &Transform{fn: toSemaphore, help: registry.Help{
+		Desc:     "convert text to flag semaphore positions.",
+		Syntax:   "$text.semaphore",
+		Examples: []string{"dig hello.semaphore @%s", "dig sos.semaphore @%s"},
+	}}
+}
+
+// Query transforms the given text where words are separated by - or .
+func (t *Transform) Query(q string) ([]string, error) {
+	if len(q) == 0 || len(q) > maxLen {
+		return nil, fmt.Errorf("text should be 1 to %d characters.", maxLen)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return r == '-' || r == '.'
+	})
+	if len(words) == 0 {
+		return nil, errors.New("invalid text.")
+	}
+
+	res, err := t.fn(words)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{txt.Record(q, res...)}, nil
+}
+
+// Help returns the help text of the service.
+func (t *Transform) Help() registry.Help {
+	return t.help
+}
+
+// mapChars returns a transform function that replaces characters using
+// the given map and returns the words as a single string.
+func mapChars(chars map[rune]string) func([]string) ([]string, error) {
+	return func(words []string) ([]string, error) {
+		var b strings.Builder
+		for i, w := range words {
+			if i > 0 {
+				b.WriteRune(' ')
+			}
+
+			for _, c := range w {
+				if s, ok := chars[c]; ok {
+					b.WriteString(s)
+				} else {
+					b.WriteRune(c)
+				}
+			}
+		}
+
+		return []string{b.String()}, nil
+	}
+}
+
+// toBraille converts words to Unicode braille patterns.
+func toBraille(words []string) ([]string, error) {
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			b.WriteRune(brailleBlank)
+		}
+
+		num := false
+		for _, c := range w {
+			// Digits are the letters a-j prefixed by the numeral indicator.
+			if c >= '0' && c <= '9' {
+				if !num {
+					b.WriteRune(brailleNum)
+					num = true
+				}
+
+				l := 'j'
+				if c != '0' {
+					l = 'a' + c - '1'
+				}
+				b.WriteRune(brailleCell(brailleDots[l]))
+				continue
+			}
+			num = false
+
+			dots, ok := brailleDots[c]
+			if !ok {
+				return nil, fmt.Errorf("unsupported character: %c.", c)
+			}
+			b.WriteRune(brailleCell(dots))
+		}
+	}
+
+	return []string{b.String()}, nil
+}
+
+// brailleCell returns the Unicode braille pattern for the given dots.
+// Dots 1-8 map to bits 0-7 over the base U+2800.
+func brailleCell(dots string) rune {
+	r := rune(0x2800)
+	for _, d := range dots {
+		r |= 1 << (d - '1')
+	}
+
+	return r
+}
+
+// toSemaphore converts words to flag semaphore positions of the
+// left and right flags as seen by the observer, one string per letter.
+func toSemaphore(words []string) ([]string, error) {
+	out := []string{}
+	for i, w := range words {
+		if i > 0 {
+			out = append(out, "space")
+		}
+
+		num := false
+		for _, c := range w {
+			if c >= '0' && c <= '9' {
+				// Numbers are preceded by the numeric sign and use a-j.
+				if !num {
+					out = append(out, "numeric N NE")
+					num = true
+				}
+
+				l := 'j'
+				if c != '0' {
+					l = 'a' + c - '1'
+				}
+				p := semaphore[l]
+				out = append(out, fmt.Sprintf("%c %s %s", c, p[0], p[1]))
+				continue
+			}
+			num = false
+
+			p, ok := semaphore[c]
+			if !ok {
+				return nil, fmt.Errorf("unsupported character: %c.", c)
+			}
+			out = append(out, fmt.Sprintf("%c %s %s", c, p[0], p[1]))
+		}
+	}
+
+	return out, nil
+}
@@ -8,26 +8,206 @@ import (
 	"time"
 
 	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/query"
+	"github.com/knadh/dns.toys/internal/record"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
 )
 
+var convGrammar = query.NewGrammar(`(?P<hour>[0-9]{1,2}):(?P<min>[0-9]{2})(?P<ampm>am|pm)?-(?P<from>[\p{L}/]+)-(?P<to>[\p{L}/]+)`,
+	"invalid time conversion. eg: 14:30-london-tokyo, 9:00am-newyork-paris/fr")
+
+// Common short names, old names, and alternate spellings of cities.
+var aliases = map[string]string{
+	"nyc":            "newyork",
+	"ny":             "newyork",
+	"la":             "losangeles",
+	"sf":             "sanfrancisco",
+	"vegas":          "lasvegas",
+	"philly":         "philadelphia",
+	"dc":             "washington",
+	"rio":            "riodejaneiro",
+	"hk":             "hongkong",
+	"kl":             "kualalumpur",
+	"bombay":         "mumbai",
+	"calcutta":       "kolkata",
+	"madras":         "chennai",
+	"bangalore":      "bengaluru",
+	"peking":         "beijing",
+	"canton":         "guangzhou",
+	"saigon":         "hochiminhcity",
+	"rangoon":        "yangon",
+	"kiev":           "kyiv",
+	"constantinople": "istanbul",
+	"mexico":         "mexicocity",
+}
+
 // Timezones controller returns times for various geographic locations.
 type Timezones struct {
 	geo *geo.Geo
+	opt Opt
+}
+
+type location struct {
+	geo.Location
+	zone *time.Location
 }
 
 // Opt contains config options for the Time package.
-type Opt struct{}
+type Opt struct {
+	// Max edits (Levenshtein distance) for fuzzy matching misspelt city
+	// names that aren't found. 0 disables fuzzy matching.
+	FuzzyDistance int
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "timezones",
+		Suffixes: []string{"time"},
+		New: func(ko *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+			return New(Opt{FuzzyDistance: ko.Int("fuzzy_distance")}, env.Geo()), nil
+		},
+	})
+}
 
 // New returns a new instance of Time.
 func New(o Opt, g *geo.Geo) *Timezones {
 	return &Timezones{
 		geo: g,
+		opt: o,
 	}
 }
 
 // Query parses a given query string and returns the answer.
-// For the time package, the query is a location name.
+// For the time package, the query is a location name or a time to convert
+// between two locations. eg: 14:30-london-tokyo
 func (t *Timezones) Query(q string) ([]string, error) {
+	if isConversion(q) {
+		from, to, err := t.convert(q)
+		if err != nil {
+			return nil, err
+		}
+
+		_, fromOff := from.Zone()
+		_, toOff := to.Zone()
+		return []string{
+			txt.Record(q, from.loc.label(), from.Format(timeFormat)),
+			txt.Record(q,
+				to.loc.label(), to.Format(timeFormat),
+				offset(time.Duration(toOff-fromOff)*time.Second)),
+		}, nil
+	}
+
+	locs, err := t.lookup(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(locs))
+	for _, l := range locs {
+		r := txt.Record(q, l.label(), time.Now().In(l.zone).Format(time.RFC1123Z))
+
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (t *Timezones) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get time for a city or convert a time between cities",
+		Syntax:   "$city[/$country].time or $hh:$mm-$city-$city.time",
+		Examples: []string{"dig mumbai.time @%s", "dig paris/fr.time @%s", "dig 14:30-london-tokyo.time @%s"},
+	}
+}
+
+// Fields returns structured results for a location name.
+func (t *Timezones) Fields(q string) ([]record.Record, error) {
+	if isConversion(q) {
+		from, to, err := t.convert(q)
+		if err != nil {
+			return nil, err
+		}
+
+		return []record.Record{from.loc.fields(from.Time), to.loc.fields(to.Time)}, nil
+	}
+
+	locs, err := t.lookup(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]record.Record, 0, len(locs))
+	for _, l := range locs {
+		out = append(out, l.fields(time.Now().In(l.zone)))
+	}
+
+	return out, nil
+}
+
+// localTime is a time at a location.
+type localTime struct {
+	time.Time
+	loc location
+}
+
+const timeFormat = "Mon, 02 Jan 2006 15:04 MST"
+
+// convert parses a conversion query and returns the given time today at the
+// first location and the same instant at the second one.
+func (t *Timezones) convert(q string) (localTime, localTime, error) {
+	args, err := convGrammar.Parse(q)
+	if err != nil {
+		return localTime{}, localTime{}, err
+	}
+
+	var (
+		h, _ = args.Int("hour")
+		m, _ = args.Int("min")
+	)
+	if args["ampm"] != "" {
+		if h < 1 || h > 12 {
+			return localTime{}, localTime{}, errors.New("invalid hour. Should be 1-12 with am/pm.")
+		}
+		h %= 12
+		if args["ampm"] == "pm" {
+			h += 12
+		}
+	}
+	if h > 23 || m > 59 {
+		return localTime{}, localTime{}, errors.New("invalid time. eg: 14:30, 2:30pm")
+	}
+
+	from, err := t.lookupOne(args["from"])
+	if err != nil {
+		return localTime{}, localTime{}, err
+	}
+	to, err := t.lookupOne(args["to"])
+	if err != nil {
+		return localTime{}, localTime{}, err
+	}
+
+	now := time.Now().In(from.zone)
+	ft := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, from.zone)
+
+	return localTime{Time: ft, loc: from}, localTime{Time: ft.In(to.zone), loc: to}, nil
+}
+
+// lookupOne returns the most populous location matching a location name.
+func (t *Timezones) lookupOne(q string) (location, error) {
+	locs, err := t.lookup(q)
+	if err != nil || len(locs) == 0 {
+		return location{}, fmt.Errorf("unknown city: %s.", q)
+	}
+
+	return locs[0], nil
+}
+
+// lookup returns the locations (with a loaded timezone) matching a
+// location name with an optional /2-letter-country-code.
+func (t *Timezones) lookup(q string) ([]location, error) {
 	var (
 		str     = strings.Split(q, "/")
 		country = ""
@@ -40,12 +220,20 @@ func (t *Timezones) Query(q string) ([]string, error) {
 	}
 	q = strings.ToLower(q)
 
+	if a, ok := aliases[q]; ok {
+		q = a
+	}
+
 	locs := t.geo.Query(q)
+	if locs == nil {
+		// Maybe it's misspelt.
+		locs = t.geo.Fuzzy(q, t.opt.FuzzyDistance)
+	}
 	if locs == nil {
 		return nil, errors.New("unknown city.")
 	}
 
-	out := make([]string, 0, len(locs))
+	out := make([]location, 0, len(locs))
 	for _, l := range locs {
 		// Filter by country.
 		if country != "" {
@@ -59,16 +247,45 @@ func (t *Timezones) Query(q string) ([]string, error) {
 			continue
 		}
 
-		r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\"",
-			q, l.Name, l.Timezone, l.Country, time.Now().In(zone).Format(time.RFC1123Z))
-
-		out = append(out, r)
+		out = append(out, location{Location: l, zone: zone})
 	}
 
 	return out, nil
 }
 
-// Dump produces a gob dump of the cached data.
-func (t *Timezones) Dump() ([]byte, error) {
-	return nil, nil
+// label returns the name, timezone, and country of a location.
+func (l location) label() string {
+	return fmt.Sprintf("%s (%s, %s)", l.Name, l.Timezone, l.Country)
+}
+
+// fields returns the structured record of a location at a time.
+func (l location) fields(t time.Time) record.Record {
+	return record.Record{
+		{Key: "city", Val: l.Name},
+		{Key: "country", Val: l.Country},
+		{Key: "timezone", Val: l.Timezone},
+		{Key: "time", Val: t.Format(time.RFC3339)},
+		{Key: "unix", Val: t.Unix()},
+	}
+}
+
+// isConversion returns true if a query is a time to convert (starts with
+// a digit) rather than a location name.
+func isConversion(q string) bool {
+	return q != "" && q[0] >= '0' && q[0] <= '9'
+}
+
+// offset formats a timezone difference. eg: +5h30m, -8h, +0h
+func offset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	s := fmt.Sprintf("%s%dh", sign, int(d.Hours()))
+	if m := int(d.Minutes()) % 60; m != 0 {
+		s += fmt.Sprintf("%dm", m)
+	}
+
+	return s
 }
@@ -0,0 +1,94 @@
+// Package totp generates time-based one-time passwords (RFC 6238) for debugging.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+const (
+	step   = 30
+	digits = 6
+)
+
+// TOTP generates TOTP codes.
+type TOTP struct{}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "totp",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of TOTP.
+func New() *TOTP {
+	return &TOTP{}
+}
+
+// Query returns the current TOTP code for a base32 secret.
+func (t *TOTP) Query(q string) ([]string, error) {
+	secret := strings.TrimRight(strings.ToUpper(q), "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid base32 secret.")
+	}
+
+	var (
+		now     = time.Now().Unix()
+		counter = uint64(now / step)
+		remain  = step - now%step
+	)
+
+	out := []string{
+		txt.Record(q,
+			code(key, counter), fmt.Sprintf("%d seconds remaining", remain),
+			fmt.Sprintf("next %s", code(key, counter+1))),
+		txt.Record(q,
+			"WARNING: secrets sent over DNS are visible to resolvers and networks. Only use test secrets."),
+	}
+
+	return out, nil
+}
+
+// Help returns the help text of the service.
+func (t *TOTP) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get the current TOTP code for a base32 test secret.",
+		Syntax:   "$base32secret.totp",
+		Examples: []string{"dig JBSWY3DPEHPK3PXP.totp @%s", "dig GEZDGNBVGY3TQOJQ.totp @%s"},
+	}
+}
+
+// Sensitive keeps the TOTP secrets in queries out of the logs.
+func (t *TOTP) Sensitive() bool {
+	return true
+}
+
+// code computes the HOTP (RFC 4226) code for the counter.
+func code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	// Dynamic truncation.
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, v%1000000)
+}
@@ -0,0 +1,112 @@
+// Package typewords returns random common words for typing practice.
+package typewords
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/words"
+	"github.com/knadh/koanf"
+)
+
+const (
+	defaultCount = 25
+	maxCount     = 100
+
+	// Number of words in a single TXT string.
+	perString = 10
+)
+
+// TypeWords returns random words.
+type TypeWords struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "typewords",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of TypeWords.
+func New() *TypeWords {
+	return &TypeWords{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Query returns N random words.
+// Format: [$count][-daily]. eg: 25, daily, 50-daily
+// The daily variant returns the same words for everyone on a given (UTC) day.
+func (t *TypeWords) Query(q string) ([]string, error) {
+	var (
+		count = defaultCount
+		daily bool
+	)
+
+	for _, p := range strings.Split(strings.ToLower(q), "-") {
+		if p == "daily" {
+			daily = true
+			continue
+		}
+
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > maxCount {
+			return nil, fmt.Errorf("invalid count. Should be 1-%d. eg: 25, daily, 50-daily", maxCount)
+		}
+		count = n
+	}
+
+	list := words.All()
+	if len(list) == 0 {
+		return nil, errors.New("word list is empty.")
+	}
+
+	out := make([]string, count)
+	if daily {
+		y, m, d := time.Now().UTC().Date()
+		rnd := rand.New(rand.NewSource(int64(y*10000 + int(m)*100 + d)))
+		for i := range out {
+			out[i] = list[rnd.Intn(len(list))]
+		}
+	} else {
+		t.mu.Lock()
+		for i := range out {
+			out[i] = list[t.rnd.Intn(len(list))]
+		}
+		t.mu.Unlock()
+	}
+
+	// Group the words into TXT strings.
+	var chunks []string
+	for i := 0; i < len(out); i += perString {
+		end := i + perString
+		if end > len(out) {
+			end = len(out)
+		}
+		chunks = append(chunks, strings.Join(out[i:end], " "))
+	}
+
+	r := txt.Record(q, chunks...)
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (t *TypeWords) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get N random common words for typing practice (daily for the word set of the day).",
+		Syntax:   "[$count][-daily].typewords",
+		Examples: []string{"dig 25.typewords @%s", "dig 50-daily.typewords @%s"},
+	}
+}
@@ -0,0 +1,138 @@
+// Package unitprice compares the per-unit prices of two quantity/price pairs.
+package unitprice
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
+)
+
+// unit is a quantity unit expressed as a multiple of its dimension's base unit.
+type unit struct {
+	base string
+	mul  float64
+}
+
+var units = map[string]unit{
+	"mg": {"g", 0.001},
+	"g":  {"g", 1},
+	"kg": {"g", 1000},
+	"oz": {"g", 28.349523125},
+	"lb": {"g", 453.59237},
+	"ml": {"ml", 1},
+	"cl": {"ml", 10},
+	"l":  {"ml", 1000},
+	"pc": {"pc", 1},
+	"":   {"pc", 1},
+}
+
+// printed units for the per-unit prices of each dimension.
+var perUnit = map[string]struct {
+	name string
+	mul  float64
+}{
+	"g":  {"kg", 1000},
+	"ml": {"l", 1000},
+	"pc": {"pc", 1},
+}
+
+var reParse = regexp.MustCompile(`^([0-9\.]+)([a-z]*)-([0-9\.]+)-vs-([0-9\.]+)([a-z]*)-([0-9\.]+)$`)
+
+// UnitPrice compares unit prices.
+type UnitPrice struct{}
+
+type item struct {
+	qty   float64
+	unit  unit
+	price float64
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "unitprice",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New(), nil
+		},
+	})
+}
+
+// New returns a new instance of UnitPrice.
+func New() *UnitPrice {
+	return &UnitPrice{}
+}
+
+// Query parses a unit price query and returns the answer.
+// Format: $qty$unit-$price-vs-$qty$unit-$price. eg: 500g-120-vs-1kg-210
+func (u *UnitPrice) Query(q string) ([]string, error) {
+	res := reParse.FindStringSubmatch(strings.ToLower(q))
+	if len(res) != 7 {
+		return nil, errors.New("invalid unitprice query. eg: 500g-120-vs-1kg-210")
+	}
+
+	a, err := parseItem(res[1], res[2], res[3])
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseItem(res[4], res[5], res[6])
+	if err != nil {
+		return nil, err
+	}
+	if a.unit.base != b.unit.base {
+		return nil, errors.New("quantities are not comparable (weight, volume, or pieces).")
+	}
+
+	var (
+		pu = perUnit[a.unit.base]
+		pa = a.price / (a.qty * a.unit.mul) * pu.mul
+		pb = b.price / (b.qty * b.unit.mul) * pu.mul
+	)
+
+	var verdict string
+	switch {
+	case pa < pb:
+		verdict = fmt.Sprintf("first is cheaper by %0.1f%%", (pb-pa)/pb*100)
+	case pb < pa:
+		verdict = fmt.Sprintf("second is cheaper by %0.1f%%", (pa-pb)/pa*100)
+	default:
+		verdict = "both cost the same"
+	}
+
+	r := txt.Record(q,
+		fmt.Sprintf("%s%s = %0.2f/%s", res[1], res[2], pa, pu.name),
+		fmt.Sprintf("%s%s = %0.2f/%s", res[4], res[5], pb, pu.name), verdict)
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (u *UnitPrice) Help() registry.Help {
+	return registry.Help{
+		Desc:     "compare the per-unit prices of two quantity/price pairs.",
+		Syntax:   "$qty$unit-$price-vs-$qty$unit-$price.unitprice",
+		Examples: []string{"dig 500g-120-vs-1kg-210.unitprice @%s", "dig 1.5l-90-vs-500ml-25.unitprice @%s"},
+	}
+}
+
+func parseItem(qty, un, price string) (item, error) {
+	uu, ok := units[un]
+	if !ok {
+		return item{}, fmt.Errorf("unknown unit '%s'. Use mg, g, kg, oz, lb, ml, cl, l, or pc.", un)
+	}
+
+	q, err := strconv.ParseFloat(qty, 64)
+	if err != nil || q <= 0 {
+		return item{}, errors.New("invalid quantity.")
+	}
+
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil || p < 0 {
+		return item{}, errors.New("invalid price.")
+	}
+
+	return item{qty: q, unit: uu, price: p}, nil
+}
@@ -9,6 +9,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/koanf"
 )
 
 type fileData struct {
@@ -22,10 +26,16 @@ type group struct {
 	BaseSymbol string `json:"base_symbol"`
 }
 
+// unit is a unit where a base unit = Value units + Offset. Offset is
+// only non-zero for temperatures. Aliases are lowercase names for
+// mixed-case symbols (eg: gb for GB, mbit for Mb) as DNS names are
+// case-insensitive.
 type unit struct {
-	Symbol string  `json:"symbol"`
-	Name   string  `json:"name"`
-	Value  float64 `json:"value"`
+	Symbol  string   `json:"symbol"`
+	Aliases []string `json:"aliases"`
+	Name    string   `json:"name"`
+	Value   float64  `json:"value"`
+	Offset  float64  `json:"offset"`
 }
 
 // Units does conversions for physical units.
@@ -34,8 +44,12 @@ type Units struct {
 	// category: {from, to: true} .
 	units map[string]map[string]unit
 
-	// symbol -> category map.
-	symbols map[string]group
+	// symbol -> categories map. A symbol can be in more than one
+	// category. eg: oz (mass, volume).
+	symbols map[string][]group
+
+	// alias -> symbol map.
+	aliases map[string]string
 
 	// Units list helptext.
 	help []string
@@ -44,13 +58,24 @@ type Units struct {
 //go:embed units.json
 var dataB []byte
 
-var reParse = regexp.MustCompile(`(?i)([0-9\.]+)([a-z]{1,6})\-([a-z]{1,6})`)
+var reParse = regexp.MustCompile(`(?i)^(-?[0-9\.]+)([a-z][a-z0-9/]{0,5})\-([a-z][a-z0-9/]{0,5})$`)
+
+func init() {
+	registry.Register(registry.Entry{
+		Name:     "units",
+		Suffixes: []string{"unit"},
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
 
 // New returns a new instance of Units.
 func New() (*Units, error) {
 	u := &Units{
 		units:   make(map[string]map[string]unit),
-		symbols: make(map[string]group),
+		symbols: make(map[string][]group),
+		aliases: make(map[string]string),
 	}
 
 	if err := u.load(dataB); err != nil {
@@ -79,63 +104,114 @@ func (u *Units) Query(q string) ([]string, error) {
 	}
 
 	var (
-		fromSym = res[2]
-		toSym   = res[3]
+		fromSym, fromGroups = u.lookup(res[2])
+		toSym, toGroups     = u.lookup(res[3])
 	)
 
-	// Validate unit symbols.
-	g, ok := u.symbols[fromSym]
-	if !ok {
-		// Try lowercase.
-		f := strings.ToLower(fromSym)
-		lg, ok := u.symbols[f]
-		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", fromSym)
-		}
-
-		g = lg
-		fromSym = f
+	if fromGroups == nil {
+		return nil, u.unknown(res[2], toGroups)
+	}
+	if toGroups == nil {
+		return nil, u.unknown(res[3], fromGroups)
 	}
-	from := u.units[g.Name][fromSym]
 
-	// Get the to unit irrespective of the group so that its name
-	// can be used in case of an error.
-	toG, ok := u.symbols[toSym]
-	if !ok {
-		// Try lowercase.
-		f := strings.ToLower(toSym)
-		lg, ok := u.symbols[f]
-		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", toSym)
+	// The from->to conversion is only valid if the to symbol belongs to the same
+	// group as the from symbol. Symbols in more than one group (eg: oz) are
+	// disambiguated by the other one.
+	var groups []group
+	for _, fg := range fromGroups {
+		for _, tg := range toGroups {
+			if fg.Name == tg.Name {
+				groups = append(groups, fg)
+			}
 		}
-
-		toG = lg
-		toSym = f
 	}
-	toReal := u.units[toG.Name][toSym]
 
-	// The from->to conversion is only valid if the to symbol belongs to the same
-	// group as the form symbol.
-	to, ok := u.units[g.Name][toSym]
-	if !ok {
+	switch {
+	case len(groups) > 1:
+		names := make([]string, len(groups))
+		for i, g := range groups {
+			names[i] = g.Name
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("ambiguous units: %s and %s can be %s.", fromSym, toSym, strings.Join(names, " or "))
+
+	case len(groups) == 0:
+		var (
+			from = u.units[fromGroups[0].Name][fromSym]
+			to   = u.units[toGroups[0].Name][toSym]
+		)
+		if len(fromGroups) == 1 {
+			return nil, fmt.Errorf("cannot convert %s (%s) to %s (%s). %s units: %s",
+				fromSym, from.Name, toSym, to.Name, fromGroups[0].Name, u.symbolList(fromGroups[0].Name))
+		}
 		return nil, fmt.Errorf("cannot convert %s (%s) to %s (%s).",
-			fromSym, from.Name, toSym, toReal.Name)
+			fromSym, from.Name, toSym, to.Name)
 	}
 
-	baseRate := u.units[g.Name][g.BaseSymbol].Value
+	var (
+		g    = groups[0]
+		from = u.units[g.Name][fromSym]
+		to   = u.units[g.Name][toSym]
+	)
 
-	// Convert.
-	conv := (baseRate / from.Value) / (baseRate / to.Value) * val
+	// Convert to the base unit and from it.
+	conv := (val-from.Offset)/from.Value*to.Value + to.Offset
 
-	r := fmt.Sprintf("%s 1 TXT \"%0.2f %s (%s) = %0.2f %s (%s)\"",
-		q, val, from.Name, from.Symbol, conv, to.Name, to.Symbol)
+	r := txt.Record(q,
+		fmt.Sprintf("%0.2f %s (%s) = %0.2f %s (%s)", val, from.Name, from.Symbol, conv, to.Name, to.Symbol))
 
 	return []string{r}, nil
 }
 
-// Dump is not implemented in this package.
-func (u *Units) Dump() ([]byte, error) {
-	return nil, nil
+// Help returns the help text of the service.
+func (u *Units) Help() registry.Help {
+	return registry.Help{
+		Desc:     "convert between units.",
+		Syntax:   "$value$from-$to.unit",
+		Examples: []string{"dig 42km-cm.unit @%s", "dig 5kg-lb.unit @%s", "dig 100c-f.unit @%s"},
+	}
+}
+
+// lookup returns the symbol (lowercased if it's only known in lowercase,
+// or the symbol of an alias) and the groups of a unit or nil if it's unknown.
+func (u *Units) lookup(sym string) (string, []group) {
+	if g, ok := u.symbols[sym]; ok {
+		return sym, g
+	}
+
+	// Try lowercase and then the aliases.
+	l := strings.ToLower(sym)
+	if g, ok := u.symbols[l]; ok {
+		return l, g
+	}
+	if s, ok := u.aliases[l]; ok {
+		return s, u.symbols[s]
+	}
+
+	return l, nil
+}
+
+// unknown returns the error for an unknown unit. If the other unit in the
+// query is known, the valid units of its group are listed.
+func (u *Units) unknown(sym string, other []group) error {
+	if len(other) == 1 {
+		return fmt.Errorf("unknown unit: %v. %s units: %s", sym, other[0].Name, u.symbolList(other[0].Name))
+	}
+
+	return fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", sym)
+}
+
+// symbolList returns the sorted, comma separated symbols of a group's units
+// with their aliases.
+func (u *Units) symbolList(group string) string {
+	out := make([]string, 0, len(u.units[group]))
+	for sym, un := range u.units[group] {
+		out = append(out, un.label(sym))
+	}
+	sort.Strings(out)
+
+	return strings.Join(out, ", ")
 }
 
 func (u *Units) printUnitsList() []string {
@@ -167,7 +243,7 @@ func (u *Units) printUnitsList() []string {
 		})
 
 		for _, un := range list {
-			l := fmt.Sprintf("unit. 1 TXT \"%s\" \"%s (%s)\"", g, un.Symbol, un.Name)
+			l := txt.Record("unit.", g, fmt.Sprintf("%s (%s)", un.label(un.Symbol), un.Name))
 			out = append(out, l)
 		}
 	}
@@ -186,13 +262,26 @@ func (u *Units) load(b []byte) error {
 	for groupName, g := range data {
 		u.units[groupName] = map[string]unit{}
 		for _, un := range g.Units {
-			u.symbols[un.Symbol] = group{
+			u.symbols[un.Symbol] = append(u.symbols[un.Symbol], group{
 				Name:       groupName,
 				BaseSymbol: g.BaseSymbol,
-			}
+			})
 			u.units[groupName][un.Symbol] = un
+
+			for _, a := range un.Aliases {
+				u.aliases[a] = un.Symbol
+			}
 		}
 	}
 
 	return nil
 }
+
+// label returns a unit's symbol with its aliases. eg: GB/gb
+func (un unit) label(sym string) string {
+	if len(un.Aliases) == 0 {
+		return sym
+	}
+
+	return sym + "/" + strings.Join(un.Aliases, "/")
+}
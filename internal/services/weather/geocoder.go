@@ -0,0 +1,189 @@
+package weather
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/upstream"
+)
+
+const defaultGeocodeURL = "https://geocoding-api.open-meteo.com/v1/search?name=%s&count=1&format=json"
+
+// GeocoderOpt contains config options for the Geocoder.
+type GeocoderOpt struct {
+	// APIURL is the geocoding API endpoint with a %s placeholder for the
+	// city name. RateLimit is the max requests/sec to the API.
+	APIURL     string
+	RateLimit  float64
+	ReqTimeout time.Duration
+	UserAgent  string
+
+	// Locations rarely change and are cached for long.
+	CacheTTL  time.Duration
+	FetchWait time.Duration
+
+	Transport http.RoundTripper
+
+	// Store, if set, persists the locations to disk with MemEntries of
+	// them held in memory.
+	Store      upstream.Store
+	MemEntries int
+}
+
+// Geocoder looks up the locations of cities that aren't in the geo
+// locations database from a geocoding API and caches them.
+type Geocoder struct {
+	up     *upstream.Fetcher
+	opt    GeocoderOpt
+	client *http.Client
+}
+
+// geocoded is a cached geocoding lookup.
+type geocoded struct {
+	Loc geo.Location
+
+	// The API doesn't know the city.
+	NotFound bool
+}
+
+type geocodeReq struct {
+	City    string
+	Country string
+}
+
+type geocodeData struct {
+	Results []struct {
+		ID          int64   `json:"id"`
+		Name        string  `json:"name"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		CountryCode string  `json:"country_code"`
+		Timezone    string  `json:"timezone"`
+		Population  int     `json:"population"`
+	} `json:"results"`
+}
+
+func init() {
+	// Register the cached value type for snapshots.
+	gob.Register(geocoded{})
+}
+
+// NewGeocoder returns a new instance of Geocoder.
+func NewGeocoder(o GeocoderOpt) *Geocoder {
+	if o.APIURL == "" {
+		o.APIURL = defaultGeocodeURL
+	}
+
+	g := &Geocoder{
+		opt: o,
+		client: &http.Client{
+			Timeout:   o.ReqTimeout,
+			Transport: o.Transport,
+		},
+	}
+
+	g.up = upstream.New(upstream.Opt{
+		Name:      "geocoding",
+		TTL:       o.CacheTTL,
+		RateLimit: o.RateLimit,
+		Retries:   1,
+		RetryWait: time.Second,
+		Wait:      o.FetchWait,
+
+		Store:      o.Store,
+		MemEntries: o.MemEntries,
+	}, func(req interface{}) (interface{}, error) {
+		return g.fetch(req.(geocodeReq))
+	})
+
+	return g
+}
+
+// Lookup returns the location of a city, optionally in a country (2 letter
+// code). It returns upstream.ErrQueued if the city is being looked up and
+// false if it isn't found.
+func (g *Geocoder) Lookup(ctx context.Context, city, country string) (geo.Location, bool, error) {
+	v, _, err := g.up.GetContext(ctx, country+"/"+city, geocodeReq{City: city, Country: country})
+	if err != nil {
+		return geo.Location{}, false, err
+	}
+
+	r := v.(geocoded)
+	return r.Loc, !r.NotFound, nil
+}
+
+// CacheStats returns the statistics of the locations cache.
+func (g *Geocoder) CacheStats() cache.Stats {
+	return g.up.CacheStats()
+}
+
+// UpstreamStats returns the counts of fetches to the geocoding API.
+func (g *Geocoder) UpstreamStats() upstream.Stats {
+	return g.up.UpstreamStats()
+}
+
+// Dump produces a gob dump of the cached locations.
+func (g *Geocoder) Dump() ([]byte, error) {
+	return g.up.Dump()
+}
+
+// Load loads a gob dump of cached locations.
+func (g *Geocoder) Load(b []byte) error {
+	return g.up.Load(b)
+}
+
+// fetch looks up a city in the geocoding API.
+func (g *Geocoder) fetch(r geocodeReq) (geocoded, error) {
+	u := fmt.Sprintf(g.opt.APIURL, neturl.QueryEscape(r.City))
+	if r.Country != "" {
+		u += "&countryCode=" + neturl.QueryEscape(r.Country)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return geocoded{}, err
+	}
+	req.Header.Add("User-Agent", g.opt.UserAgent)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return geocoded{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return geocoded{}, fmt.Errorf("error fetching geocoding data: %w", upstream.ErrRateLimited)
+	default:
+		return geocoded{}, fmt.Errorf("error fetching geocoding data: %d", resp.StatusCode)
+	}
+
+	var data geocodeData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return geocoded{}, err
+	}
+	if len(data.Results) == 0 {
+		return geocoded{NotFound: true}, nil
+	}
+
+	res := data.Results[0]
+	return geocoded{Loc: geo.Location{
+		// Prefixed so as to not collide with the IDs in the database.
+		ID:         "gc" + strconv.FormatInt(res.ID, 10),
+		Name:       res.Name,
+		Lat:        res.Latitude,
+		Lon:        res.Longitude,
+		Timezone:   res.Timezone,
+		Country:    res.CountryCode,
+		Population: res.Population,
+	}}, nil
+}
@@ -1,38 +1,48 @@
 package weather
 
 import (
-	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errs"
 	"github.com/knadh/dns.toys/internal/geo"
-	"golang.org/x/time/rate"
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/upstream"
+	"github.com/knadh/koanf"
 )
 
 const (
-	apiURL = "https://api.met.no/weatherapi/locationforecast/2.0/compact?lat=%0.5f&lon=%0.5f"
+	defaultAPIURL       = "https://api.met.no/weatherapi/locationforecast/2.0/compact?lat=%0.5f&lon=%0.5f"
+	defaultOpenMeteoURL = "https://api.open-meteo.com/v1/forecast?latitude=%0.5f&longitude=%0.5f&hourly=temperature_2m,relative_humidity_2m,weather_code&timezone=UTC&forecast_days=3"
+
+	providerMetNo     = "metno"
+	providerOpenMeteo = "openmeteo"
 
 	// Max requests/sec allowed by the API.
-	apiRateLimit = 15
+	defaultRateLimit = 15
 )
 
 type entry struct {
 	Forecasts []forecast
+	Days      []day
 	Location  string
 	Timezone  string
 	Lat, Lon  float32
-	ExpiresAt time.Time
-	Valid     bool
+
+	// Provider that answered.
+	Provider string
 }
 
 type forecast struct {
@@ -44,6 +54,13 @@ type forecast struct {
 	Forecast1H string
 }
 
+// day is the forecast summary of a day in the location's timezone.
+type day struct {
+	Date       time.Time
+	MinC, MaxC float32
+	Symbol     string
+}
+
 type apiData struct {
 	Properties struct {
 		Meta struct {
@@ -79,58 +96,253 @@ type apiData struct {
 	} `json:"properties"`
 }
 
+type openMeteoData struct {
+	Hourly struct {
+		Time        []string  `json:"time"`
+		Temperature []float32 `json:"temperature_2m"`
+		Humidity    []float32 `json:"relative_humidity_2m"`
+		WeatherCode []int     `json:"weather_code"`
+	} `json:"hourly"`
+}
+
 // Opt contains config options for Weather.
 type Opt struct {
 	ForecastInterval time.Duration
 	MaxEntries       int
 
+	// Number of days (from today) to summarize. 0 disables it.
+	ForecastDays int
+
 	CacheTTL   time.Duration
 	ReqTimeout time.Duration
 	UserAgent  string
+
+	// Transport, if set, is used for API requests instead of the network.
+	// eg: fixtures in offline mode.
+	Transport http.RoundTripper
+
+	// APIURL is the forecast API endpoint with %f placeholders for
+	// the lat and lon. RateLimit is the max requests/sec to the API.
+	APIURL    string
+	RateLimit int
+
+	// Providers is the ordered list of forecast APIs (metno, openmeteo)
+	// that are failed over to when one errors or is rate limited.
+	// OpenMeteoURL is the open-meteo endpoint with %f placeholders.
+	Providers    []string
+	OpenMeteoURL string
+
+	// How long past CacheTTL forecasts are served (marked stale) while
+	// they are refreshed, and if the refresh fails.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+
+	// Max time a query for an uncached location waits for the forecast
+	// to be fetched before responding with a "being fetched" message.
+	FetchWait time.Duration
+
+	// Consecutive API failures after which fetches are paused for
+	// BreakerCooldown. 0 disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Max API requests per UTC hour and day. 0 is unlimited.
+	HourlyQuota int
+	DailyQuota  int
+
+	// Store, if set, persists forecasts to disk with MemEntries of them
+	// held in memory.
+	Store      upstream.Store
+	MemEntries int
+
+	// Geocoder, if set, looks up cities that aren't in the geo database.
+	Geocoder *Geocoder
 }
 
 // Weather fetches weather forecasts for a given geo location.
 type Weather struct {
-	data map[string]entry
-
-	// Queue for defering API fetch requests.
-	fetchQueue chan geo.Location
-
-	limiter *rate.Limiter
-	mut     sync.RWMutex
+	up    *upstream.Fetcher
+	chain *upstream.Chain
 
 	opt    Opt
 	geo    *geo.Geo
 	client *http.Client
 }
 
-var errQueued = errors.New("data is queued.")
+func init() {
+	// Register the cached value type for snapshots.
+	gob.Register(entry{})
+
+	registry.Register(registry.Entry{
+		Name: "weather",
+		New:  newFromConfig,
+	})
+}
+
+// newFromConfig returns a new instance of Weather with its config section,
+// and with a Geocoder if weather.geocoding is enabled.
+func newFromConfig(ko *koanf.Koanf, env *registry.Env) (registry.Service, error) {
+	ua := ko.String("useragent")
+	if ua == "" {
+		ua = env.Domain
+	}
+
+	reqTimeout := ko.Duration("req_timeout")
+	if reqTimeout == 0 {
+		reqTimeout = time.Second * 3
+	}
+
+	// Look up cities that aren't in the geo locations in an API?
+	var gc *Geocoder
+	if ko.Bool("geocoding.enabled") {
+		gc = NewGeocoder(GeocoderOpt{
+			APIURL:     ko.String("geocoding.api_url"),
+			RateLimit:  ko.Float64("geocoding.rate_limit"),
+			ReqTimeout: reqTimeout,
+			UserAgent:  ua,
+			CacheTTL:   ko.MustDuration("geocoding.cache_ttl"),
+			FetchWait:  ko.Duration("fetch_wait"),
+			Transport:  env.Transport,
+
+			Store:      env.Store("weather.geocoding"),
+			MemEntries: ko.Int("geocoding.cache_mem_entries"),
+		})
+
+		if b := env.Snapshot("weather.geocoding", gc); b != nil {
+			if err := gc.Load(b); err != nil {
+				slog.Error("error reading weather geocoding snapshot", "error", err)
+			}
+		}
+		env.AddCache("geocoding", gc)
+	}
+
+	return New(Opt{
+		MaxEntries:       ko.MustInt("max_entries"),
+		ForecastInterval: ko.MustDuration("forecast_interval"),
+		ForecastDays:     ko.Int("forecast_days"),
+		CacheTTL:         ko.MustDuration("cache_ttl"),
+		ReqTimeout:       reqTimeout,
+		UserAgent:        ua,
+		Transport:        env.Transport,
+		APIURL:           ko.String("api_url"),
+		RateLimit:        ko.Int("rate_limit"),
+		Providers:        ko.Strings("providers"),
+		OpenMeteoURL:     ko.String("openmeteo_url"),
+		FetchWait:        ko.Duration("fetch_wait"),
+
+		StaleWhileRevalidate: ko.Duration("stale_while_revalidate"),
+		StaleIfError:         ko.Duration("stale_if_error"),
+		BreakerThreshold:     ko.Int("breaker_threshold"),
+		BreakerCooldown:      ko.Duration("breaker_cooldown"),
+		HourlyQuota:          ko.Int("hourly_quota"),
+		DailyQuota:           ko.Int("daily_quota"),
+
+		Store:      env.Store("weather"),
+		MemEntries: ko.Int("cache_mem_entries"),
+		Geocoder:   gc,
+	}, env.Geo()), nil
+}
 
 func New(o Opt, g *geo.Geo) *Weather {
-	w := &Weather{
-		data:       make(map[string]entry),
-		fetchQueue: make(chan geo.Location, 1000),
+	if o.APIURL == "" {
+		o.APIURL = defaultAPIURL
+	}
+	if o.RateLimit < 1 {
+		o.RateLimit = defaultRateLimit
+	}
+	if o.OpenMeteoURL == "" {
+		o.OpenMeteoURL = defaultOpenMeteoURL
+	}
+	if len(o.Providers) == 0 {
+		o.Providers = []string{providerMetNo}
+	}
 
-		// yr.no API request rate limit.
-		limiter: rate.NewLimiter(apiRateLimit, 1),
-		opt:     o,
-		geo:     g,
+	w := &Weather{
+		opt: o,
+		geo: g,
 		client: &http.Client{
 			Timeout: o.ReqTimeout,
 			Transport: &http.Transport{
-				MaxIdleConnsPerHost:   apiRateLimit,
+				MaxIdleConnsPerHost:   o.RateLimit,
 				ResponseHeaderTimeout: o.ReqTimeout,
 			},
 		},
 	}
+	if o.Transport != nil {
+		w.client.Transport = o.Transport
+	}
 
-	go w.runFetchQueue()
+	// Forecast API providers in the order of preference.
+	var provs []upstream.Provider
+	for _, p := range o.Providers {
+		fn := w.fetchMetNo
+		switch p {
+		case providerMetNo:
+		case providerOpenMeteo:
+			fn = w.fetchOpenMeteo
+		default:
+			slog.Warn("unknown weather provider", "provider", p)
+			continue
+		}
+
+		provs = append(provs, upstream.Provider{Name: p, Fetch: func(req interface{}) (interface{}, error) {
+			return fn(req.(geo.Location))
+		}})
+	}
+	w.chain = upstream.NewChain("weather", 0, provs...)
+
+	w.up = upstream.New(upstream.Opt{
+		Name: "weather",
+		TTL:  o.CacheTTL,
+
+		// yr.no API request rate limit.
+		RateLimit: float64(o.RateLimit),
+		Retries:   1,
+		RetryWait: time.Second,
+		Wait:      o.FetchWait,
+
+		StaleWhileRevalidate: o.StaleWhileRevalidate,
+		StaleIfError:         o.StaleIfError,
+
+		BreakerThreshold: o.BreakerThreshold,
+		BreakerCooldown:  o.BreakerCooldown,
+		HourlyQuota:      o.HourlyQuota,
+		DailyQuota:       o.DailyQuota,
+
+		Store:      o.Store,
+		MemEntries: o.MemEntries,
+	}, func(req interface{}) (interface{}, error) {
+		v, prov, err := w.chain.Fetch(req)
+		if err != nil {
+			return nil, err
+		}
+
+		e := v.(entry)
+		e.Provider = prov
+		return e, nil
+	})
 
 	return w
 }
 
 // Query queries the weather for a given location.
 func (w *Weather) Query(q string) ([]string, error) {
+	return w.QueryContext(context.Background(), q)
+}
+
+// Help returns the help text of the service.
+func (w *Weather) Help() registry.Help {
+	return registry.Help{
+		Desc:     "get weather forecast for a city.",
+		Syntax:   "$city[/$country].weather",
+		Examples: []string{"dig berlin.weather @%s", "dig paris/fr.weather @%s"},
+		Upstream: true,
+	}
+}
+
+// QueryContext queries the weather for a given location. Uncached locations
+// are not waited for once ctx is done.
+func (w *Weather) QueryContext(ctx context.Context, q string) ([]string, error) {
 	var (
 		str     = strings.Split(q, "/")
 		country = ""
@@ -145,7 +357,22 @@ func (w *Weather) Query(q string) ([]string, error) {
 
 	locs := w.geo.Query(q)
 	if locs == nil {
-		return nil, errors.New("unknown city.")
+		if w.opt.Geocoder == nil {
+			return nil, errors.New("unknown city.")
+		}
+
+		l, ok, err := w.opt.Geocoder.Lookup(ctx, q, country)
+		if err != nil {
+			if err == upstream.ErrQueued {
+				return []string{txt.Record(q,
+					"city is being looked up. Try again in a few seconds.")}, nil
+			}
+			return nil, errs.Wrap(errs.Upstream, "city lookup is unavailable. Try again in a few seconds.", err)
+		}
+		if !ok {
+			return nil, errors.New("unknown city.")
+		}
+		locs = []geo.Location{l}
 	}
 
 	out := make([]string, 0, len(locs)*3)
@@ -157,17 +384,32 @@ func (w *Weather) Query(q string) ([]string, error) {
 			}
 		}
 
-		data, err := w.get(l)
+		v, stale, err := w.up.GetContext(ctx, l.ID, l)
 		if err != nil {
 			// Data never existed and has been queued. Show a friendly
-			// message instead of an error.
-			if err == errQueued {
-				r := fmt.Sprintf("%s 1 TXT \"weather data is being fetched. Try again in a few seconds.\"", q)
+			// message instead of an error, or the locations that have
+			// been answered so far.
+			if err == upstream.ErrQueued {
+				if len(out) > 0 {
+					return out, nil
+				}
+
+				r := txt.Record(q, "weather data is being fetched. Try again in a few seconds.")
 				return []string{r}, nil
 			}
 
-			return nil, err
+			if err == upstream.ErrDown {
+				return nil, errs.Wrap(errs.Upstream, "weather service is temporarily unavailable. Try again later.", err)
+			}
+
+			// The API quota is exhausted. Report when it resets.
+			if qe, ok := err.(*upstream.QuotaError); ok {
+				return nil, errs.Wrap(errs.RateLimited, "", qe)
+			}
+
+			return nil, errs.Wrap(errs.Upstream, "weather data is unavailable. Try again in a few seconds.", err)
 		}
+		data := v.(entry)
 
 		zone, err := time.LoadLocation(l.Timezone)
 		if err != nil {
@@ -175,8 +417,33 @@ func (w *Weather) Query(q string) ([]string, error) {
 		}
 
 		for _, f := range data.Forecasts {
-			r := fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"%0.2fC (%0.2fF)\" \"%0.2f%% hu.\" \"%s\" \"%s\"",
-				q, l.Name, l.Country, f.TempC, f.TempF, f.Humidity, f.Forecast1H, f.Time.In(zone).Format("15:04, Mon"))
+			r := txt.Record(q,
+				fmt.Sprintf("%s (%s)", l.Name, l.Country),
+				fmt.Sprintf("%0.2fC (%0.2fF)", f.TempC, f.TempF),
+				fmt.Sprintf("%0.2f%% hu.", f.Humidity), f.Forecast1H,
+				f.Time.In(zone).Format("15:04, Mon"))
+
+			// Mark forecasts that are being served past their cache TTL.
+			if stale {
+				r = txt.Append(r, "stale")
+			}
+
+			// Mark forecasts from a fallback provider.
+			if data.Provider != "" && data.Provider != w.opt.Providers[0] {
+				r = txt.Append(r, "via "+data.Provider)
+			}
+			out = append(out, r)
+		}
+
+		// Daily summaries.
+		for _, d := range data.Days {
+			r := txt.Record(q,
+				fmt.Sprintf("%s (%s)", l.Name, l.Country), d.Date.Format("Mon, 02 Jan"),
+				fmt.Sprintf("min %0.1fC (%0.1fF)", d.MinC, d.MinC*1.8+32),
+				fmt.Sprintf("max %0.1fC (%0.1fF)", d.MaxC, d.MaxC*1.8+32), d.Symbol)
+			if stale {
+				r = txt.Append(r, "stale")
+			}
 			out = append(out, r)
 		}
 
@@ -188,93 +455,34 @@ func (w *Weather) Query(q string) ([]string, error) {
 	return out, nil
 }
 
-// Dump produces a gob dump of the cached data.
-func (w *Weather) Dump() ([]byte, error) {
-	buf := &bytes.Buffer{}
-
-	w.mut.RLock()
-	defer w.mut.RUnlock()
-	if err := gob.NewEncoder(buf).Encode(w.data); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+// CacheStats returns the statistics of the forecast cache.
+func (w *Weather) CacheStats() cache.Stats {
+	return w.up.CacheStats()
 }
 
-// Load loads a gob dump of cached data.
-func (w *Weather) Load(b []byte) error {
-	buf := bytes.NewBuffer(b)
-
-	w.mut.RLock()
-	defer w.mut.RUnlock()
-
-	err := gob.NewDecoder(buf).Decode(&w.data)
-	return err
+// UpstreamStats returns the counts of fetches to the weather providers
+// including the ones dropped by the rate limit or quota.
+func (w *Weather) UpstreamStats() upstream.Stats {
+	st := w.chain.UpstreamStats()
+	st.Dropped = w.up.UpstreamStats().Dropped
+	return st
 }
 
-func (w *Weather) runFetchQueue() {
-	for {
-		select {
-		case l := <-w.fetchQueue:
-			if !w.limiter.Allow() {
-				log.Println("weather API rate limit exceeded")
-				continue
-			}
-
-			res, err := w.fetchAPI(l.Lat, l.Lon)
-
-			// Even if it's an error, cache to avoid flooding the service.
-			w.mut.Lock()
-			w.data[l.ID] = res
-			w.mut.Unlock()
-
-			if err != nil {
-				log.Printf("error fetching weather API: %v", err)
-				continue
-			}
-		}
-	}
+// Dump produces a gob dump of the cached data.
+func (w *Weather) Dump() ([]byte, error) {
+	return w.up.Dump()
 }
 
-func (w *Weather) get(l geo.Location) (entry, error) {
-	w.mut.RLock()
-	data, ok := w.data[l.ID]
-	w.mut.RUnlock()
-
-	if !ok || data.ExpiresAt.Before(time.Now()) {
-		// If data is cached but has expired, return the existing data
-		// to respond instantly but queue re-fetch in the background to
-		// update it for the next request.
-		select {
-		case w.fetchQueue <- l:
-		default:
-		}
-
-		// Set the expiry date to the future to not send further
-		// requests for the same location until the fetch queue is processed.
-		data.ExpiresAt = time.Now().Add(time.Minute)
-		w.mut.Lock()
-		w.data[l.ID] = data
-		w.mut.Unlock()
-	}
-
-	if !ok {
-		return entry{}, errQueued
-	}
-
-	if !data.Valid {
-		return entry{}, errors.New("weather data is unavailable. Try again in a few seconds.")
-	}
-
-	return data, nil
+// Load loads a gob dump of cached data.
+func (w *Weather) Load(b []byte) error {
+	return w.up.Load(b)
 }
 
-func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
-	// If the request fails, still cache the bad result with a TTL to avoid
-	// flooding the upstream with subsequent requests.
-	bad := entry{Valid: false, ExpiresAt: time.Now().Add(time.Minute * 10)}
+// fetchMetNo fetches forecasts from the met.no (yr.no) API.
+func (w *Weather) fetchMetNo(l geo.Location) (entry, error) {
+	var bad entry
 
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(apiURL, lat, lon), nil)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(w.opt.APIURL, l.Lat, l.Lon), nil)
 	if err != nil {
 		return bad, err
 	}
@@ -317,10 +525,8 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 		body = b
 	}
 
-	// Even if the request failed, still cache the invalid request with a TTL
-	// so as to not bombard the API.
 	if r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusTooManyRequests {
-		return bad, errors.New("error fetching weather data.")
+		return bad, fmt.Errorf("error fetching weather data: %w", upstream.ErrRateLimited)
 	}
 
 	var data apiData
@@ -334,40 +540,211 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 	// 	exp = time.Now().Add(time.Hour * 1)
 	// }
 
-	out := entry{
-		ExpiresAt: time.Now().Add(w.opt.CacheTTL),
-		Valid:     true,
-	}
-
-	now := time.Now()
+	all := make([]forecast, 0, len(data.Properties.Timeseries))
 	for _, p := range data.Properties.Timeseries {
-		// Skip stale entries.
-		if p.Time.Before(now) {
-			continue
+		// Further out, the series is 6 hourly.
+		sym := p.Data.Next1Hours.Summary.SymbolCode
+		if sym == "" {
+			sym = p.Data.Next6Hours.Summary.SymbolCode
 		}
 
-		f := forecast{
+		all = append(all, forecast{
 			Time:       p.Time,
 			TempC:      p.Data.Instant.Details.AirTemperature,
 			TempF:      (p.Data.Instant.Details.AirTemperature * 1.8) + 32.0,
-			Forecast1H: p.Data.Next1Hours.Summary.SymbolCode,
+			Forecast1H: sym,
 			Humidity:   p.Data.Instant.Details.RelativeHumidity,
+		})
+	}
+
+	return w.newEntry(all, l), nil
+}
+
+// fetchOpenMeteo fetches forecasts from the open-meteo.com API.
+func (w *Weather) fetchOpenMeteo(l geo.Location) (entry, error) {
+	var bad entry
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(w.opt.OpenMeteoURL, l.Lat, l.Lon), nil)
+	if err != nil {
+		return bad, err
+	}
+	req.Header.Add("User-Agent", w.opt.UserAgent)
+
+	r, err := w.client.Do(req)
+	if err != nil {
+		return bad, err
+	}
+	defer func() {
+		// Drain and close the body to let the Transport reuse the connection
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	if r.StatusCode == http.StatusTooManyRequests {
+		return bad, fmt.Errorf("error fetching weather data: %w", upstream.ErrRateLimited)
+	}
+	if r.StatusCode != http.StatusOK {
+		return bad, fmt.Errorf("error fetching weather data: %d", r.StatusCode)
+	}
+
+	var data openMeteoData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		return bad, err
+	}
+
+	h := data.Hourly
+	if len(h.Temperature) != len(h.Time) || len(h.Humidity) != len(h.Time) || len(h.WeatherCode) != len(h.Time) {
+		return bad, errors.New("invalid weather data.")
+	}
+
+	all := make([]forecast, 0, len(h.Time))
+	for i, ts := range h.Time {
+		t, err := time.Parse("2006-01-02T15:04", ts)
+		if err != nil {
+			return bad, err
+		}
+
+		all = append(all, forecast{
+			Time:       t,
+			TempC:      h.Temperature[i],
+			TempF:      (h.Temperature[i] * 1.8) + 32.0,
+			Forecast1H: wmoSymbol(h.WeatherCode[i]),
+			Humidity:   h.Humidity[i],
+		})
+	}
+
+	return w.newEntry(all, l), nil
+}
+
+// newEntry returns the entry for a location's time series of forecasts with
+// the upcoming ones picked at ForecastInterval and the daily summaries.
+func (w *Weather) newEntry(all []forecast, l geo.Location) entry {
+	var (
+		out entry
+		now = time.Now()
+	)
+	for _, f := range all {
+		// Skip stale entries.
+		if f.Time.Before(now) {
+			continue
 		}
 
-		// Only pick up entries with with a certain gap.
-		if len(out.Forecasts) > 0 {
-			if out.Forecasts[len(out.Forecasts)-1].Time.Add(w.opt.ForecastInterval).After(p.Time) {
+		if !w.add(&out, f) {
+			break
+		}
+	}
+
+	zone, err := time.LoadLocation(l.Timezone)
+	if err != nil {
+		zone = time.UTC
+	}
+	out.Days = summarize(all, now.In(zone), w.opt.ForecastDays)
+
+	return out
+}
+
+// summarize returns the min and max temperatures and the most frequent
+// daytime weather of n days from the day of now (in its location) in a
+// series of forecasts. Days without forecasts are skipped.
+func summarize(all []forecast, now time.Time, n int) []day {
+	var (
+		zone  = now.Location()
+		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, zone)
+		out   = make([]day, 0, n)
+	)
+	for i := 0; i < n; i++ {
+		var (
+			start = today.AddDate(0, 0, i)
+			end   = start.AddDate(0, 0, 1)
+			d     = day{Date: start}
+			syms  = map[string]int{}
+			found = false
+		)
+		for _, f := range all {
+			if f.Time.Before(start) || !f.Time.Before(end) {
 				continue
 			}
+
+			if !found || f.TempC < d.MinC {
+				d.MinC = f.TempC
+			}
+			if !found || f.TempC > d.MaxC {
+				d.MaxC = f.TempC
+			}
+			found = true
+
+			// Weather between 6am and 6pm without the _day, _night
+			// variants of met.no symbols.
+			if h := f.Time.In(zone).Hour(); h >= 6 && h < 18 && f.Forecast1H != "" {
+				syms[strings.SplitN(f.Forecast1H, "_", 2)[0]]++
+			}
+		}
+		if !found {
+			continue
 		}
 
-		out.Forecasts = append(out.Forecasts, f)
+		for s, c := range syms {
+			if c > syms[d.Symbol] || (c == syms[d.Symbol] && s < d.Symbol) {
+				d.Symbol = s
+			}
+		}
+		if d.Symbol == "" {
+			d.Symbol = "unknown"
+		}
 
-		// Only store 3 days of forecast.
-		if len(out.Forecasts) >= w.opt.MaxEntries {
-			break
+		out = append(out, d)
+	}
+
+	return out
+}
+
+// add adds a forecast to an entry if it's at least ForecastInterval after
+// the last one. It returns false once the entry has MaxEntries forecasts.
+func (w *Weather) add(e *entry, f forecast) bool {
+	// Only pick up entries with with a certain gap.
+	if len(e.Forecasts) > 0 {
+		if e.Forecasts[len(e.Forecasts)-1].Time.Add(w.opt.ForecastInterval).After(f.Time) {
+			return true
 		}
 	}
 
-	return out, nil
+	e.Forecasts = append(e.Forecasts, f)
+
+	// Only store 3 days of forecast.
+	return len(e.Forecasts) < w.opt.MaxEntries
+}
+
+// wmoSymbol returns the met.no style symbol code for a WMO weather
+// interpretation code used by open-meteo.
+func wmoSymbol(code int) string {
+	switch {
+	case code == 0:
+		return "clearsky"
+	case code == 1:
+		return "fair"
+	case code == 2:
+		return "partlycloudy"
+	case code == 3:
+		return "cloudy"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 51 && code <= 57:
+		return "lightrain"
+	case code == 61 || code == 63:
+		return "rain"
+	case code == 65:
+		return "heavyrain"
+	case code == 66 || code == 67:
+		return "sleet"
+	case code >= 71 && code <= 77:
+		return "snow"
+	case code >= 80 && code <= 82:
+		return "rainshowers"
+	case code == 85 || code == 86:
+		return "snowshowers"
+	case code >= 95:
+		return "rainandthunder"
+	}
+
+	return "unknown"
 }
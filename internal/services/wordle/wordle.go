@@ -0,0 +1,131 @@
+// Package wordle implements a daily Wordle-style word guessing puzzle.
+package wordle
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/registry"
+	"github.com/knadh/dns.toys/internal/txt"
+	"github.com/knadh/dns.toys/internal/words"
+	"github.com/knadh/koanf"
+)
+
+const (
+	wordLen = 5
+
+	// Seed for shuffling the word list so that the daily words
+	// don't go in alphabetical order.
+	seed = 20220101
+)
+
+// Puzzle numbers are days since this date.
+var epoch = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Wordle is the daily word puzzle.
+type Wordle struct {
+	words []string
+}
+
+func init() {
+	registry.Register(registry.Entry{
+		Name: "wordle",
+		New: func(*koanf.Koanf, *registry.Env) (registry.Service, error) {
+			return New()
+		},
+	})
+}
+
+// New returns a new instance of Wordle.
+func New() (*Wordle, error) {
+	list := words.OfLength(wordLen)
+	if len(list) == 0 {
+		return nil, errors.New("no words in the word list")
+	}
+
+	w := make([]string, len(list))
+	copy(w, list)
+	rand.New(rand.NewSource(seed)).Shuffle(len(w), func(i, j int) {
+		w[i], w[j] = w[j], w[i]
+	})
+
+	return &Wordle{words: w}, nil
+}
+
+// Query returns the day's puzzle info or the feedback for a guess.
+// Format: wordle (puzzle of the day), guess-$word. eg: guess-crane
+// Feedback: G = right letter in the right spot, Y = right letter in the
+// wrong spot, . = letter not in the word.
+func (w *Wordle) Query(q string) ([]string, error) {
+	num, target := w.today()
+
+	if q == "wordle." {
+		h := sha256.Sum256([]byte(target))
+		r := txt.Record(q,
+			fmt.Sprintf("puzzle #%d", num), fmt.Sprintf("%d letters", wordLen),
+			fmt.Sprintf("sha256 %x", h[:8]), "guess: dig guess-crane.wordle")
+		return []string{r}, nil
+	}
+
+	guess := strings.TrimPrefix(strings.ToLower(q), "guess-")
+	if guess == q || len(guess) != wordLen || strings.Trim(guess, "abcdefghijklmnopqrstuvwxyz") != "" {
+		return nil, fmt.Errorf("invalid guess. Should be a %d letter word. eg: guess-crane", wordLen)
+	}
+
+	fb := feedback(guess, target)
+
+	status := "keep guessing"
+	if guess == target {
+		status = fmt.Sprintf("solved puzzle #%d!", num)
+	}
+
+	r := txt.Record(q, strings.ToUpper(guess), fb, status)
+	return []string{r}, nil
+}
+
+// Help returns the help text of the service.
+func (w *Wordle) Help() registry.Help {
+	return registry.Help{
+		Desc:     "play the daily 5 letter word puzzle (G = right spot, Y = wrong spot).",
+		Syntax:   "wordle or guess-$word.wordle",
+		Examples: []string{"dig guess-crane.wordle @%s", "dig wordle @%s"},
+	}
+}
+
+// today returns the puzzle number and word of the day (UTC).
+func (w *Wordle) today() (int, string) {
+	n := int(time.Since(epoch).Hours() / 24)
+	return n, w.words[n%len(w.words)]
+}
+
+// feedback returns the G/Y/. feedback string for a guess. Repeated letters
+// are marked only as many times as they occur in the target.
+func feedback(guess, target string) string {
+	var (
+		out  = []byte(strings.Repeat(".", len(guess)))
+		left = map[byte]int{}
+	)
+
+	// Exact matches first.
+	for i := 0; i < len(guess); i++ {
+		if guess[i] == target[i] {
+			out[i] = 'G'
+		} else {
+			left[target[i]]++
+		}
+	}
+
+	// Misplaced letters.
+	for i := 0; i < len(guess); i++ {
+		if out[i] != 'G' && left[guess[i]] > 0 {
+			out[i] = 'Y'
+			left[guess[i]]--
+		}
+	}
+
+	return string(out)
+}
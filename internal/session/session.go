@@ -0,0 +1,172 @@
+// Package session is a small in-memory store with expiring sessions
+// for interactive, stateful services such as games.
+package session
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+)
+
+// Session IDs are lowercase alphanumeric as DNS names are case insensitive.
+const (
+	idChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	idLen   = 6
+)
+
+var (
+	// ErrNotFound is returned when a session doesn't exist or has expired.
+	ErrNotFound = errors.New("session not found or expired.")
+
+	// ErrFull is returned when the store has reached its maximum size.
+	ErrFull = errors.New("too many active sessions. Try again later.")
+)
+
+// Store is an in-memory session store. Sessions expire after TTL
+// since they were last accessed.
+type Store struct {
+	ttl time.Duration
+	max int
+
+	mu    sync.Mutex
+	items map[string]*item
+
+	// Number of session lookups that were (not) found.
+	hits, misses uint64
+}
+
+type item struct {
+	val     interface{}
+	created time.Time
+	expires time.Time
+}
+
+// New returns a new session store that holds up to max sessions,
+// each of which expires after ttl of inactivity.
+func New(ttl time.Duration, max int) *Store {
+	return &Store{
+		ttl:   ttl,
+		max:   max,
+		items: make(map[string]*item),
+	}
+}
+
+// Create stores a new session and returns its ID.
+func (s *Store) Create(val interface{}) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) >= s.max {
+		s.sweep()
+		if len(s.items) >= s.max {
+			return "", ErrFull
+		}
+	}
+
+	for {
+		id, err := newID()
+		if err != nil {
+			return "", err
+		}
+
+		if _, ok := s.items[id]; ok {
+			continue
+		}
+
+		now := time.Now()
+		s.items[id] = &item{val: val, created: now, expires: now.Add(s.ttl)}
+		return id, nil
+	}
+}
+
+// Update calls fn with the value of the session, holding a lock on the store
+// so that fn can safely modify the value. The session's expiry is extended.
+// If fn returns done = true, the session is deleted.
+func (s *Store) Update(id string, fn func(val interface{}) (done bool, err error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[id]
+	if !ok || time.Now().After(it.expires) {
+		delete(s.items, id)
+		s.misses++
+		return ErrNotFound
+	}
+	s.hits++
+
+	done, err := fn(it.val)
+	if done {
+		delete(s.items, id)
+	} else {
+		it.expires = time.Now().Add(s.ttl)
+	}
+
+	return err
+}
+
+// Delete deletes a session.
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	delete(s.items, id)
+	s.mu.Unlock()
+}
+
+// Len returns the number of sessions in the store, including ones
+// that have expired but haven't been removed yet.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
+// CacheStats returns the statistics of the store. Sessions hold arbitrary
+// values, so memory is not estimated.
+func (s *Store) CacheStats() cache.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := cache.Stats{
+		Entries: len(s.items),
+		Bytes:   -1,
+		Hits:    s.hits,
+		Misses:  s.misses,
+	}
+	for _, it := range s.items {
+		if st.Oldest.IsZero() || it.created.Before(st.Oldest) {
+			st.Oldest = it.created
+		}
+		if it.created.After(st.Newest) {
+			st.Newest = it.created
+		}
+	}
+
+	return st
+}
+
+// sweep removes expired sessions. The lock should be held by the caller.
+func (s *Store) sweep() {
+	now := time.Now()
+	for id, it := range s.items {
+		if now.After(it.expires) {
+			delete(s.items, id)
+		}
+	}
+}
+
+// newID returns a random session ID.
+func newID() (string, error) {
+	b := make([]byte, idLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = idChars[int(b[i])%len(idChars)]
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,158 @@
+// Package txt formats the TXT record answers of services. Character strings
+// in TXT records are limited to 255 bytes, so long text is split into
+// multiple strings, and into multiple records if needed, at spaces where
+// possible and never within a UTF-8 character.
+package txt
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLen is the max length of a TXT character string.
+const MaxLen = 255
+
+// Record returns a TXT record for name with strs as its strings in the zone
+// file format accepted by dns.NewRR(). Strings are escaped and the ones
+// longer than MaxLen are split.
+func Record(name string, strs ...string) string {
+	return RecordTTL(name, 1, strs...)
+}
+
+// RecordTTL is Record with a TTL other than 1 second.
+func RecordTTL(name string, ttl int, strs ...string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(ttl))
+	b.WriteString(" TXT")
+	writeStrings(&b, strs)
+
+	return b.String()
+}
+
+// Append appends strs to a record returned by Record (eg: a note that an
+// answer is stale).
+func Append(rec string, strs ...string) string {
+	var b strings.Builder
+	b.WriteString(rec)
+	writeStrings(&b, strs)
+
+	return b.String()
+}
+
+// Records returns TXT records for name with a prefix of strings (eg: a
+// title) followed by text split into strings. Records are limited to
+// perRecord strings of text (0 for no limit) after which the text continues
+// in a new record with the same prefix.
+func Records(name string, prefix []string, text string, perRecord int) []string {
+	var (
+		parts = Split(text)
+		out   []string
+	)
+	if perRecord <= 0 {
+		perRecord = len(parts)
+	}
+
+	for i := 0; i < len(parts); i += perRecord {
+		end := i + perRecord
+		if end > len(parts) {
+			end = len(parts)
+		}
+
+		strs := append(append([]string{}, prefix...), parts[i:end]...)
+		out = append(out, Record(name, strs...))
+	}
+
+	return out
+}
+
+// Split splits s into strings that fit in a TXT character string once
+// escaped, breaking after the last space within the limit if there's one.
+func Split(s string) []string {
+	if escLen(s) <= MaxLen {
+		return []string{s}
+	}
+
+	var out []string
+	for escLen(s) > MaxLen {
+		// Find the longest prefix that fits without splitting a character.
+		var (
+			n     = 0
+			size  = 0
+			space = -1
+		)
+		for n < len(s) {
+			r, w := utf8.DecodeRuneInString(s[n:])
+			cl := w
+			if r == '"' || r == '\\' {
+				cl = 2
+			}
+			if size+cl > MaxLen {
+				break
+			}
+
+			if r == ' ' {
+				space = n
+			}
+			size += cl
+			n += w
+		}
+
+		// Break after the last space, unless it's too early in the string,
+		// so that the strings joined are the original text.
+		if space > MaxLen/2 {
+			out = append(out, s[:space+1])
+			s = s[space+1:]
+			continue
+		}
+
+		out = append(out, s[:n])
+		s = s[n:]
+	}
+
+	if s != "" {
+		out = append(out, s)
+	}
+
+	return out
+}
+
+// Strings returns strs escaped and split for the Txt field of a dns.TXT,
+// which holds strings in the zone file format.
+func Strings(strs ...string) []string {
+	out := make([]string, 0, len(strs))
+	for _, s := range strs {
+		for _, c := range Split(s) {
+			out = append(out, escape(c))
+		}
+	}
+
+	return out
+}
+
+// writeStrings writes strs as escaped and split TXT character strings.
+func writeStrings(b *strings.Builder, strs []string) {
+	for _, s := range strs {
+		for _, c := range Split(s) {
+			b.WriteString(` "`)
+			b.WriteString(escape(c))
+			b.WriteString(`"`)
+		}
+	}
+}
+
+// escape escapes the quotes and backslashes in a TXT string.
+func escape(s string) string {
+	if !strings.ContainsAny(s, `"\`) {
+		return s
+	}
+
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// escLen returns the length of s once escaped.
+func escLen(s string) int {
+	return len(s) + strings.Count(s, `"`) + strings.Count(s, `\`)
+}
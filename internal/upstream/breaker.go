@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker that opens after a number of consecutive
+// fetch failures. While open, fetches are not attempted until the cooldown
+// passes, after which a single probe fetch is allowed (half-open). If the
+// probe succeeds the breaker closes, else it opens again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	fails    int
+	openedAt time.Time
+	probing  bool
+	mut      sync.Mutex
+}
+
+// allow returns true if a fetch can be attempted.
+func (b *breaker) allow() bool {
+	if b.threshold < 1 {
+		return true
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.fails < b.threshold {
+		return true
+	}
+
+	// Open. Allow a single probe once the cooldown has passed.
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// isOpen returns true if the breaker is open and the cooldown
+// hasn't passed.
+func (b *breaker) isOpen() bool {
+	if b.threshold < 1 {
+		return false
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.fails >= b.threshold && (b.probing || time.Since(b.openedAt) < b.cooldown)
+}
+
+// done records the result of a fetch and returns true if the
+// breaker opened (or re-opened after a failed probe).
+func (b *breaker) done(err error) bool {
+	if b.threshold < 1 {
+		return false
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.fails = 0
+		return false
+	}
+
+	b.fails++
+	if b.fails >= b.threshold {
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// cancel releases a probe that was allowed but not made.
+func (b *breaker) cancel() {
+	b.mut.Lock()
+	b.probing = false
+	b.mut.Unlock()
+}
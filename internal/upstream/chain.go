@@ -0,0 +1,91 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited should be wrapped in the errors returned by providers
+// when the upstream rate limits them (eg: HTTP 429).
+var ErrRateLimited = errors.New("rate limited")
+
+// Provider is one of the upstream APIs that can answer a request.
+type Provider struct {
+	Name  string
+	Fetch FetchFunc
+}
+
+// Chain fetches from an ordered list of providers, failing over to the
+// next one when a provider errors. Providers that are rate limited are
+// skipped until the cooldown passes.
+type Chain struct {
+	name      string
+	providers []Provider
+	cooldown  time.Duration
+
+	skipUntil []time.Time
+	mut       sync.Mutex
+
+	stats counters
+}
+
+// NewChain returns a Chain of providers. name is used in log messages.
+func NewChain(name string, cooldown time.Duration, p ...Provider) *Chain {
+	if cooldown == 0 {
+		cooldown = time.Minute * 5
+	}
+
+	return &Chain{
+		name:      name,
+		providers: p,
+		cooldown:  cooldown,
+		skipUntil: make([]time.Time, len(p)),
+	}
+}
+
+// Fetch returns the value from the first provider that answers a request
+// along with the name of the provider.
+func (c *Chain) Fetch(req interface{}) (interface{}, string, error) {
+	var errs []string
+	for i, p := range c.providers {
+		c.mut.Lock()
+		skip := time.Now().Before(c.skipUntil[i])
+		c.mut.Unlock()
+		if skip {
+			continue
+		}
+
+		val, err := p.Fetch(req)
+		c.stats.add(err)
+		if err == nil {
+			if i > 0 {
+				slog.Info("answered by fallback provider", "upstream", c.name, "provider", p.Name)
+			}
+			return val, p.Name, nil
+		}
+
+		if errors.Is(err, ErrRateLimited) {
+			slog.Warn("provider is rate limited. Skipping it", "upstream", c.name, "provider", p.Name, "cooldown", c.cooldown)
+			c.mut.Lock()
+			c.skipUntil[i] = time.Now().Add(c.cooldown)
+			c.mut.Unlock()
+		}
+
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name, err))
+	}
+
+	if len(errs) == 0 {
+		return nil, "", errors.New("all providers are rate limited")
+	}
+
+	return nil, "", errors.New(strings.Join(errs, "; "))
+}
+
+// UpstreamStats returns the counts of fetches to the providers.
+func (c *Chain) UpstreamStats() Stats {
+	return c.stats.get()
+}
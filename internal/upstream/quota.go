@@ -0,0 +1,76 @@
+package upstream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QuotaError is returned when there is no cached value and the hourly or
+// daily quota of fetches to the upstream is exhausted.
+type QuotaError struct {
+	Reset time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded, resets at %s UTC.", e.Reset.UTC().Format("15:04"))
+}
+
+// quota limits the number of fetches to the upstream per UTC clock hour
+// and day. 0 is unlimited.
+type quota struct {
+	hourly int
+	daily  int
+
+	hour, day   time.Time
+	hourN, dayN int
+	mut         sync.Mutex
+}
+
+// take uses up a fetch from the quota and returns true if it's allowed.
+func (q *quota) take() bool {
+	if q.hourly < 1 && q.daily < 1 {
+		return true
+	}
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if _, ok := q.exhausted(time.Now()); ok {
+		return false
+	}
+	q.hourN++
+	q.dayN++
+	return true
+}
+
+// resetAt returns the time the quota resets if it's exhausted.
+func (q *quota) resetAt() (time.Time, bool) {
+	if q.hourly < 1 && q.daily < 1 {
+		return time.Time{}, false
+	}
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.exhausted(time.Now())
+}
+
+// exhausted rolls the windows over and returns the time the quota resets
+// if it's exhausted. q.mut should be locked.
+func (q *quota) exhausted(now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	if h := now.Truncate(time.Hour); !h.Equal(q.hour) {
+		q.hour, q.hourN = h, 0
+	}
+	if d := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !d.Equal(q.day) {
+		q.day, q.dayN = d, 0
+	}
+
+	if q.daily > 0 && q.dayN >= q.daily {
+		return q.day.AddDate(0, 0, 1), true
+	}
+	if q.hourly > 0 && q.hourN >= q.hourly {
+		return q.hour.Add(time.Hour), true
+	}
+	return time.Time{}, false
+}
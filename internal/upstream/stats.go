@@ -0,0 +1,52 @@
+package upstream
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Stats are the counts of fetches to an upstream.
+type Stats struct {
+	Fetches uint64
+	Errors  uint64
+
+	// RateLimited is the number of fetches that the upstream rate limited
+	// and Dropped the ones that were not made because of the local rate
+	// limit or quota.
+	RateLimited uint64
+	Dropped     uint64
+}
+
+// Stater is implemented by services that fetch from upstreams.
+type Stater interface {
+	UpstreamStats() Stats
+}
+
+type counters struct {
+	fetches     uint64
+	errors      uint64
+	rateLimited uint64
+	dropped     uint64
+}
+
+// add counts a fetch and its error, if any.
+func (c *counters) add(err error) {
+	atomic.AddUint64(&c.fetches, 1)
+	if err == nil {
+		return
+	}
+
+	atomic.AddUint64(&c.errors, 1)
+	if errors.Is(err, ErrRateLimited) {
+		atomic.AddUint64(&c.rateLimited, 1)
+	}
+}
+
+func (c *counters) get() Stats {
+	return Stats{
+		Fetches:     atomic.LoadUint64(&c.fetches),
+		Errors:      atomic.LoadUint64(&c.errors),
+		RateLimited: atomic.LoadUint64(&c.rateLimited),
+		Dropped:     atomic.LoadUint64(&c.dropped),
+	}
+}
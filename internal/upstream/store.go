@@ -0,0 +1,97 @@
+package upstream
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a persistent backend for cached entries. When a Fetcher has a
+// Store, entries are written through to it and the in-memory map only holds
+// the most recently used ones (read-through), so that large caches survive
+// restarts and don't have to fit in memory.
+type Store interface {
+	Get(key string) (Entry, bool, error)
+	Put(key string, e Entry) error
+	Len() int
+	Close() error
+}
+
+// BoltStore is a Store backed by a BoltDB file.
+type BoltStore struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// NewBoltStore opens (or creates) a BoltDB file and returns a Store that
+// keeps its entries in the given bucket. Multiple stores can share a file
+// with different buckets.
+func NewBoltStore(path, bucket string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second * 5})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db, bucket: []byte(bucket)}, nil
+}
+
+// Get returns the entry for a key.
+func (s *BoltStore) Get(key string) (Entry, bool, error) {
+	var b []byte
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(s.bucket).Get([]byte(key)); v != nil {
+			b = append([]byte(nil), v...)
+		}
+		return nil
+	}); err != nil {
+		return Entry{}, false, err
+	}
+
+	if b == nil {
+		return Entry{}, false, nil
+	}
+
+	var e Entry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return Entry{}, false, err
+	}
+
+	return e, true, nil
+}
+
+// Put writes the entry for a key.
+func (s *BoltStore) Put(key string, e Entry) error {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(e); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte(key), buf.Bytes())
+	})
+}
+
+// Len returns the number of stored entries.
+func (s *BoltStore) Len() int {
+	n := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(s.bucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// Close closes the underlying DB file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
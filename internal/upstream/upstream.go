@@ -0,0 +1,575 @@
+// Package upstream is a cached, rate limited, queued fetcher for services that
+// look up data from upstream HTTP APIs. Lookups that are not cached are queued
+// and fetched in the background so that DNS queries are never blocked on the
+// upstream. Failures are cached for a while (negative caching) so as to not
+// flood the upstream.
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/logging"
+
+	"golang.org/x/time/rate"
+)
+
+// FetchFunc fetches the value for a request from the upstream.
+type FetchFunc func(req interface{}) (interface{}, error)
+
+// Opt contains config options for the Fetcher.
+type Opt struct {
+	// Name is used in log messages.
+	Name string
+
+	// TTL is the cache duration for successfully fetched values and
+	// NegativeTTL for failed fetches.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	// Max requests/sec to the upstream. Fetches over it wait in the queue.
+	RateLimit float64
+
+	// Number of times to retry a failed fetch, waiting RetryWait between each.
+	Retries   int
+	RetryWait time.Duration
+
+	QueueSize int
+
+	// StaleWhileRevalidate is how long past its expiry a value is still
+	// served (marked stale) while it is refreshed in the background.
+	// StaleIfError is how long past its expiry a value is served if the
+	// refresh fails or the upstream is down. After these windows, lookups
+	// are treated as uncached. 0 disables them.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+
+	// Max time a lookup for an uncached key waits for the value to be
+	// fetched. Concurrent lookups for the same key share a single fetch.
+	// 0 returns ErrQueued immediately.
+	Wait time.Duration
+
+	// Number of consecutive failed fetches after which the upstream is
+	// considered down and fetches are stopped for BreakerCooldown, after
+	// which a single probe fetch is made. 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Max fetches to the upstream per UTC clock hour and day. Once
+	// exhausted, lookups that can't be served from the cache get a
+	// *QuotaError. 0 is unlimited.
+	HourlyQuota int
+	DailyQuota  int
+
+	// Store, if set, persists entries to disk and MemEntries is the max
+	// number of entries that are held in memory on top of it.
+	Store      Store
+	MemEntries int
+}
+
+// Entry is a cached value.
+type Entry struct {
+	Val interface{}
+
+	// Bytes is the approximate size of Val (its gob encoding) for the
+	// cache's memory estimate.
+	Bytes int
+
+	FetchedAt time.Time
+	ExpiresAt time.Time
+	Valid     bool
+
+	// Failed is set when the value's refresh failed and it is being
+	// served stale. It is not refreshed again until RetryAt.
+	Failed  bool
+	RetryAt time.Time
+}
+
+// Fetcher fetches and caches values from an upstream.
+type Fetcher struct {
+	// In-memory entries. They're kept for as long as they can be served
+	// (their TTL and stale windows) after which they're removed.
+	mem *cache.Map
+
+	// Queue for defering API fetch requests.
+	queue chan job
+
+	// Fetches that are queued or in progress by key, so that concurrent
+	// lookups for the same key result in a single fetch.
+	inflight map[string]*call
+
+	fetch   FetchFunc
+	breaker *breaker
+	quota   *quota
+	limiter *rate.Limiter
+	mut     sync.RWMutex
+	opt     Opt
+
+	// Number of lookups and the ones served from the cache.
+	lookups uint64
+	hits    uint64
+
+	stats counters
+}
+
+type job struct {
+	key  string
+	req  interface{}
+	call *call
+
+	// Time by which the job has to be started.
+	deadline time.Time
+}
+
+type call struct {
+	// done is closed once the fetch is complete (or skipped).
+	done chan struct{}
+}
+
+// Max time a job waits in the queue for the rate limit. By then, the lookups
+// that queued it have long returned.
+const maxQueueWait = time.Minute
+
+var (
+	// ErrQueued is returned when there is no cached value and the request
+	// has been queued for fetching.
+	ErrQueued = errors.New("data is queued.")
+
+	// ErrUnavailable is returned when the last fetch failed.
+	ErrUnavailable = errors.New("data is unavailable. Try again in a few seconds.")
+
+	// ErrDown is returned when there is no cached value and the upstream
+	// is down (the circuit breaker is open).
+	ErrDown = errors.New("temporarily unavailable. Try again later.")
+)
+
+func init() {
+	// In-memory entries are dumped to snapshots.
+	gob.Register(Entry{})
+}
+
+// New returns a new Fetcher that fetches values with fn. Services that
+// persist snapshots with Dump() should gob.Register() the type of their values.
+func New(o Opt, fn FetchFunc) *Fetcher {
+	if o.NegativeTTL == 0 {
+		o.NegativeTTL = time.Minute * 10
+	}
+	if o.QueueSize < 1 {
+		o.QueueSize = 1000
+	}
+	if o.RateLimit <= 0 {
+		o.RateLimit = 1
+	}
+	if o.BreakerCooldown == 0 {
+		o.BreakerCooldown = time.Minute
+	}
+	if o.MemEntries < 1 {
+		o.MemEntries = 10000
+	}
+
+	f := &Fetcher{
+		queue:    make(chan job, o.QueueSize),
+		inflight: make(map[string]*call),
+		fetch:    fn,
+		breaker:  &breaker{threshold: o.BreakerThreshold, cooldown: o.BreakerCooldown},
+		quota:    &quota{hourly: o.HourlyQuota, daily: o.DailyQuota},
+		limiter:  rate.NewLimiter(rate.Limit(o.RateLimit), int(math.Ceil(o.RateLimit))),
+		opt:      o,
+	}
+
+	// With a Store, memory only holds the recently used entries.
+	if o.Store != nil {
+		f.mem = cache.NewMap(0, o.MemEntries)
+	} else {
+		f.mem = cache.NewMap(0, 0)
+	}
+
+	go f.runQueue()
+
+	return f
+}
+
+// Get returns the cached value for a key and whether it is stale. If there
+// is no value, or if the value has expired, req is queued to be fetched in the
+// background. Expired values are still returned within the stale windows so
+// that the response is instant and the next request gets the updated value.
+// Uncached lookups wait for up to Opt.Wait for the value.
+func (f *Fetcher) Get(key string, req interface{}) (interface{}, bool, error) {
+	return f.GetContext(context.Background(), key, req)
+}
+
+// GetContext is Get where uncached lookups also stop waiting for the value
+// when ctx is done (eg: the query's deadline is reached) and get ErrQueued.
+// The fetch itself is not cancelled as it may be shared with other lookups.
+func (f *Fetcher) GetContext(ctx context.Context, key string, req interface{}) (interface{}, bool, error) {
+	now := time.Now()
+	atomic.AddUint64(&f.lookups, 1)
+
+	e, ok := f.lookup(key)
+	if ok && e.Valid && now.Before(e.ExpiresAt) {
+		atomic.AddUint64(&f.hits, 1)
+		logging.SetOutcome(ctx, logging.CacheHit)
+		return e.Val, false, nil
+	}
+
+	var (
+		down  = f.breaker.isOpen()
+		stale = ok && e.Valid && now.Sub(e.ExpiresAt) <= f.staleWindow(e, down)
+	)
+
+	// The upstream is down. Serve whatever is cached without queueing anything.
+	if down {
+		if stale {
+			atomic.AddUint64(&f.hits, 1)
+			logging.SetOutcome(ctx, logging.Stale)
+			return e.Val, true, nil
+		}
+		return nil, false, ErrDown
+	}
+
+	// The quota is exhausted. Serve whatever is cached as the value
+	// can't be refreshed until the quota resets.
+	if reset, over := f.quota.resetAt(); over {
+		if ok && e.Valid && now.Sub(e.ExpiresAt) <= f.staleWindow(e, true) {
+			atomic.AddUint64(&f.hits, 1)
+			logging.SetOutcome(ctx, logging.Stale)
+			return e.Val, true, nil
+		}
+		return nil, false, &QuotaError{Reset: reset}
+	}
+
+	// Queue a fetch unless a failed one (negative cached) is yet to be retried.
+	var c *call
+	if now.After(e.ExpiresAt) && now.After(e.RetryAt) {
+		c = f.enqueue(key, req)
+	}
+
+	if stale {
+		atomic.AddUint64(&f.hits, 1)
+		logging.SetOutcome(ctx, logging.Stale)
+		return e.Val, true, nil
+	}
+
+	if ok && !e.Valid && c == nil {
+		return nil, false, ErrUnavailable
+	}
+
+	// Nothing to serve. Wait for the fetch.
+	if c == nil || f.opt.Wait == 0 {
+		logging.SetOutcome(ctx, logging.Queued)
+		return nil, false, ErrQueued
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(f.opt.Wait):
+		logging.SetOutcome(ctx, logging.Queued)
+		return nil, false, ErrQueued
+	case <-ctx.Done():
+		logging.SetOutcome(ctx, logging.Queued)
+		return nil, false, ErrQueued
+	}
+
+	e, ok = f.lookup(key)
+
+	// The fetch was skipped (eg: rate limited).
+	if !ok {
+		logging.SetOutcome(ctx, logging.Queued)
+		return nil, false, ErrQueued
+	}
+
+	now = time.Now()
+	if !e.Valid || now.Sub(e.ExpiresAt) > f.staleWindow(e, false) {
+		return nil, false, ErrUnavailable
+	}
+
+	logging.SetOutcome(ctx, logging.Fetched)
+	return e.Val, now.After(e.ExpiresAt), nil
+}
+
+// staleWindow returns how long past its expiry an entry can be served.
+func (f *Fetcher) staleWindow(e Entry, down bool) time.Duration {
+	if (e.Failed || down) && f.opt.StaleIfError > f.opt.StaleWhileRevalidate {
+		return f.opt.StaleIfError
+	}
+	return f.opt.StaleWhileRevalidate
+}
+
+// enqueue queues a fetch for a key unless one is already queued or in
+// progress and returns the call to wait on. It returns nil if the queue is full.
+func (f *Fetcher) enqueue(key string, req interface{}) *call {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if c, ok := f.inflight[key]; ok {
+		return c
+	}
+
+	c := &call{done: make(chan struct{})}
+	select {
+	case f.queue <- job{key: key, req: req, call: c, deadline: time.Now().Add(maxQueueWait)}:
+		f.inflight[key] = c
+		return c
+	default:
+		return nil
+	}
+}
+
+// newEntry returns the entry to cache for the result of a fetch. Even if
+// it's an error, it is cached to avoid flooding the upstream. If there's
+// an existing value within the stale-if-error window, it is retained.
+func (f *Fetcher) newEntry(old Entry, val interface{}, err error) Entry {
+	now := time.Now()
+	if err == nil {
+		return Entry{Val: val, Bytes: sizeOf(val), Valid: true, FetchedAt: now, ExpiresAt: now.Add(f.opt.TTL)}
+	}
+
+	if old.Valid && now.Sub(old.ExpiresAt) <= f.opt.StaleIfError {
+		old.Failed = true
+		old.RetryAt = now.Add(f.opt.NegativeTTL)
+		return old
+	}
+
+	return Entry{FetchedAt: now, ExpiresAt: now.Add(f.opt.NegativeTTL)}
+}
+
+// Size returns the approximate size of the entry's value for cache.Map.
+func (e Entry) Size() int {
+	return e.Bytes
+}
+
+// sizeOf returns the size of the gob encoding of v, or 0 if it can't be
+// encoded. It's computed once per fetch.
+func sizeOf(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+
+	var c countWriter
+	if err := gob.NewEncoder(&c).Encode(v); err != nil {
+		return 0
+	}
+
+	return int(c)
+}
+
+// countWriter is an io.Writer that counts the bytes written to it.
+type countWriter int
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	*c += countWriter(len(b))
+	return len(b), nil
+}
+
+// finish marks a queued fetch as complete and releases its waiters.
+func (f *Fetcher) finish(j job) {
+	f.mut.Lock()
+	delete(f.inflight, j.key)
+	f.mut.Unlock()
+
+	close(j.call.done)
+}
+
+// lookup returns the cached entry for a key. If there's a Store, entries
+// that are not in memory are read from it and kept in memory.
+func (f *Fetcher) lookup(key string) (Entry, bool) {
+	if v, ok := f.mem.Get(key); ok {
+		return v.(Entry), true
+	}
+
+	if f.opt.Store == nil {
+		return Entry{}, false
+	}
+
+	e, ok, err := f.opt.Store.Get(key)
+	if err != nil {
+		slog.Error("error reading cache store", "upstream", f.opt.Name, "error", err)
+		return e, false
+	}
+	if ok {
+		f.setMem(key, e)
+	}
+
+	return e, ok
+}
+
+// save caches an entry in memory and writes it to the Store if there's one.
+func (f *Fetcher) save(key string, e Entry) {
+	f.setMem(key, e)
+
+	if f.opt.Store == nil {
+		return
+	}
+	if err := f.opt.Store.Put(key, e); err != nil {
+		slog.Error("error writing cache store", "upstream", f.opt.Name, "error", err)
+	}
+}
+
+// setMem sets an in-memory entry for as long as it can be served. With a
+// Store, an arbitrary entry is evicted from memory (it remains in the Store)
+// when there are MemEntries entries.
+func (f *Fetcher) setMem(key string, e Entry) {
+	keep := f.opt.StaleWhileRevalidate
+	if f.opt.StaleIfError > keep {
+		keep = f.opt.StaleIfError
+	}
+
+	f.mem.SetTTL(key, e, time.Until(e.ExpiresAt)+keep)
+}
+
+// Len returns the number of cached entries.
+func (f *Fetcher) Len() int {
+	if f.opt.Store != nil {
+		return f.opt.Store.Len()
+	}
+
+	return f.mem.Len()
+}
+
+// CacheStats returns the statistics of the cache. With a Store, the memory
+// estimate and entry ages are of the entries held in memory.
+func (f *Fetcher) CacheStats() cache.Stats {
+	hits := atomic.LoadUint64(&f.hits)
+	st := cache.Stats{
+		Entries: f.Len(),
+		Hits:    hits,
+		Misses:  atomic.LoadUint64(&f.lookups) - hits,
+	}
+
+	f.mem.Range(func(_ string, it cache.Item) bool {
+		e := it.Val.(Entry)
+		if e.FetchedAt.IsZero() {
+			return true
+		}
+		if st.Oldest.IsZero() || e.FetchedAt.Before(st.Oldest) {
+			st.Oldest = e.FetchedAt
+		}
+		if e.FetchedAt.After(st.Newest) {
+			st.Newest = e.FetchedAt
+		}
+		return true
+	})
+
+	// The memory estimate is tracked by the in-memory cache as entries
+	// are set and removed.
+	st.Bytes = f.mem.CacheStats().Bytes
+
+	return st
+}
+
+// UpstreamStats returns the counts of fetches to the upstream.
+func (f *Fetcher) UpstreamStats() Stats {
+	return f.stats.get()
+}
+
+// Dump produces a gob dump of the cached data. With a Store, the data is
+// already persisted and nothing is dumped.
+func (f *Fetcher) Dump() ([]byte, error) {
+	if f.opt.Store != nil {
+		return nil, nil
+	}
+
+	data := make(map[string]Entry, f.mem.Len())
+	f.mem.Range(func(k string, it cache.Item) bool {
+		data[k] = it.Val.(Entry)
+		return true
+	})
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load loads a gob dump of cached data.
+func (f *Fetcher) Load(b []byte) error {
+	var data map[string]Entry
+	if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(&data); err != nil {
+		return err
+	}
+
+	for k, e := range data {
+		// Skip valid entries without values, eg: from an older dump format.
+		if e.Valid && e.Val == nil {
+			continue
+		}
+		f.setMem(k, e)
+
+		if f.opt.Store != nil {
+			if err := f.opt.Store.Put(k, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) runQueue() {
+	for j := range f.queue {
+		if !f.breaker.allow() {
+			f.finish(j)
+			continue
+		}
+
+		if !f.wait(j) {
+			slog.Warn("API rate limit exceeded", "upstream", f.opt.Name)
+			atomic.AddUint64(&f.stats.dropped, 1)
+			f.breaker.cancel()
+			f.finish(j)
+			continue
+		}
+
+		if !f.quota.take() {
+			slog.Warn("API quota exceeded", "upstream", f.opt.Name)
+			atomic.AddUint64(&f.stats.dropped, 1)
+			f.breaker.cancel()
+			f.finish(j)
+			continue
+		}
+
+		val, err := f.fetch(j.req)
+		f.stats.add(err)
+		for i := 0; err != nil && i < f.opt.Retries; i++ {
+			time.Sleep(f.opt.RetryWait)
+			if !f.wait(j) || !f.quota.take() {
+				atomic.AddUint64(&f.stats.dropped, 1)
+				break
+			}
+			val, err = f.fetch(j.req)
+			f.stats.add(err)
+		}
+
+		if err != nil {
+			slog.Error("error fetching API", "upstream", f.opt.Name, "error", err)
+		}
+
+		if f.breaker.done(err) {
+			slog.Warn("API is down. Pausing fetches", "upstream", f.opt.Name, "cooldown", f.opt.BreakerCooldown)
+		}
+
+		old, _ := f.lookup(j.key)
+		f.save(j.key, f.newEntry(old, val, err))
+
+		f.finish(j)
+	}
+}
+
+// wait waits for the rate limit until the job's deadline and returns false
+// if the job can't be started by then.
+func (f *Fetcher) wait(j job) bool {
+	ctx, cancel := context.WithDeadline(context.Background(), j.deadline)
+	defer cancel()
+
+	return f.limiter.Wait(ctx) == nil
+}
@@ -0,0 +1,48 @@
+// Package words provides an embedded list of common English words
+// for word games and generators.
+package words
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed common.txt
+var raw string
+
+var (
+	all    []string
+	byLen  = map[int][]string{}
+	lookup = map[string]struct{}{}
+)
+
+func init() {
+	for _, w := range strings.Split(raw, "\n") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+
+		all = append(all, w)
+		byLen[len(w)] = append(byLen[len(w)], w)
+		lookup[w] = struct{}{}
+	}
+}
+
+// All returns all the words in the list, sorted alphabetically.
+// The returned slice should not be modified.
+func All() []string {
+	return all
+}
+
+// OfLength returns the words of length n, sorted alphabetically.
+// The returned slice should not be modified.
+func OfLength(n int) []string {
+	return byLen[n]
+}
+
+// Has checks whether a word is in the list.
+func Has(w string) bool {
+	_, ok := lookup[w]
+	return ok
+}